@@ -103,6 +103,11 @@ type Provider struct {
 	// List of VPC subnet names. Required when configured to get node
 	// data from VPC.
 	G2VpcSubnetNames string `gcfg:"g2VpcSubnetNames"`
+	// Optional: List of VPC subnet names or IDs. For workers with multiple network
+	// interfaces, the address of the network interface in the first of these
+	// subnets is used as the node internal IP and as the load balancer pool member.
+	// Defaults to the address of the primary network interface.
+	G2NodeInternalIPSubnetNames string `gcfg:"g2NodeInternalIPSubnetNames"`
 	// Optional: VPC RIaaS endpoint override URL
 	G2EndpointOverride string `gcfg:"g2EndpointOverride"`
 	// Optional: IAM endpoint override URL
@@ -177,7 +182,7 @@ func (c *Cloud) HasClusterID() bool {
 func (c *Cloud) SetInformers(informerFactory informers.SharedInformerFactory) {
 	klog.Infof("Initializing Informers")
 
-	// endpointInformer is not needed for VPC
+	// endpointSliceInformer is not needed for VPC
 	if !c.isProviderVpc() {
 		c.ClassicCloud.SetInformers(informerFactory)
 	}
@@ -22,6 +22,7 @@ package ibm
 import (
 	"context"
 	"fmt"
+	"net"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -127,7 +128,7 @@ func (c *Cloud) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprov
 
 	providerID := c.providerIDV2(ctx, nodeMD)
 	instanceType := c.instanceTypeV2(ctx, nodeMD)
-	nodeAddresses := c.nodeAddressesV2(ctx, nodeMD)
+	nodeAddresses := c.nodeAddressesV2(ctx, node.Name, nodeMD)
 
 	instanceMetadata := cloudprovider.InstanceMetadata{
 		ProviderID:    providerID,
@@ -155,20 +156,31 @@ func (c *Cloud) instanceTypeV2(ctx context.Context, nodeMD NodeMetadata) string
 }
 
 // Get node addresses from node labels
-func (c *Cloud) nodeAddressesV2(ctx context.Context, nodeMD NodeMetadata) []v1.NodeAddress {
-	// ExternalIP may not be provided by metadata for private-only nodes, but
-	// we will return one in case external consumers depend on it.
-	externalIP := nodeMD.ExternalIP
-	if len(externalIP) == 0 {
-		externalIP = nodeMD.InternalIP
+func (c *Cloud) nodeAddressesV2(ctx context.Context, nodeName string, nodeMD NodeMetadata) []v1.NodeAddress {
+	// Return the primary internal IP first, followed by the additional IPv4 and IPv6 internal addresses
+	internalIPs := nodeMD.getInternalIPs()
+	// Return at most one external address for each IP family. ExternalIP may not be provided by
+	// metadata for private-only nodes, but we will return the primary internal IP in case external
+	// consumers depend on it. The additional internal addresses are never returned as external.
+	externalIPs := nodeMD.getExternalIPs()
+	if len(externalIPs) == 0 && len(nodeMD.InternalIP) > 0 {
+		externalIPs = []string{nodeMD.InternalIP}
 	}
 	// Build and return node nodeaddresses - if they are non-empty
 	nodeAddress := []v1.NodeAddress{}
-	if len(nodeMD.InternalIP) > 0 {
-		nodeAddress = append(nodeAddress, v1.NodeAddress{Type: v1.NodeInternalIP, Address: nodeMD.InternalIP})
+	for _, internalIP := range internalIPs {
+		nodeAddress = append(nodeAddress, v1.NodeAddress{Type: v1.NodeInternalIP, Address: internalIP})
 	}
-	if len(externalIP) > 0 {
+	for _, externalIP := range externalIPs {
 		nodeAddress = append(nodeAddress, v1.NodeAddress{Type: v1.NodeExternalIP, Address: externalIP})
 	}
+	// The host name is only returned along with the IP addresses of the node. The node name is
+	// only a DNS name if it is not an IP address
+	if len(nodeAddress) > 0 && nodeName != "" {
+		nodeAddress = append(nodeAddress, v1.NodeAddress{Type: v1.NodeHostName, Address: nodeName})
+		if net.ParseIP(nodeName) == nil {
+			nodeAddress = append(nodeAddress, v1.NodeAddress{Type: v1.NodeInternalDNS, Address: nodeName})
+		}
+	}
 	return nodeAddress
 }
@@ -21,12 +21,14 @@ package ibm
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 )
 
 func getInstancesV2InterfaceWithProvider(provider *Provider) cloudprovider.InstancesV2 {
@@ -105,6 +107,8 @@ func TestInstanceMetadata(t *testing.T) {
 		NodeAddresses: []v1.NodeAddress{
 			{Type: v1.NodeInternalIP, Address: "10.190.31.186"},
 			{Type: v1.NodeExternalIP, Address: "169.61.102.244"},
+			{Type: v1.NodeHostName, Address: "testnode"},
+			{Type: v1.NodeInternalDNS, Address: "testnode"},
 		},
 		Zone:   "testfailuredomain",
 		Region: "testregion",
@@ -147,6 +151,9 @@ func TestInstanceMetadata(t *testing.T) {
 	if metadata.InstanceType != expectedMetadata.InstanceType {
 		t.Fatalf("InstanceType set to incorrect value of %s", metadata.InstanceType)
 	}
+	if len(metadata.NodeAddresses) != len(expectedMetadata.NodeAddresses) {
+		t.Fatalf("NodeAddress set to incorrect value of %v", metadata.NodeAddresses)
+	}
 	for i, nodeAddress := range metadata.NodeAddresses {
 		if nodeAddress != expectedMetadata.NodeAddresses[i] {
 			t.Fatalf("NodeAddress set to incorrect value of %s", nodeAddress)
@@ -159,6 +166,62 @@ func TestInstanceMetadata(t *testing.T) {
 		t.Fatalf("Region set to incorrect value of %s", metadata.Region)
 	}
 
+	// testing getting dual-stack node, the IPv6 address is provided by kubelet
+	k8snodeDualStack := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "10.190.31.186",
+			Labels:      labels,
+			Annotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "10.190.31.186,2001:db8::1",
+				externalIPsAnnotation:                          "169.61.102.245,2001:db8:1::1",
+			}},
+	}
+	_, err = fakeclient.CoreV1().Nodes().Create(context.TODO(), &k8snodeDualStack, metav1.CreateOptions{})
+	if nil != err {
+		t.Fatalf("Failed to create Node 10.190.31.186: %v", err)
+	}
+	metadata, err = i.InstanceMetadata(context.Background(), &k8snodeDualStack)
+	if nil != err {
+		t.Fatalf("Failed to get InstanceID")
+	}
+	expectedAddresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.190.31.186"},
+		{Type: v1.NodeInternalIP, Address: "2001:db8::1"},
+		{Type: v1.NodeExternalIP, Address: "169.61.102.244"},
+		{Type: v1.NodeExternalIP, Address: "2001:db8:1::1"},
+		{Type: v1.NodeHostName, Address: "10.190.31.186"},
+	}
+	if !reflect.DeepEqual(metadata.NodeAddresses, expectedAddresses) {
+		t.Fatalf("NodeAddress set to incorrect value of %v", metadata.NodeAddresses)
+	}
+
+	// testing getting private-only dual-stack node, only the primary internal IP is returned as external
+	labels["ibm-cloud.kubernetes.io/external-ip"] = ""
+	k8snodePrivate := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "privatenode",
+			Labels:      labels,
+			Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "10.190.31.186,2001:db8::1"}},
+	}
+	_, err = fakeclient.CoreV1().Nodes().Create(context.TODO(), &k8snodePrivate, metav1.CreateOptions{})
+	if nil != err {
+		t.Fatalf("Failed to create Node privatenode: %v", err)
+	}
+	metadata, err = i.InstanceMetadata(context.Background(), &k8snodePrivate)
+	if nil != err {
+		t.Fatalf("Failed to get InstanceID")
+	}
+	expectedAddresses = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.190.31.186"},
+		{Type: v1.NodeInternalIP, Address: "2001:db8::1"},
+		{Type: v1.NodeExternalIP, Address: "10.190.31.186"},
+		{Type: v1.NodeHostName, Address: "privatenode"},
+		{Type: v1.NodeInternalDNS, Address: "privatenode"},
+	}
+	if !reflect.DeepEqual(metadata.NodeAddresses, expectedAddresses) {
+		t.Fatalf("NodeAddress set to incorrect value of %v", metadata.NodeAddresses)
+	}
+
 	labels["ibm-cloud.kubernetes.io/internal-ip"] = ""
 	labels["ibm-cloud.kubernetes.io/external-ip"] = ""
 	k8snode2 := v1.Node{
@@ -187,5 +187,9 @@ func (c *Cloud) isServiceConfigurationSupported(service *v1.Service) error {
 		}
 
 	}
+
+	if !c.isProviderVpc() {
+		return classic.IsServiceConfigurationSupported(service)
+	}
 	return nil
 }
@@ -94,6 +94,23 @@ func TestFilterLoadBalancersFromServiceList(t *testing.T) {
 		t.Fatalf("Load balancer with (allocate node ports == false) was filtered when it should not have been")
 	}
 
+	// Classic version 2.0 load balancer requires externalTrafficPolicy Local
+	s.Spec.AllocateLoadBalancerNodePorts = nil
+	s.Annotations = map[string]string{"service.kubernetes.io/ibm-load-balancer-cloud-provider-enable-features": "ipvs"}
+	services = &v1.ServiceList{Items: []v1.Service{s}}
+	c.filterLoadBalancersFromServiceList(services)
+	if len(services.Items) != 0 {
+		t.Fatalf("Version 2.0 load balancer with cluster traffic policy was not filtered when it should have been")
+	}
+	s.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	services = &v1.ServiceList{Items: []v1.Service{s}}
+	c.filterLoadBalancersFromServiceList(services)
+	if len(services.Items) != 1 {
+		t.Fatalf("Version 2.0 load balancer with local traffic policy was filtered when it should not have been")
+	}
+	s.Annotations = nil
+	s.Spec.ExternalTrafficPolicy = ""
+
 	// Allocate node port == "false" should be filtered if VPC
 	s.Spec.AllocateLoadBalancerNodePorts = &AllocateLoadBalancerNodePorts
 	services = &v1.ServiceList{Items: []v1.Service{s}}
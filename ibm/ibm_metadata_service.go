@@ -22,6 +22,8 @@ package ibm
 import (
 	"context"
 	"errors"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,8 +41,14 @@ import (
 // NodeMetadata holds the provider metatdata from a node.
 // Field names reflects Kubernetes CCM terminology.
 type NodeMetadata struct {
-	InternalIP    string
-	ExternalIP    string
+	InternalIP string
+	ExternalIP string
+	// Additional internal addresses of the node for each IP family, for example the IPv6 address of a
+	// dual-stack node or the addresses of secondary network interfaces. InternalIP is not repeated here.
+	InternalIPs map[v1.IPFamily][]string
+	// External address of the node for an IP family that ExternalIP does not cover, for example the
+	// IPv6 address of a dual-stack node. At most one external address is kept for each IP family.
+	ExternalIPs   map[v1.IPFamily]string
 	WorkerID      string
 	InstanceType  string
 	FailureDomain string
@@ -48,6 +56,107 @@ type NodeMetadata struct {
 	ProviderID    string
 }
 
+// addInternalIP adds an additional internal address to the node metadata. The address
+// is ignored if it is not valid, is the primary internal IP, or was already added.
+func (node *NodeMetadata) addInternalIP(address string) {
+	address = strings.TrimSpace(address)
+	ip := net.ParseIP(address)
+	if ip == nil || address == node.InternalIP {
+		return
+	}
+	family := getIPFamily(ip)
+	for _, existing := range node.InternalIPs[family] {
+		if existing == address {
+			return
+		}
+	}
+	if node.InternalIPs == nil {
+		node.InternalIPs = map[v1.IPFamily][]string{}
+	}
+	node.InternalIPs[family] = append(node.InternalIPs[family], address)
+}
+
+// getInternalIPs returns the primary internal IP of the node followed by the
+// additional IPv4 and IPv6 internal addresses.
+func (node *NodeMetadata) getInternalIPs() []string {
+	internalIPs := []string{}
+	if node.InternalIP != "" {
+		internalIPs = append(internalIPs, node.InternalIP)
+	}
+	internalIPs = append(internalIPs, node.InternalIPs[v1.IPv4Protocol]...)
+	internalIPs = append(internalIPs, node.InternalIPs[v1.IPv6Protocol]...)
+	return internalIPs
+}
+
+// getIPFamily returns the IP family of the parsed IP address
+func getIPFamily(ip net.IP) v1.IPFamily {
+	if ip.To4() != nil {
+		return v1.IPv4Protocol
+	}
+	return v1.IPv6Protocol
+}
+
+// addExternalIP adds an external address to the node metadata. The address is ignored if
+// it is not valid or if the node already has an external address of the same IP family.
+func (node *NodeMetadata) addExternalIP(address string) {
+	address = strings.TrimSpace(address)
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return
+	}
+	family := getIPFamily(ip)
+	if existing := net.ParseIP(node.ExternalIP); existing != nil && getIPFamily(existing) == family {
+		return
+	}
+	if node.ExternalIPs[family] != "" {
+		return
+	}
+	if node.ExternalIPs == nil {
+		node.ExternalIPs = map[v1.IPFamily]string{}
+	}
+	node.ExternalIPs[family] = address
+}
+
+// getExternalIPs returns the external IP of the node followed by the external
+// addresses of the other IP family, at most one address for each IP family.
+func (node *NodeMetadata) getExternalIPs() []string {
+	externalIPs := []string{}
+	if node.ExternalIP != "" {
+		externalIPs = append(externalIPs, node.ExternalIP)
+	}
+	for _, family := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
+		if address := node.ExternalIPs[family]; address != "" {
+			externalIPs = append(externalIPs, address)
+		}
+	}
+	return externalIPs
+}
+
+// addAnnotatedExternalIPs adds the external addresses in the external IPs annotation to the
+// node metadata. Label values can not hold an IPv6 address, so the annotation is the only
+// source of the IPv6 external address of a dual-stack node.
+func (node *NodeMetadata) addAnnotatedExternalIPs(k8sNode *v1.Node) {
+	externalIPs := k8sNode.Annotations[externalIPsAnnotation]
+	if externalIPs == "" {
+		return
+	}
+	for _, address := range strings.Split(externalIPs, ",") {
+		node.addExternalIP(address)
+	}
+}
+
+// addProvidedNodeIPs adds the addresses that kubelet was started with (--node-ip) to the
+// node metadata. On a dual-stack node this is the only source of the IPv6 address.
+func (node *NodeMetadata) addProvidedNodeIPs(k8sNode *v1.Node) {
+	providedIPs := k8sNode.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]
+	if providedIPs == "" {
+		return
+	}
+	for _, address := range strings.Split(providedIPs, ",") {
+		node.addInternalIP(address)
+	}
+}
+
 // MetadataService provides access to provider metadata stored in node labels.
 type MetadataService struct {
 	provider       Provider
@@ -65,6 +174,8 @@ const (
 	regionLabel        string = "ibm-cloud.kubernetes.io/region"
 	workerIDLabel      string = "ibm-cloud.kubernetes.io/worker-id"
 	machineTypeLabel   string = "ibm-cloud.kubernetes.io/machine-type"
+
+	externalIPsAnnotation string = "ibm-cloud.kubernetes.io/external-ips"
 )
 
 var (
@@ -198,6 +309,8 @@ func (ms *MetadataService) GetNodeMetadata(name string, applyNetworkUnavailable
 
 	// If all labels were set, cache and return the result
 	if ok {
+		newNode.addProvidedNodeIPs(k8sNode)
+		newNode.addAnnotatedExternalIPs(k8sNode)
 		ms.putCachedNode(name, newNode)
 		return newNode, nil
 	} else if isProviderVpc(ms.provider.ProviderType) {
@@ -217,6 +330,8 @@ func (ms *MetadataService) GetNodeMetadata(name string, applyNetworkUnavailable
 		if err != nil {
 			return node, err
 		}
+		newNode.addProvidedNodeIPs(k8sNode)
+		newNode.addAnnotatedExternalIPs(k8sNode)
 
 		ms.putCachedNode(name, newNode)
 		return newNode, nil
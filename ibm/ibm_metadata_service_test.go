@@ -388,3 +388,63 @@ func TestMetadataService(t *testing.T) {
 		t.Fatal("NodeMetadata not correct for 'noprovideridnode'.")
 	}
 }
+
+func TestNodeMetadataInternalIPs(t *testing.T) {
+	node := NodeMetadata{InternalIP: "10.0.0.1"}
+
+	// invalid, duplicate, and primary addresses are ignored
+	node.addInternalIP("")
+	node.addInternalIP("invalid")
+	node.addInternalIP("10.0.0.1")
+	node.addInternalIP(" 10.0.0.2 ")
+	node.addInternalIP("10.0.0.2")
+	expectedInternalIPs := map[corev1.IPFamily][]string{corev1.IPv4Protocol: {"10.0.0.2"}}
+	if !reflect.DeepEqual(expectedInternalIPs, node.InternalIPs) {
+		t.Fatalf("InternalIPs not correct: %v", node.InternalIPs)
+	}
+
+	// addresses provided by kubelet are added for each IP family
+	k8snode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "10.0.0.1,2001:db8::1"}}}
+	node.addProvidedNodeIPs(k8snode)
+	expectedInternalIPs[corev1.IPv6Protocol] = []string{"2001:db8::1"}
+	if !reflect.DeepEqual(expectedInternalIPs, node.InternalIPs) {
+		t.Fatalf("InternalIPs not correct: %v", node.InternalIPs)
+	}
+
+	// the primary internal IP is returned first, followed by IPv4 and IPv6 addresses
+	node.addInternalIP("10.0.0.3")
+	expectedList := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "2001:db8::1"}
+	if !reflect.DeepEqual(expectedList, node.getInternalIPs()) {
+		t.Fatalf("getInternalIPs not correct: %v", node.getInternalIPs())
+	}
+}
+
+func TestNodeMetadataExternalIPs(t *testing.T) {
+	node := NodeMetadata{ExternalIP: "169.61.102.244"}
+
+	// invalid addresses and addresses of a family that already has an external address are ignored
+	node.addExternalIP("")
+	node.addExternalIP("invalid")
+	node.addExternalIP("169.61.102.245")
+	node.addExternalIP(" 2001:db8::1 ")
+	node.addExternalIP("2001:db8::2")
+	expectedExternalIPs := map[corev1.IPFamily]string{corev1.IPv6Protocol: "2001:db8::1"}
+	if !reflect.DeepEqual(expectedExternalIPs, node.ExternalIPs) {
+		t.Fatalf("ExternalIPs not correct: %v", node.ExternalIPs)
+	}
+	expectedList := []string{"169.61.102.244", "2001:db8::1"}
+	if !reflect.DeepEqual(expectedList, node.getExternalIPs()) {
+		t.Fatalf("getExternalIPs not correct: %v", node.getExternalIPs())
+	}
+
+	// addresses in the annotation are added for each IP family of a private-only node
+	node = NodeMetadata{}
+	k8snode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{externalIPsAnnotation: "2001:db8::1,169.61.102.244,169.61.102.245"}}}
+	node.addAnnotatedExternalIPs(k8snode)
+	expectedList = []string{"169.61.102.244", "2001:db8::1"}
+	if !reflect.DeepEqual(expectedList, node.getExternalIPs()) {
+		t.Fatalf("getExternalIPs not correct: %v", node.getExternalIPs())
+	}
+}
@@ -36,6 +36,30 @@ type vpcClient struct {
 	sdk      *vpcv1.VpcV1
 }
 
+// vpcInterfaceAddress is the primary address of an instance network interface and its subnet
+type vpcInterfaceAddress struct {
+	address    string
+	subnetID   string
+	subnetName string
+}
+
+// newVpcInterfaceAddress returns the primary address and subnet of a network interface
+func newVpcInterfaceAddress(primaryIP *vpcv1.ReservedIPReference, subnet *vpcv1.SubnetReference) vpcInterfaceAddress {
+	interfaceAddress := vpcInterfaceAddress{}
+	if primaryIP != nil && primaryIP.Address != nil {
+		interfaceAddress.address = *primaryIP.Address
+	}
+	if subnet != nil {
+		if subnet.ID != nil {
+			interfaceAddress.subnetID = *subnet.ID
+		}
+		if subnet.Name != nil {
+			interfaceAddress.subnetName = *subnet.Name
+		}
+	}
+	return interfaceAddress
+}
+
 // newVpcSdkClient initializes a new sdk client and can be overridden by testing
 var newVpcSdkClient = func(provider Provider) (*vpcv1.VpcV1, error) {
 	// check id used to allocate worker nodes
@@ -142,9 +166,16 @@ func (vpc *vpcClient) populateNodeMetadata(nodeName string, node *NodeMetadata)
 
 	// Found the instance
 	if len(instances.Instances) == 1 {
-		node.InternalIP = *instances.Instances[0].PrimaryNetworkInterface.PrimaryIP.Address
+		interfaceAddresses := vpc.getInstanceInterfaceAddresses(&instances.Instances[0])
+		node.InternalIP = vpc.selectNodeInternalIP(interfaceAddresses)
 		klog.Infof("***** InternalIP %s", node.InternalIP)
 
+		// The other network interfaces provide additional internal addresses
+		for _, interfaceAddress := range interfaceAddresses {
+			node.addInternalIP(interfaceAddress.address)
+		}
+		klog.Infof("***** InternalIPs %v", node.InternalIPs)
+
 		node.WorkerID = *instances.Instances[0].ID
 		klog.Infof("***** WorkerId %s", node.WorkerID)
 
@@ -169,3 +200,44 @@ func (vpc *vpcClient) populateNodeMetadata(nodeName string, node *NodeMetadata)
 	// Too many entries
 	return errors.New("More than one instance entry returned: name=" + nodeName + " url=" + vpc.sdk.GetServiceURL())
 }
+
+// getInstanceInterfaceAddresses returns the addresses of all of the network interfaces and
+// virtual network interface attachments of the instance. The primary interface is returned first.
+func (vpc *vpcClient) getInstanceInterfaceAddresses(instance *vpcv1.Instance) []vpcInterfaceAddress {
+	interfaceAddresses := []vpcInterfaceAddress{}
+	if instance.PrimaryNetworkInterface != nil {
+		interfaceAddresses = append(interfaceAddresses,
+			newVpcInterfaceAddress(instance.PrimaryNetworkInterface.PrimaryIP, instance.PrimaryNetworkInterface.Subnet))
+	}
+	if instance.PrimaryNetworkAttachment != nil {
+		interfaceAddresses = append(interfaceAddresses,
+			newVpcInterfaceAddress(instance.PrimaryNetworkAttachment.PrimaryIP, instance.PrimaryNetworkAttachment.Subnet))
+	}
+	for _, networkInterface := range instance.NetworkInterfaces {
+		interfaceAddresses = append(interfaceAddresses, newVpcInterfaceAddress(networkInterface.PrimaryIP, networkInterface.Subnet))
+	}
+	for _, networkAttachment := range instance.NetworkAttachments {
+		interfaceAddresses = append(interfaceAddresses, newVpcInterfaceAddress(networkAttachment.PrimaryIP, networkAttachment.Subnet))
+	}
+	return interfaceAddresses
+}
+
+// selectNodeInternalIP returns the address of the network interface in the first of the configured
+// internal IP subnets. If no subnets are configured or none match, the primary interface address is returned.
+func (vpc *vpcClient) selectNodeInternalIP(interfaceAddresses []vpcInterfaceAddress) string {
+	if len(interfaceAddresses) == 0 {
+		return ""
+	}
+	for _, subnet := range strings.Split(vpc.provider.G2NodeInternalIPSubnetNames, ",") {
+		subnet = strings.TrimSpace(subnet)
+		if subnet == "" {
+			continue
+		}
+		for _, interfaceAddress := range interfaceAddresses {
+			if interfaceAddress.address != "" && (subnet == interfaceAddress.subnetName || subnet == interfaceAddress.subnetID) {
+				return interfaceAddress.address
+			}
+		}
+	}
+	return interfaceAddresses[0].address
+}
@@ -93,6 +93,7 @@ func (c *Cloud) NewConfigVpc(enablePrivateEndpoint bool) (*vpcctl.ConfigVpc, err
 		EnablePrivate:              enablePrivateEndpoint,
 		IamEndpointOverride:        c.Config.Prov.IamEndpointOverride,
 		IKSPrivateEndpointHostname: c.Config.Prov.IKSPrivateEndpointHostname,
+		NodeInternalIPSubnetNames:  c.Config.Prov.G2NodeInternalIPSubnetNames,
 		ProviderType:               c.Config.Prov.ProviderType,
 		Region:                     c.Config.Prov.Region,
 		ResourceGroupName:          c.Config.Prov.G2ResourceGroupName,
@@ -220,7 +221,7 @@ func (c *Cloud) vpcGetServiceDetails(service *v1.Service) string {
 	for _, ingress := range service.Status.LoadBalancer.Ingress {
 		status = append(status, hostnameIP{Hostname: ingress.Hostname, IP: ingress.IP})
 	}
-	return fmt.Sprintf("Name:%v NameSpace:%v UID:%v Annotations:%v Ports:%v ExternalTrafficPolicy:%v HealthCheckNodePort:%v Status:%+v",
+	return fmt.Sprintf("Name:%v NameSpace:%v UID:%v Annotations:%v Ports:%v ExternalTrafficPolicy:%v HealthCheckNodePort:%v SourceRanges:%v Status:%+v",
 		service.ObjectMeta.Name,
 		service.ObjectMeta.Namespace,
 		service.ObjectMeta.UID,
@@ -228,6 +229,7 @@ func (c *Cloud) vpcGetServiceDetails(service *v1.Service) string {
 		ports,
 		service.Spec.ExternalTrafficPolicy,
 		service.Spec.HealthCheckNodePort,
+		service.Spec.LoadBalancerSourceRanges,
 		status)
 }
 
@@ -20,8 +20,13 @@
 package classic
 
 import (
+	"sync"
+
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 )
 
@@ -40,14 +45,32 @@ type CloudConfig struct {
 
 // Cloud is the ibm cloud provider implementation.
 type Cloud struct {
-	Config *CloudConfig
+	KubeClient kubernetes.Interface
+	Config     *CloudConfig
+	Recorder   record.EventRecorder
+	calico     calicoPolicyManager // Created on first use based on the Calico datastore type
+	// Serializes the allocation of the load balancer IPs and virtual router IDs
+	// with the create or update of the deployments that use them
+	lbAllocationLock sync.Mutex
+	// Listers from the shared informer factory, set by SetInformers
+	endpointSliceLister discoverylisters.EndpointSliceLister
+	serviceLister       corelisters.ServiceLister
 }
 
+// NewCloud creates a new instance of the classic Cloud.
 func NewCloud(kubeClient kubernetes.Interface, config *CloudConfig, recorder record.EventRecorder) *Cloud {
-	return &Cloud{Config: config}
+	return &Cloud{KubeClient: kubeClient, Config: config, Recorder: recorder}
 }
 
 // SetInformers - Configure watch/informers
 func (c *Cloud) SetInformers(informerFactory informers.SharedInformerFactory) {
-	// No informers/watchers needed
+	c.endpointSliceLister = informerFactory.Discovery().V1().EndpointSlices().Lister()
+	c.serviceLister = informerFactory.Core().V1().Services().Lister()
+	endpointSliceInformer := informerFactory.Discovery().V1().EndpointSlices().Informer()
+	// #nosec G104 Error is ignored for now
+	endpointSliceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleEndpointSliceAdd,
+		UpdateFunc: c.handleEndpointSliceUpdate,
+		DeleteFunc: c.handleEndpointSliceDelete,
+	})
 }
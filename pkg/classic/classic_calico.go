@@ -0,0 +1,242 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	calicoDatastoreETCD = "ETCD"
+	calicoDatastoreKDD  = "KDD"
+
+	calicoAPIVersion               = "projectcalico.org/v3"
+	calicoKindGlobalNetworkPolicy  = "GlobalNetworkPolicy"
+	calicoPolicyNamePrefix         = "ibm-cloud-provider-lb-"
+	calicoPolicyOrder              = 1800
+	calicoHostEndpointSelector     = "ibm.role in { 'worker_public', 'worker_private' }"
+	calicoctlCommand               = "calicoctl"
+	calicoctlResourceGlobalNetwork = "globalnetworkpolicy"
+)
+
+// calicoGlobalNetworkPolicyResource is the Calico GlobalNetworkPolicy custom
+// resource used when Calico is configured with the Kubernetes datastore.
+var calicoGlobalNetworkPolicyResource = schema.GroupVersionResource{
+	Group:    "crd.projectcalico.org",
+	Version:  "v1",
+	Resource: "globalnetworkpolicies",
+}
+
+// execCalicoctl runs calicoctl with the specified arguments and input. It is
+// a variable so that it can be replaced by the tests.
+var execCalicoctl = func(input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(calicoctlCommand, args...) // #nosec G204 calicoctl arguments are generated by the cloud provider
+	cmd.Stdin = bytes.NewReader(input)
+	return cmd.CombinedOutput()
+}
+
+// calicoPolicyManager applies and deletes the Calico GlobalNetworkPolicy
+// objects for the load balancers, independent of the Calico datastore type.
+type calicoPolicyManager interface {
+	applyGlobalNetworkPolicy(name string, labels map[string]string, spec map[string]interface{}) error
+	deleteGlobalNetworkPolicy(name string) error
+}
+
+// calicoKDDPolicyManager manages the policies in the Kubernetes datastore
+type calicoKDDPolicyManager struct {
+	client dynamic.Interface
+}
+
+// calicoETCDPolicyManager manages the policies in the etcd datastore using calicoctl
+type calicoETCDPolicyManager struct{}
+
+// newCalicoKDDPolicyManager creates a Calico policy manager for the Kubernetes datastore
+func newCalicoKDDPolicyManager(client dynamic.Interface) *calicoKDDPolicyManager {
+	return &calicoKDDPolicyManager{client: client}
+}
+
+// applyGlobalNetworkPolicy creates or updates the policy custom resource
+func (m *calicoKDDPolicyManager) applyGlobalNetworkPolicy(name string, labels map[string]string, spec map[string]interface{}) error {
+	policies := m.client.Resource(calicoGlobalNetworkPolicyResource)
+	existing, err := policies.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		policy := &unstructured.Unstructured{}
+		policy.SetAPIVersion(calicoGlobalNetworkPolicyResource.GroupVersion().String())
+		policy.SetKind(calicoKindGlobalNetworkPolicy)
+		policy.SetName(name)
+		policy.SetLabels(labels)
+		policy.Object["spec"] = spec
+		_, err = policies.Create(context.TODO(), policy, metav1.CreateOptions{})
+		return err
+	}
+	existing.SetLabels(labels)
+	existing.Object["spec"] = spec
+	_, err = policies.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	return err
+}
+
+// deleteGlobalNetworkPolicy deletes the policy custom resource. No error is
+// returned if the policy does not exist.
+func (m *calicoKDDPolicyManager) deleteGlobalNetworkPolicy(name string) error {
+	err := m.client.Resource(calicoGlobalNetworkPolicyResource).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// applyGlobalNetworkPolicy creates or updates the policy using calicoctl
+func (m *calicoETCDPolicyManager) applyGlobalNetworkPolicy(name string, labels map[string]string, spec map[string]interface{}) error {
+	policy := map[string]interface{}{
+		"apiVersion": calicoAPIVersion,
+		"kind":       calicoKindGlobalNetworkPolicy,
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		"spec":       spec,
+	}
+	input, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	output, err := execCalicoctl(input, "apply", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("calicoctl apply failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// deleteGlobalNetworkPolicy deletes the policy using calicoctl. No error is
+// returned if the policy does not exist.
+func (m *calicoETCDPolicyManager) deleteGlobalNetworkPolicy(name string) error {
+	output, err := execCalicoctl(nil, "delete", calicoctlResourceGlobalNetwork, name, "--skip-not-exists")
+	if err != nil {
+		return fmt.Errorf("calicoctl delete failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// getCalicoPolicyManager returns the Calico policy manager for the configured
+// Calico datastore type. Nil is returned if the Calico datastore type is not
+// set or is not supported, the Calico policies are not managed in that case.
+func (c *Cloud) getCalicoPolicyManager() (calicoPolicyManager, error) {
+	if c.calico != nil {
+		return c.calico, nil
+	}
+	switch strings.ToUpper(c.Config.CalicoDatastore) {
+	case calicoDatastoreKDD:
+		restConfig, err := clientcmd.BuildConfigFromFlags("", c.Config.ConfigFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to build Kubernetes config from %s: %v", c.Config.ConfigFilePath, err)
+		}
+		client, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create Kubernetes dynamic client: %v", err)
+		}
+		c.calico = newCalicoKDDPolicyManager(client)
+	case calicoDatastoreETCD:
+		c.calico = &calicoETCDPolicyManager{}
+	case "":
+		klog.Warningf("Calico datastore type is not set, Calico policies are not managed for load balancers")
+		return nil, nil
+	default:
+		klog.Warningf("Calico datastore type '%s' is not supported, Calico policies are not managed for load balancers", c.Config.CalicoDatastore)
+		return nil, nil
+	}
+	return c.calico, nil
+}
+
+// getCalicoPolicyName returns the name of the Calico policy for the load balancer
+func getCalicoPolicyName(lbName string) string {
+	return calicoPolicyNamePrefix + lbName
+}
+
+// generateCalicoPolicySpec returns the Calico GlobalNetworkPolicy spec that
+// allows traffic to the load balancer IP and service ports through the
+// host endpoints of the cluster nodes.
+func generateCalicoPolicySpec(lbIP string, service *v1.Service) map[string]interface{} {
+	net := lbIP + "/32"
+	if getIPFamily(lbIP) == v1.IPv6Protocol {
+		net = lbIP + "/128"
+	}
+	portsByProtocol := map[string][]interface{}{}
+	for _, port := range service.Spec.Ports {
+		protocol := string(port.Protocol)
+		portsByProtocol[protocol] = append(portsByProtocol[protocol], int64(port.Port))
+	}
+	protocols := []string{}
+	for protocol := range portsByProtocol {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	ingress := []interface{}{}
+	for _, protocol := range protocols {
+		ingress = append(ingress, map[string]interface{}{
+			"action":   "Allow",
+			"protocol": protocol,
+			"destination": map[string]interface{}{
+				"nets":  []interface{}{net},
+				"ports": portsByProtocol[protocol],
+			},
+		})
+	}
+	return map[string]interface{}{
+		"order":          int64(calicoPolicyOrder),
+		"preDNAT":        true,
+		"applyOnForward": true,
+		"selector":       calicoHostEndpointSelector,
+		"types":          []interface{}{"Ingress"},
+		"ingress":        ingress,
+	}
+}
+
+// ensureCalicoPolicy creates or updates the Calico policy for the load balancer
+func (c *Cloud) ensureCalicoPolicy(lbName, lbIP string, service *v1.Service) error {
+	calico, err := c.getCalicoPolicyManager()
+	if err != nil || calico == nil {
+		return err
+	}
+	return calico.applyGlobalNetworkPolicy(getCalicoPolicyName(lbName), map[string]string{lbLabelName: lbName}, generateCalicoPolicySpec(lbIP, service))
+}
+
+// deleteCalicoPolicy deletes the Calico policy for the load balancer
+func (c *Cloud) deleteCalicoPolicy(lbName string) error {
+	calico, err := c.getCalicoPolicyManager()
+	if err != nil || calico == nil {
+		return err
+	}
+	return calico.deleteGlobalNetworkPolicy(getCalicoPolicyName(lbName))
+}
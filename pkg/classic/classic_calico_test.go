@@ -0,0 +1,181 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func getTestCalicoPolicy(c *Cloud, lbName string) *unstructured.Unstructured {
+	client := c.calico.(*calicoKDDPolicyManager).client
+	policy, err := client.Resource(calicoGlobalNetworkPolicyResource).Get(context.Background(), getCalicoPolicyName(lbName), metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return policy
+}
+
+func TestGenerateCalicoPolicySpec(t *testing.T) {
+	service := newTestService("echo-server", "1")
+	service.Spec.Ports = append(service.Spec.Ports,
+		v1.ServicePort{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053},
+		v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443})
+
+	spec := generateCalicoPolicySpec("192.168.10.20", service)
+	assert.Equal(t, true, spec["preDNAT"])
+	assert.Equal(t, true, spec["applyOnForward"])
+	assert.Equal(t, calicoHostEndpointSelector, spec["selector"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"action":      "Allow",
+			"protocol":    "TCP",
+			"destination": map[string]interface{}{"nets": []interface{}{"192.168.10.20/32"}, "ports": []interface{}{int64(80), int64(443)}},
+		},
+		map[string]interface{}{
+			"action":      "Allow",
+			"protocol":    "UDP",
+			"destination": map[string]interface{}{"nets": []interface{}{"192.168.10.20/32"}, "ports": []interface{}{int64(53)}},
+		},
+	}, spec["ingress"])
+
+	spec = generateCalicoPolicySpec("2001:db8::1", service)
+	ingress := spec["ingress"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{"2001:db8::1/128"}, ingress["destination"].(map[string]interface{})["nets"])
+}
+
+func TestCalicoKDDPolicyManager(t *testing.T) {
+	c, _, _ := newTestCloud()
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+
+	// Policy created
+	err := c.ensureCalicoPolicy(lbName, "192.168.10.20", service)
+	assert.Nil(t, err)
+	policy := getTestCalicoPolicy(c, lbName)
+	assert.NotNil(t, policy)
+	assert.Equal(t, calicoKindGlobalNetworkPolicy, policy.GetKind())
+	assert.Equal(t, lbName, policy.GetLabels()[lbLabelName])
+
+	// Policy updated
+	service.Spec.Ports[0].Port = 8080
+	err = c.ensureCalicoPolicy(lbName, "192.168.10.20", service)
+	assert.Nil(t, err)
+	policy = getTestCalicoPolicy(c, lbName)
+	ports, _, _ := unstructured.NestedSlice(policy.Object, "spec", "ingress")
+	assert.Equal(t, []interface{}{int64(8080)}, ports[0].(map[string]interface{})["destination"].(map[string]interface{})["ports"])
+
+	// Policy deleted, delete of a missing policy is ignored
+	err = c.deleteCalicoPolicy(lbName)
+	assert.Nil(t, err)
+	assert.Nil(t, getTestCalicoPolicy(c, lbName))
+	err = c.deleteCalicoPolicy(lbName)
+	assert.Nil(t, err)
+
+	// Policy create failed
+	c.calico.(*calicoKDDPolicyManager).client.(*dynamicfake.FakeDynamicClient).PrependReactor("create", "globalnetworkpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("create failed")
+	})
+	err = c.ensureCalicoPolicy(lbName, "192.168.10.20", service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "create failed")
+}
+
+func TestCalicoETCDPolicyManager(t *testing.T) {
+	c, _, _ := newTestCloud()
+	c.Config.CalicoDatastore = calicoDatastoreETCD
+	c.calico = nil
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+
+	var calls [][]string
+	var inputs []string
+	var execErr error
+	defer func(orig func([]byte, ...string) ([]byte, error)) { execCalicoctl = orig }(execCalicoctl)
+	execCalicoctl = func(input []byte, args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		inputs = append(inputs, string(input))
+		return []byte("calicoctl output"), execErr
+	}
+
+	// Policy applied
+	err := c.ensureCalicoPolicy(lbName, "192.168.10.20", service)
+	assert.Nil(t, err)
+	assert.IsType(t, &calicoETCDPolicyManager{}, c.calico)
+	assert.Equal(t, []string{"apply", "-f", "-"}, calls[0])
+	assert.Contains(t, inputs[0], `"apiVersion":"projectcalico.org/v3"`)
+	assert.Contains(t, inputs[0], `"name":"ibm-cloud-provider-lb-`+lbName+`"`)
+	assert.Contains(t, inputs[0], `"nets":["192.168.10.20/32"]`)
+
+	// Policy deleted
+	err = c.deleteCalicoPolicy(lbName)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"delete", "globalnetworkpolicy", getCalicoPolicyName(lbName), "--skip-not-exists"}, calls[1])
+
+	// calicoctl failed
+	execErr = errors.New("exit status 1")
+	err = c.ensureCalicoPolicy(lbName, "192.168.10.20", service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "calicoctl apply failed: exit status 1: calicoctl output")
+	err = c.deleteCalicoPolicy(lbName)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "calicoctl delete failed")
+}
+
+func TestGetCalicoPolicyManager(t *testing.T) {
+	c, _, _ := newTestCloud()
+
+	// Kubernetes datastore
+	c.calico = nil
+	c.Config.ConfigFilePath = "../../test-fixtures/kubernetes/k8s-config"
+	calico, err := c.getCalicoPolicyManager()
+	assert.Nil(t, err)
+	assert.IsType(t, &calicoKDDPolicyManager{}, calico)
+
+	// Kubernetes config is not valid
+	c.calico = nil
+	c.Config.ConfigFilePath = "../../test-fixtures/does-not-exist"
+	calico, err = c.getCalicoPolicyManager()
+	assert.Nil(t, calico)
+	assert.NotNil(t, err)
+
+	// Datastore type is not supported, the Calico policies are not managed
+	c.Config.CalicoDatastore = "invalid"
+	calico, err = c.getCalicoPolicyManager()
+	assert.Nil(t, calico)
+	assert.Nil(t, err)
+
+	// Datastore type is not set, the Calico policies are not managed
+	c.Config.CalicoDatastore = ""
+	calico, err = c.getCalicoPolicyManager()
+	assert.Nil(t, calico)
+	assert.Nil(t, err)
+	assert.Nil(t, c.ensureCalicoPolicy("lbName", "192.168.10.20", newTestService("echo-server", "1")))
+	assert.Nil(t, c.deleteCalicoPolicy("lbName"))
+}
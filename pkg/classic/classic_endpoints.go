@@ -0,0 +1,185 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// isServiceExternalTrafficPolicyLocal returns true if the service only
+// routes external traffic to node-local endpoints.
+func isServiceExternalTrafficPolicyLocal(service *v1.Service) bool {
+	return service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+}
+
+// listServiceEndpointSlices returns the endpoint slices of the service. The
+// informer cache is used if the informers were set, otherwise the slices are
+// listed from the API server.
+func (c *Cloud) listServiceEndpointSlices(service *v1.Service) ([]*discoveryv1.EndpointSlice, error) {
+	selector := labels.Set{discoveryv1.LabelServiceName: service.Name}.AsSelector()
+	if c.endpointSliceLister != nil {
+		return c.endpointSliceLister.EndpointSlices(service.Namespace).List(selector)
+	}
+	sliceList, err := c.KubeClient.DiscoveryV1().EndpointSlices(service.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	slices := []*discoveryv1.EndpointSlice{}
+	for i := range sliceList.Items {
+		slices = append(slices, &sliceList.Items[i])
+	}
+	return slices, nil
+}
+
+// getServiceEndpointNodes returns the sorted names of the nodes that have
+// ready endpoints for the service. Nil is returned if the service does not
+// use the Local external traffic policy, since any node can then be used.
+func (c *Cloud) getServiceEndpointNodes(service *v1.Service) ([]string, error) {
+	if !isServiceExternalTrafficPolicyLocal(service) {
+		return nil, nil
+	}
+	slices, err := c.listServiceEndpointSlices(service)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list endpoint slices for service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	nodeSet := map[string]bool{}
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			// A nil ready condition is an unknown state which is treated as ready
+			if endpoint.NodeName == nil || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+			nodeSet[*endpoint.NodeName] = true
+		}
+	}
+	nodes := []string{}
+	for node := range nodeSet {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// getEndpointSlice returns the endpoint slice from an informer event object
+func getEndpointSlice(obj interface{}) *discoveryv1.EndpointSlice {
+	slice, isSlice := obj.(*discoveryv1.EndpointSlice)
+	// We can get DeletedFinalStateUnknown instead of *discoveryv1.EndpointSlice
+	// here and we need to handle that correctly.
+	if !isSlice {
+		deletedState, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Received unexpected object: %v", obj)
+			return nil
+		}
+		slice, ok = deletedState.Obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			klog.Errorf("DeletedFinalStateUnknown contained non-EndpointSlice object: %v", deletedState.Obj)
+			return nil
+		}
+	}
+	return slice
+}
+
+// handleEndpointSliceAdd handles the add of an endpoint slice
+func (c *Cloud) handleEndpointSliceAdd(obj interface{}) {
+	c.handleEndpointSliceChange(getEndpointSlice(obj))
+}
+
+// handleEndpointSliceUpdate handles the update of an endpoint slice
+func (c *Cloud) handleEndpointSliceUpdate(oldObj, newObj interface{}) {
+	c.handleEndpointSliceChange(getEndpointSlice(newObj))
+}
+
+// handleEndpointSliceDelete handles the delete of an endpoint slice
+func (c *Cloud) handleEndpointSliceDelete(obj interface{}) {
+	c.handleEndpointSliceChange(getEndpointSlice(obj))
+}
+
+// handleEndpointSliceChange updates the placement of the load balancer
+// deployment for the service of the endpoint slice. Load balancers for
+// services with the Local external traffic policy must only run on nodes
+// that have ready endpoints for the service. The service is read from the
+// informer cache, so the endpoint churn of services that are not Local load
+// balancers does not generate API server requests.
+func (c *Cloud) handleEndpointSliceChange(slice *discoveryv1.EndpointSlice) {
+	if slice == nil || c.serviceLister == nil {
+		return
+	}
+	serviceName := slice.Labels[discoveryv1.LabelServiceName]
+	if serviceName == "" {
+		return
+	}
+	service, err := c.serviceLister.Services(slice.Namespace).Get(serviceName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Warningf("Failed to get service %s/%s for endpoint slice %s: %v", slice.Namespace, serviceName, slice.Name, err)
+		}
+		return
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.Spec.LoadBalancerClass != nil ||
+		!isServiceExternalTrafficPolicyLocal(service) {
+		return
+	}
+	lbName := GetCloudProviderLoadBalancerName(service)
+	deployment, err := c.getLoadBalancerDeployment(lbName)
+	if err != nil {
+		klog.Warningf("Failed to get load balancer deployment %s: %v", lbName, err)
+		return
+	}
+	if deployment == nil {
+		return
+	}
+	c.lbAllocationLock.Lock()
+	defer c.lbAllocationLock.Unlock()
+	lbIP, err := c.getLoadBalancerIP(getLoadBalancerDeploymentIP(deployment), service, nil)
+	if err != nil {
+		klog.Warningf("Failed to get load balancer IP address for %s: %v", lbName, err)
+		return
+	}
+	endpointNodes, err := c.getServiceEndpointNodes(service)
+	if err != nil {
+		klog.Warningf("%v", err)
+		return
+	}
+	virtualRouterID, err := c.getLoadBalancerVirtualRouterID(lbName, lbIP.VlanID, deployment)
+	if err != nil {
+		klog.Warningf("Failed to get load balancer virtual router ID for %s: %v", lbName, err)
+		return
+	}
+	desired := c.generateLoadBalancerDeployment(lbName, lbIP, virtualRouterID, service, endpointNodes)
+	updated, err := c.updateLoadBalancerDeployment(deployment, desired)
+	if err != nil {
+		klog.Warningf("Failed to update load balancer deployment %s: %v", lbName, err)
+		return
+	}
+	if updated {
+		klog.Infof("Load balancer %v placement updated for endpoint nodes: %v", lbName, endpointNodes)
+	}
+}
@@ -0,0 +1,145 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestIndexer(objects ...interface{}) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		_ = indexer.Add(obj)
+	}
+	return indexer
+}
+
+func newTestEndpointSlice(name, serviceName string, readyByNode map[string]*bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: serviceName},
+		},
+	}
+	for node, ready := range readyByNode {
+		nodeName := node
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			NodeName:   &nodeName,
+			Conditions: discoveryv1.EndpointConditions{Ready: ready},
+		})
+	}
+	return slice
+}
+
+func TestGetServiceEndpointNodes(t *testing.T) {
+	ready := true
+	notReady := false
+	slice1 := newTestEndpointSlice("echo-server-1", "echo-server", map[string]*bool{"node2": &ready, "node1": nil})
+	slice2 := newTestEndpointSlice("echo-server-2", "echo-server", map[string]*bool{"node3": &notReady, "node2": &ready})
+	slice3 := newTestEndpointSlice("other-1", "other", map[string]*bool{"node4": &ready})
+	c, _, _ := newTestCloud(slice1, slice2, slice3)
+	service := newTestService("echo-server", "1")
+
+	// Cluster external traffic policy
+	nodes, err := c.getServiceEndpointNodes(service)
+	assert.Nil(t, err)
+	assert.Nil(t, nodes)
+
+	// Local external traffic policy
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	nodes, err = c.getServiceEndpointNodes(service)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node1", "node2"}, nodes)
+
+	// Endpoint slices from the informer cache
+	c.endpointSliceLister = discoverylisters.NewEndpointSliceLister(newTestIndexer(slice2, slice3))
+	nodes, err = c.getServiceEndpointNodes(service)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node2"}, nodes)
+}
+
+func TestHandleEndpointSliceChange(t *testing.T) {
+	ready := true
+	service := newTestService("echo-server", "1")
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	service.Spec.LoadBalancerIP = "192.168.10.20"
+	lbName := GetCloudProviderLoadBalancerName(service)
+	c, kubeClient, _ := newTestCloud(newTestVlanIPConfigMap(t), service)
+	c.serviceLister = corelisters.NewServiceLister(newTestIndexer(service))
+	_, err := c.EnsureLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	deployment, _ := c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, lbDeploymentReplicas, *deployment.Spec.Replicas)
+
+	// Endpoints are added on a single node
+	slice := newTestEndpointSlice("echo-server-1", "echo-server", map[string]*bool{"node1": &ready})
+	_, err = kubeClient.DiscoveryV1().EndpointSlices("default").Create(context.Background(), slice, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	c.handleEndpointSliceAdd(slice)
+	deployment, _ = c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+	expressions := deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	assert.Equal(t, v1.NodeSelectorRequirement{Key: nodeLabelPublicVlan, Operator: v1.NodeSelectorOpIn, Values: []string{"1"}}, expressions[0])
+	assert.Equal(t, v1.NodeSelectorRequirement{Key: v1.LabelHostname, Operator: v1.NodeSelectorOpIn, Values: []string{"node1"}}, expressions[1])
+
+	// Endpoints move to other nodes
+	slice = newTestEndpointSlice("echo-server-1", "echo-server", map[string]*bool{"node2": &ready, "node3": &ready})
+	_, err = kubeClient.DiscoveryV1().EndpointSlices("default").Update(context.Background(), slice, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	c.handleEndpointSliceUpdate(nil, slice)
+	deployment, _ = c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, lbDeploymentReplicas, *deployment.Spec.Replicas)
+	expressions = deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	assert.Equal(t, []string{"node2", "node3"}, expressions[1].Values)
+
+	// Endpoints are deleted, the node restriction is removed
+	err = kubeClient.DiscoveryV1().EndpointSlices("default").Delete(context.Background(), slice.Name, metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	c.handleEndpointSliceDelete(cache.DeletedFinalStateUnknown{Key: "default/echo-server-1", Obj: slice})
+	deployment, _ = c.getLoadBalancerDeployment(lbName)
+	expressions = deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	assert.Len(t, expressions, 1)
+
+	// Unexpected objects and slices for other services are ignored
+	c.handleEndpointSliceDelete("invalid")
+	c.handleEndpointSliceDelete(cache.DeletedFinalStateUnknown{Key: "default/invalid", Obj: "invalid"})
+	c.handleEndpointSliceAdd(newTestEndpointSlice("other-1", "other", map[string]*bool{"node1": &ready}))
+	c.handleEndpointSliceAdd(&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"}})
+}
+
+func TestSetInformers(t *testing.T) {
+	c, kubeClient, _ := newTestCloud()
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	c.SetInformers(informerFactory)
+	assert.True(t, informerFactory.Discovery().V1().EndpointSlices().Informer().GetIndexer() != nil)
+	assert.NotNil(t, c.endpointSliceLister)
+	assert.NotNil(t, c.serviceLister)
+}
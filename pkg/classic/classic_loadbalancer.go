@@ -1,6 +1,6 @@
 /*******************************************************************************
 * IBM Cloud Kubernetes Service, 5737-D43
-* (C) Copyright IBM Corp. 2017, 2023 All Rights Reserved.
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
 *
 * SPDX-License-Identifier: Apache2.0
 *
@@ -22,9 +22,30 @@ package classic
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	creatingCloudLoadBalancerFailed  = "CreatingCloudLoadBalancerFailed"
+	deletingCloudLoadBalancerFailed  = "DeletingCloudLoadBalancerFailed"
+	gettingCloudLoadBalancerFailed   = "GettingCloudLoadBalancerFailed"
+	requestedIPUnavailable           = "RequestedLoadBalancerIPUnavailable"
+	updatingCloudLoadBalancerFailed  = "UpdatingCloudLoadBalancerFailed"
+	verifyingCloudLoadBalancerFailed = "VerifyingCloudLoadBalancerFailed"
+
+	lbStatusHealthy       = "healthy"
+	lbStatusDegraded      = "degraded"
+	lbStatusDuplicateIP   = "duplicate_ip"
+	lbStatusDuplicateVRID = "duplicate_vrid"
+	lbStatusIPNotInConfig = "ip_not_in_config"
+	lbStatusNotFound      = "not_found"
+	lbStatusUnavailable   = "unavailable"
 )
 
 // GetCloudProviderLoadBalancerName is a copy of the original Kubernetes function
@@ -38,30 +59,359 @@ func GetCloudProviderLoadBalancerName(service *v1.Service) string {
 	return ret
 }
 
+// getLoadBalancerStatus returns the load balancer status for the cloud provider IP
+func getLoadBalancerStatus(lbIP string) *v1.LoadBalancerStatus {
+	return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: lbIP}}}
+}
+
+// getServiceLoadBalancerIP returns the cloud provider IP address that was
+// previously assigned to the service, if any.
+func getServiceLoadBalancerIP(service *v1.Service) string {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+	}
+	return ""
+}
+
 // GetLoadBalancer returns whether the specified load balancer exists, and
 // if so, what its status is.
 func (c *Cloud) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
-	return nil, false, errors.New("cloud provider does not support GetLoadBalancer interface")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	deployment, err := c.getLoadBalancerDeployment(lbName)
+	if err != nil {
+		errString := fmt.Sprintf("Failed getting LoadBalancer deployment: %v", err)
+		klog.Errorf("%s", errString)
+		return nil, false, c.recordServiceWarningEvent(service, gettingCloudLoadBalancerFailed, lbName, errString)
+	}
+	if deployment == nil {
+		klog.Infof("Load balancer %v not found", lbName)
+		return nil, false, nil
+	}
+	return getLoadBalancerStatus(getLoadBalancerDeploymentIP(deployment)), true, nil
 }
 
 // EnsureLoadBalancer creates a new load balancer 'name', or updates the existing one. Returns the status of the balancer
 func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
-	return nil, errors.New("cloud provider does not support EnsureLoadBalancer interface")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	klog.Infof("EnsureLoadBalancer(lbName:%v, Service:%v/%v, NodeCount:%v)", lbName, service.Namespace, service.Name, len(nodes))
+
+	// Check to see if the load balancer deployment exists
+	deployment, err := c.getLoadBalancerDeployment(lbName)
+	if err != nil {
+		errString := fmt.Sprintf("Failed getting LoadBalancer deployment: %v", err)
+		klog.Errorf("%s", errString)
+		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
+	}
+
+	// The IP address and virtual router ID must not be allocated to another
+	// load balancer before this deployment is created
+	c.lbAllocationLock.Lock()
+	defer c.lbAllocationLock.Unlock()
+
+	// Determine the cloud provider IP address of the load balancer. An existing
+	// load balancer keeps the IP address that it was assigned, unless the service
+	// requests a different IP. Otherwise a free IP address is allocated from the
+	// VLAN IP config map.
+	lbIP := getLoadBalancerDeploymentIP(deployment)
+	if lbIP == "" {
+		lbIP = getServiceLoadBalancerIP(service)
+	}
+	if requestedIP := service.Spec.LoadBalancerIP; requestedIP != "" && lbIP != "" && requestedIP != lbIP {
+		klog.Infof("Load balancer %v IP %v differs from the requested IP %v, moving the load balancer to the requested IP", lbName, lbIP, requestedIP)
+		lbIP = ""
+	}
+	cloudIP, err := c.getLoadBalancerIP(lbIP, service, nodes)
+	if err != nil {
+		reason := creatingCloudLoadBalancerFailed
+		if errors.Is(err, errRequestedIPUnavailable) {
+			reason = requestedIPUnavailable
+		}
+		errString := fmt.Sprintf("Failed getting LoadBalancer IP address: %v", err)
+		klog.Errorf("%s", errString)
+		return nil, c.recordServiceWarningEvent(service, reason, lbName, errString)
+	}
+	lbIP = cloudIP.IP
+
+	// Create or update the load balancer deployment
+	endpointNodes, err := c.getServiceEndpointNodes(service)
+	if err != nil {
+		klog.Errorf("%v", err)
+		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, err.Error())
+	}
+	virtualRouterID, err := c.getLoadBalancerVirtualRouterID(lbName, cloudIP.VlanID, deployment)
+	if err != nil {
+		errString := fmt.Sprintf("Failed getting LoadBalancer virtual router ID: %v", err)
+		klog.Errorf("%s", errString)
+		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
+	}
+	desired := c.generateLoadBalancerDeployment(lbName, cloudIP, virtualRouterID, service, endpointNodes)
+	if deployment == nil {
+		err = c.createLoadBalancerDeployment(desired)
+		if err != nil {
+			errString := fmt.Sprintf("Failed creating LoadBalancer deployment: %v", err)
+			klog.Errorf("%s", errString)
+			return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
+		}
+		klog.Infof("Load balancer %v created with IP %v", lbName, lbIP)
+	} else {
+		updated, err := c.updateLoadBalancerDeployment(deployment, desired)
+		if err != nil {
+			errString := fmt.Sprintf("Failed updating LoadBalancer deployment: %v", err)
+			klog.Errorf("%s", errString)
+			return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
+		}
+		if updated {
+			klog.Infof("Load balancer %v updated with IP %v", lbName, lbIP)
+		}
+	}
+
+	// Allow the load balancer traffic through the Calico host endpoint policies
+	err = c.ensureCalicoPolicy(lbName, lbIP, service)
+	if err != nil {
+		errString := fmt.Sprintf("Failed applying Calico policy for LoadBalancer: %v", err)
+		klog.Errorf("%s", errString)
+		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
+	}
+	return getLoadBalancerStatus(lbIP), nil
 }
 
 // UpdateLoadBalancer updates hosts under the specified load balancer.
 func (c *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
-	return errors.New("cloud provider does not support UpdateLoadBalancer interface")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	klog.Infof("UpdateLoadBalancer(lbName:%v, Service:%v/%v, NodeCount:%v)", lbName, service.Namespace, service.Name, len(nodes))
+
+	deployment, err := c.getLoadBalancerDeployment(lbName)
+	if err != nil {
+		errString := fmt.Sprintf("Failed getting LoadBalancer deployment: %v", err)
+		klog.Errorf("%s", errString)
+		return c.recordServiceWarningEvent(service, updatingCloudLoadBalancerFailed, lbName, errString)
+	}
+	if deployment == nil {
+		klog.Warningf("Load balancer not found: %v", lbName)
+		return nil
+	}
+
+	c.lbAllocationLock.Lock()
+	defer c.lbAllocationLock.Unlock()
+	cloudIP, err := c.getLoadBalancerIP(getLoadBalancerDeploymentIP(deployment), service, nodes)
+	if err != nil {
+		reason := updatingCloudLoadBalancerFailed
+		if errors.Is(err, errRequestedIPUnavailable) {
+			reason = requestedIPUnavailable
+		}
+		errString := fmt.Sprintf("Failed getting LoadBalancer IP address: %v", err)
+		klog.Errorf("%s", errString)
+		return c.recordServiceWarningEvent(service, reason, lbName, errString)
+	}
+	endpointNodes, err := c.getServiceEndpointNodes(service)
+	if err != nil {
+		klog.Errorf("%v", err)
+		return c.recordServiceWarningEvent(service, updatingCloudLoadBalancerFailed, lbName, err.Error())
+	}
+	virtualRouterID, err := c.getLoadBalancerVirtualRouterID(lbName, cloudIP.VlanID, deployment)
+	if err != nil {
+		errString := fmt.Sprintf("Failed getting LoadBalancer virtual router ID: %v", err)
+		klog.Errorf("%s", errString)
+		return c.recordServiceWarningEvent(service, updatingCloudLoadBalancerFailed, lbName, errString)
+	}
+	desired := c.generateLoadBalancerDeployment(lbName, cloudIP, virtualRouterID, service, endpointNodes)
+	_, err = c.updateLoadBalancerDeployment(deployment, desired)
+	if err != nil {
+		errString := fmt.Sprintf("Failed updating LoadBalancer deployment: %v", err)
+		klog.Errorf("%s", errString)
+		return c.recordServiceWarningEvent(service, updatingCloudLoadBalancerFailed, lbName, errString)
+	}
+	// Repair a Calico policy that was changed or deleted. The update of the load balancer is not
+	// failed by the policy, the next update or ensure of the load balancer applies it again
+	err = c.ensureCalicoPolicy(lbName, cloudIP.IP, service)
+	if err != nil {
+		errString := fmt.Sprintf("Failed applying Calico policy for LoadBalancer: %v", err)
+		klog.Warningf("%s", errString)
+		_ = c.recordServiceWarningEvent(service, updatingCloudLoadBalancerFailed, lbName, errString) // #nosec G104 error is always returned
+	}
+	return nil
 }
 
 // EnsureLoadBalancerDeleted deletes the specified load balancer if it
 // exists, returning nil if the load balancer specified either didn't exist or
 // was successfully deleted.
 func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
-	return errors.New("cloud provider does not support EnsureLoadBalancerDeleted interface")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	klog.Infof("EnsureLoadBalancerDeleted(lbName:%v, Service:%v/%v)", lbName, service.Namespace, service.Name)
+
+	err := c.deleteLoadBalancerDeployment(lbName)
+	if err != nil {
+		errString := fmt.Sprintf("Failed deleting LoadBalancer deployment: %v", err)
+		klog.Errorf("%s", errString)
+		return c.recordServiceWarningEvent(service, deletingCloudLoadBalancerFailed, lbName, errString)
+	}
+	// The delete of the load balancer is not blocked by the cleanup of the Calico policy,
+	// the policy only allows traffic to an IP that is no longer used by the load balancer
+	err = c.deleteCalicoPolicy(lbName)
+	if err != nil {
+		errString := fmt.Sprintf("Failed deleting Calico policy for LoadBalancer: %v", err)
+		klog.Warningf("%s", errString)
+		_ = c.recordServiceWarningEvent(service, deletingCloudLoadBalancerFailed, lbName, errString) // #nosec G104 error is always returned
+	}
+	klog.Infof("Load balancer %v deleted", lbName)
+	return nil
+}
+
+// getEventMessage returns the event message for the load balancer status
+func getEventMessage(status, lbIP string) string {
+	switch status {
+	case lbStatusHealthy:
+		return fmt.Sprintf("The load balancer deployment for IP %s that routes requests to this Kubernetes LoadBalancer service is available.", lbIP)
+	case lbStatusDegraded:
+		return fmt.Sprintf("The load balancer deployment for IP %s that routes requests to this Kubernetes LoadBalancer service does not have all replicas available.", lbIP)
+	case lbStatusDuplicateIP:
+		return fmt.Sprintf("The load balancer IP %s is assigned to more than one Kubernetes LoadBalancer service.", lbIP)
+	case lbStatusDuplicateVRID:
+		return fmt.Sprintf("The load balancer deployment for IP %s uses a VRRP virtual router ID that is assigned to another load balancer deployment on the same VLAN. Delete and re-create the service to allocate a new ID.", lbIP)
+	case lbStatusIPNotInConfig:
+		return fmt.Sprintf("The load balancer IP %s is no longer in the VLAN IP config map and can not be used by the cluster.", lbIP)
+	case lbStatusNotFound:
+		return "The load balancer deployment that routes requests to this Kubernetes LoadBalancer service was not found."
+	case lbStatusUnavailable:
+		return fmt.Sprintf("The load balancer deployment for IP %s that routes requests to this Kubernetes LoadBalancer service has no replicas available.", lbIP)
+	default:
+		return fmt.Sprintf("The load balancer deployment for IP %s that routes requests to this Kubernetes LoadBalancer service is currently %s.", lbIP, status)
+	}
+}
+
+// getLoadBalancerDeploymentStatus returns the status of the load balancer
+// deployment based on the available replicas.
+func getLoadBalancerDeploymentStatus(deployment *appsv1.Deployment) string {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch {
+	case deployment.Status.AvailableReplicas == 0:
+		return lbStatusUnavailable
+	case deployment.Status.AvailableReplicas < replicas:
+		return lbStatusDegraded
+	}
+	return lbStatusHealthy
 }
 
 // MonitorLoadBalancers monitors load balancer services to ensure that they
-// are working properly.
+// are working properly. The load balancer deployment must be available, its
+// IP must still be in the VLAN IP config map, its IP must not be assigned to
+// another load balancer, and its VRRP virtual router ID must not be assigned
+// to another load balancer on the same VLAN. A warning event is generated for each service that
+// fails two consecutive monitors and a normal event is generated when a
+// service becomes healthy. The status is kept in the data map by service UID.
 func (c *Cloud) MonitorLoadBalancers(services *v1.ServiceList, data map[string]string) {
+	// Verify we were passed a list of Kube services
+	if services == nil {
+		klog.Infof("%s", "No Load Balancers to monitor, returning")
+		return
+	}
+
+	// The IP check is skipped if the VLAN IP config map can not be read
+	config, err := c.getCloudProviderVlanIPConfig()
+	if err != nil {
+		klog.Warningf("Failed to get VLAN IP config: %v", err)
+	}
+
+	// Retrieve the load balancer deployments and track the IP and virtual router ID assignments.
+	// A failure to get one deployment does not stop the monitor of the other load balancers.
+	deployments := map[string]*appsv1.Deployment{}
+	getFailed := map[string]bool{}
+	getErrors := []string{}
+	ipCount := map[string]int{}
+	vridCount := map[string]int{}
+	for i := range services.Items {
+		service := &services.Items[i]
+		lbName := GetCloudProviderLoadBalancerName(service)
+		deployment, err := c.getLoadBalancerDeployment(lbName)
+		if err != nil {
+			getFailed[lbName] = true
+			getErrors = append(getErrors, fmt.Sprintf("%s: %v", lbName, err))
+			continue
+		}
+		if deployment != nil {
+			deployments[lbName] = deployment
+			ipCount[getLoadBalancerDeploymentIP(deployment)]++
+			vridCount[getLoadBalancerDeploymentVlanVirtualRouterID(deployment)]++
+		}
+	}
+
+	if len(getErrors) > 0 {
+		klog.Errorf("Failed getting LoadBalancer deployments: %s", strings.Join(getErrors, "; "))
+	}
+
+	for i := range services.Items {
+		service := &services.Items[i]
+		lbName := GetCloudProviderLoadBalancerName(service)
+		// The status is unknown if the deployment could not be retrieved, the previous status is kept
+		if getFailed[lbName] {
+			continue
+		}
+		serviceID := string(service.ObjectMeta.UID)
+		oldStatus := data[serviceID]
+		deployment := deployments[lbName]
+		lbIP := getLoadBalancerDeploymentIP(deployment)
+
+		var newStatus string
+		switch {
+		case deployment == nil:
+			newStatus = lbStatusNotFound
+		case config != nil && config.findIP(lbIP) == nil:
+			newStatus = lbStatusIPNotInConfig
+		case ipCount[lbIP] > 1:
+			newStatus = lbStatusDuplicateIP
+		case vridCount[getLoadBalancerDeploymentVlanVirtualRouterID(deployment)] > 1:
+			newStatus = lbStatusDuplicateVRID
+		default:
+			newStatus = getLoadBalancerDeploymentStatus(deployment)
+		}
+
+		// Store the new status so its available to the next call to MonitorLoadBalancers()
+		data[serviceID] = newStatus
+		if newStatus == lbStatusHealthy {
+			klog.Infof("Load balancer %s IP %s status %s Service:%s/%s", lbName, lbIP, newStatus, service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+			if oldStatus != lbStatusHealthy {
+				c.recordServiceNormalEvent(service, lbName, getEventMessage(newStatus, lbIP))
+			}
+			continue
+		}
+		klog.Warningf("Load balancer %s IP %s status %s Service:%s/%s", lbName, lbIP, newStatus, service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+		// Record a warning event if the previous monitor found the same failure, so that
+		// only a failure in two consecutive monitors generates a warning event
+		if oldStatus == newStatus {
+			_ = c.recordServiceWarningEvent(
+				service, verifyingCloudLoadBalancerFailed, lbName, getEventMessage(newStatus, lbIP)) // #nosec G104 error is always returned
+		}
+	}
+}
+
+// getLoadBalancerDeploymentVlanVirtualRouterID returns the VLAN and the VRRP
+// virtual router ID of the load balancer deployment in the format <vlan>/<id>.
+// The virtual router IDs only need to be unique per VLAN.
+func getLoadBalancerDeploymentVlanVirtualRouterID(deployment *appsv1.Deployment) string {
+	return fmt.Sprintf("%s/%d", getLoadBalancerDeploymentVlan(deployment), getLoadBalancerDeploymentVirtualRouterID(deployment))
+}
+
+// recordServiceNormalEvent logs a load balancer service event
+func (c *Cloud) recordServiceNormalEvent(lbService *v1.Service, lbName, eventMessage string) {
+	if c.Recorder != nil {
+		message := fmt.Sprintf("Event on cloud load balancer %v for service %v with UID %v: %v",
+			lbName, types.NamespacedName{Namespace: lbService.ObjectMeta.Namespace, Name: lbService.ObjectMeta.Name}, lbService.ObjectMeta.UID, eventMessage)
+		c.Recorder.Event(lbService, v1.EventTypeNormal, "CloudLoadBalancerNormalEvent", message)
+	}
+}
+
+// recordServiceWarningEvent logs a load balancer service warning
+// event and returns an error representing the event.
+func (c *Cloud) recordServiceWarningEvent(lbService *v1.Service, reason, lbName, errorMessage string) error {
+	message := fmt.Sprintf("Error on cloud load balancer %v for service %v with UID %v: %v",
+		lbName, types.NamespacedName{Namespace: lbService.ObjectMeta.Namespace, Name: lbService.ObjectMeta.Name}, lbService.ObjectMeta.UID, errorMessage)
+	if c.Recorder != nil {
+		c.Recorder.Event(lbService, v1.EventTypeWarning, reason, message)
+	}
+	return errors.New(message)
 }
@@ -0,0 +1,389 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	lbDeploymentNamespace      = "ibm-system"
+	lbDeploymentPriorityClass  = "ibm-app-cluster-critical"
+	lbDeploymentReplicas       = int32(2)
+	lbDeploymentServiceAccount = "ibm-cloud-provider-lb"
+
+	lbAnnotationIP              = "ibm-cloud-provider-lb-ip"
+	lbAnnotationServiceName     = "ibm-cloud-provider-lb-service"
+	lbAnnotationSpecHash        = "ibm-cloud-provider-lb-spec-hash"
+	lbAnnotationVirtualRouterID = "ibm-cloud-provider-lb-vrid"
+	lbAnnotationVlan            = "ibm-cloud-provider-lb-vlan"
+	lbLabelApp                  = "app"
+	lbLabelName                 = "ibm-cloud-provider-lb-name"
+
+	lbEnvVirtualIP       = "VIRTUAL_IP"
+	lbEnvVirtualRouterID = "VIRTUAL_ROUTER_ID"
+	lbEnvServicePorts    = "SERVICE_PORTS"
+	lbEnvVersion         = "LB_VERSION"
+	lbEnvIPVSScheduler   = "IPVS_SCHEDULER"
+
+	lbVirtualRouterIDMax = 255
+
+	nodeLabelDedicated = "dedicated"
+	nodeLabelValueEdge = "edge"
+)
+
+// getLoadBalancerDeployment returns the load balancer deployment for the
+// specified load balancer name, or nil if the deployment does not exist.
+func (c *Cloud) getLoadBalancerDeployment(lbName string) (*appsv1.Deployment, error) {
+	deployment, err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).Get(context.TODO(), lbName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// getLoadBalancerDeploymentIP returns the cloud provider IP address that is
+// assigned to the load balancer deployment.
+func getLoadBalancerDeploymentIP(deployment *appsv1.Deployment) string {
+	if deployment == nil {
+		return ""
+	}
+	return deployment.Annotations[lbAnnotationIP]
+}
+
+// getLoadBalancerDeploymentVirtualRouterID returns the VRRP virtual router ID
+// assigned to the load balancer deployment, or 0 if no valid ID is assigned.
+// Deployments created before the ID was stored in an annotation only have it
+// in the keepalived environment.
+func getLoadBalancerDeploymentVirtualRouterID(deployment *appsv1.Deployment) int {
+	if deployment == nil {
+		return 0
+	}
+	value := deployment.Annotations[lbAnnotationVirtualRouterID]
+	if value == "" {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == lbEnvVirtualRouterID {
+					value = env.Value
+				}
+			}
+		}
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 || id > lbVirtualRouterIDMax {
+		return 0
+	}
+	return id
+}
+
+// getLoadBalancerDeploymentVlan returns the VLAN of the cloud provider IP
+// address of the load balancer deployment, or "" if the VLAN is not known.
+// Deployments created before the VLAN was stored in an annotation only have it
+// in the required node affinity.
+func getLoadBalancerDeploymentVlan(deployment *appsv1.Deployment) string {
+	if deployment == nil {
+		return ""
+	}
+	if vlanID := deployment.Annotations[lbAnnotationVlan]; vlanID != "" {
+		return vlanID
+	}
+	affinity := deployment.Spec.Template.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if (expression.Key == nodeLabelPublicVlan || expression.Key == nodeLabelPrivateVlan) && len(expression.Values) == 1 {
+				return expression.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// getLoadBalancerVirtualRouterID returns the VRRP virtual router ID (1-255)
+// used by keepalived for the load balancer. The ID stored in the annotation of
+// an existing deployment is kept so that it stays stable. Otherwise the first ID
+// not used by another load balancer deployment on the same VLAN is allocated,
+// starting at a hash of the load balancer name. VRRP advertisements do not
+// leave the VLAN, so the IDs only need to be unique per VLAN. A deployment on
+// an unknown VLAN may share a VLAN with any other load balancer. The caller
+// must hold the lbAllocationLock until the deployment with the ID is created
+// or updated, otherwise a concurrent allocation can return the same ID.
+func (c *Cloud) getLoadBalancerVirtualRouterID(lbName, vlanID string, existing *appsv1.Deployment) (int, error) {
+	if existing != nil && existing.Annotations[lbAnnotationVirtualRouterID] != "" {
+		if id := getLoadBalancerDeploymentVirtualRouterID(existing); id > 0 {
+			return id, nil
+		}
+	}
+	deployments, err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: lbLabelName})
+	if err != nil {
+		return 0, err
+	}
+	inUse := map[int]bool{}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deployment.Name == lbName {
+			continue
+		}
+		if otherVlanID := getLoadBalancerDeploymentVlan(deployment); vlanID == "" || otherVlanID == "" || otherVlanID == vlanID {
+			inUse[getLoadBalancerDeploymentVirtualRouterID(deployment)] = true
+		}
+	}
+	// An existing deployment without the annotation keeps the ID from its
+	// environment unless another load balancer uses the same ID.
+	if id := getLoadBalancerDeploymentVirtualRouterID(existing); id > 0 && !inUse[id] {
+		return id, nil
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(lbName)) // #nosec G104 hash writes never fail
+	start := int(h.Sum32() % lbVirtualRouterIDMax)
+	for i := 0; i < lbVirtualRouterIDMax; i++ {
+		id := (start+i)%lbVirtualRouterIDMax + 1
+		if !inUse[id] {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("No free VRRP virtual router ID for load balancer %s on VLAN %s, all %d IDs are in use", lbName, vlanID, lbVirtualRouterIDMax)
+}
+
+// getLoadBalancerServicePorts returns the service ports in the format
+// <protocol>:<port>:<nodePort>, sorted and comma separated.
+func getLoadBalancerServicePorts(service *v1.Service) string {
+	ports := []string{}
+	for _, port := range service.Spec.Ports {
+		ports = append(ports, fmt.Sprintf("%s:%d:%d", strings.ToLower(string(port.Protocol)), port.Port, port.NodePort))
+	}
+	sort.Strings(ports)
+	return strings.Join(ports, ",")
+}
+
+// getLoadBalancerNodeAffinity returns the node affinity for the load balancer
+// deployment. Edge nodes are preferred and, if the VLAN of the cloud provider
+// IP address is known, the pods are required to run on nodes on that VLAN.
+// If endpoint nodes are specified, the pods are also required to run on them.
+func getLoadBalancerNodeAffinity(lbIP *cloudProviderReservedIP, endpointNodes []string) *v1.NodeAffinity {
+	nodeAffinity := &v1.NodeAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{{
+			Weight: 100,
+			Preference: v1.NodeSelectorTerm{
+				MatchExpressions: []v1.NodeSelectorRequirement{{
+					Key:      nodeLabelDedicated,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{nodeLabelValueEdge},
+				}},
+			},
+		}},
+	}
+	requirements := []v1.NodeSelectorRequirement{}
+	if lbIP.VlanID != "" {
+		vlanLabel := nodeLabelPrivateVlan
+		if lbIP.IsPublic {
+			vlanLabel = nodeLabelPublicVlan
+		}
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      vlanLabel,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{lbIP.VlanID},
+		})
+	}
+	if len(endpointNodes) > 0 {
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      v1.LabelHostname,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   endpointNodes,
+		})
+	}
+	if len(requirements) > 0 {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: requirements}},
+		}
+	}
+	return nodeAffinity
+}
+
+// generateLoadBalancerDeployment returns the load balancer deployment for the
+// service. The deployment runs keepalived on the host network of the cluster
+// nodes in order to host the cloud provider IP address of the load balancer.
+// The endpoint nodes restrict where the deployment runs, see getServiceEndpointNodes.
+// The virtual router ID is allocated by getLoadBalancerVirtualRouterID.
+func (c *Cloud) generateLoadBalancerDeployment(lbName string, lbIP *cloudProviderReservedIP, virtualRouterID int, service *v1.Service, endpointNodes []string) *appsv1.Deployment {
+	replicas := lbDeploymentReplicas
+	// Only one pod per node can run, see the pod anti-affinity
+	if len(endpointNodes) > 0 && int32(len(endpointNodes)) < replicas {
+		replicas = int32(len(endpointNodes))
+	}
+	maxUnavailable := intstr.FromInt(1)
+	maxSurge := intstr.FromInt(0)
+	privileged := false
+	labels := map[string]string{
+		lbLabelApp:  c.Config.Application,
+		lbLabelName: lbName,
+	}
+	env := []v1.EnvVar{
+		{Name: lbEnvVirtualIP, Value: lbIP.IP},
+		{Name: lbEnvVirtualRouterID, Value: strconv.Itoa(virtualRouterID)},
+		{Name: lbEnvServicePorts, Value: getLoadBalancerServicePorts(service)},
+		{Name: lbEnvVersion, Value: getLoadBalancerVersion(service)},
+	}
+	// Version 2.0 load balancers configure IPVS virtual servers for the
+	// service ports rather than forwarding to the node ports with iptables.
+	if getLoadBalancerVersion(service) == lbVersion2 {
+		env = append(env, v1.EnvVar{Name: lbEnvIPVSScheduler, Value: getIPVSScheduler(service)})
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      lbName,
+			Namespace: lbDeploymentNamespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				lbAnnotationIP:              lbIP.IP,
+				lbAnnotationServiceName:     service.Namespace + "/" + service.Name,
+				lbAnnotationVirtualRouterID: strconv.Itoa(virtualRouterID),
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{lbLabelName: lbName}},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					HostNetwork:        true,
+					PriorityClassName:  lbDeploymentPriorityClass,
+					ServiceAccountName: lbDeploymentServiceAccount,
+					Affinity: &v1.Affinity{
+						NodeAffinity: getLoadBalancerNodeAffinity(lbIP, endpointNodes),
+						PodAntiAffinity: &v1.PodAntiAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{
+								LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{lbLabelName: lbName}},
+								TopologyKey:   v1.LabelHostname,
+							}},
+						},
+					},
+					Tolerations: []v1.Toleration{{
+						Key:      nodeLabelDedicated,
+						Operator: v1.TolerationOpEqual,
+						Value:    nodeLabelValueEdge,
+					}},
+					Containers: []v1.Container{{
+						Name:            c.Config.Application,
+						Image:           c.Config.Image,
+						ImagePullPolicy: v1.PullIfNotPresent,
+						Env:             env,
+						Resources: v1.ResourceRequirements{
+							Requests: v1.ResourceList{
+								v1.ResourceCPU:    resource.MustParse("5m"),
+								v1.ResourceMemory: resource.MustParse("10Mi"),
+							},
+						},
+						SecurityContext: &v1.SecurityContext{
+							Privileged: &privileged,
+							Capabilities: &v1.Capabilities{
+								Add: []v1.Capability{"NET_ADMIN", "NET_RAW"},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if lbIP.VlanID != "" {
+		deployment.Annotations[lbAnnotationVlan] = lbIP.VlanID
+	}
+	deployment.Annotations[lbAnnotationSpecHash] = getLoadBalancerDeploymentSpecHash(&deployment.Spec)
+	return deployment
+}
+
+// getLoadBalancerDeploymentSpecHash returns a hash of the generated deployment
+// spec. The hash is used to detect changes since the spec read back from the
+// cluster also contains defaulted fields.
+func getLoadBalancerDeploymentSpecHash(spec *appsv1.DeploymentSpec) string {
+	data, _ := json.Marshal(spec) // #nosec G104 the spec can always be marshalled
+	h := fnv.New32a()
+	_, _ = h.Write(data) // #nosec G104 hash writes never fail
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// createLoadBalancerDeployment creates the load balancer deployment
+func (c *Cloud) createLoadBalancerDeployment(deployment *appsv1.Deployment) error {
+	_, err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	return err
+}
+
+// updateLoadBalancerDeployment updates the existing load balancer deployment
+// if it differs from the desired deployment. Returns true if an update was done.
+func (c *Cloud) updateLoadBalancerDeployment(existing, desired *appsv1.Deployment) (bool, error) {
+	if equality.Semantic.DeepDerivative(desired.Labels, existing.Labels) &&
+		equality.Semantic.DeepDerivative(desired.Annotations, existing.Annotations) {
+		return false, nil
+	}
+	updated := existing.DeepCopy()
+	updated.Spec = desired.Spec
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		updated.Labels[k] = v
+	}
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Annotations {
+		updated.Annotations[k] = v
+	}
+	_, err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).Update(context.TODO(), updated, metav1.UpdateOptions{})
+	return err == nil, err
+}
+
+// deleteLoadBalancerDeployment deletes the load balancer deployment. No
+// error is returned if the deployment does not exist.
+func (c *Cloud) deleteLoadBalancerDeployment(lbName string) error {
+	propagation := metav1.DeletePropagationForeground
+	err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).Delete(context.TODO(), lbName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
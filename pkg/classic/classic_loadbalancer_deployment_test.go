@@ -0,0 +1,218 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGenerateLoadBalancerDeployment(t *testing.T) {
+	c, _, _ := newTestCloud()
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	lbIP := &cloudProviderReservedIP{IP: "192.168.10.20", SubnetID: "11", VlanID: "1", IsPublic: true}
+
+	deployment := c.generateLoadBalancerDeployment(lbName, lbIP, 10, service, nil)
+	assert.Equal(t, lbName, deployment.Name)
+	assert.Equal(t, lbDeploymentNamespace, deployment.Namespace)
+	assert.Equal(t, "192.168.10.20", deployment.Annotations[lbAnnotationIP])
+	assert.Equal(t, "default/echo-server", deployment.Annotations[lbAnnotationServiceName])
+	assert.Equal(t, "10", deployment.Annotations[lbAnnotationVirtualRouterID])
+	assert.Equal(t, lbName, deployment.Spec.Selector.MatchLabels[lbLabelName])
+	assert.Equal(t, "keepalived", deployment.Spec.Template.Labels[lbLabelApp])
+	assert.Equal(t, lbDeploymentReplicas, *deployment.Spec.Replicas)
+	assert.True(t, deployment.Spec.Template.Spec.HostNetwork)
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, c.Config.Image, container.Image)
+	assert.Equal(t, v1.EnvVar{Name: lbEnvVirtualIP, Value: "192.168.10.20"}, container.Env[0])
+	assert.Equal(t, v1.EnvVar{Name: lbEnvVirtualRouterID, Value: "10"}, container.Env[1])
+	assert.Equal(t, v1.EnvVar{Name: lbEnvServicePorts, Value: "tcp:80:30080"}, container.Env[2])
+	assert.Equal(t, v1.EnvVar{Name: lbEnvVersion, Value: lbVersion1}, container.Env[3])
+	assert.Len(t, container.Env, 4)
+
+	// Version 2.0 load balancer
+	service.Annotations = map[string]string{
+		serviceAnnotationEnableFeatures: lbFeatureIPVS,
+		serviceAnnotationIPVSScheduler:  ipvsSchedulerSourceHashing,
+	}
+	deployment = c.generateLoadBalancerDeployment(lbName, lbIP, 10, service, nil)
+	container = deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, v1.EnvVar{Name: lbEnvVersion, Value: lbVersion2}, container.Env[3])
+	assert.Equal(t, v1.EnvVar{Name: lbEnvIPVSScheduler, Value: ipvsSchedulerSourceHashing}, container.Env[4])
+}
+
+func TestGetLoadBalancerNodeAffinity(t *testing.T) {
+	// VLAN not known, only edge nodes are preferred
+	nodeAffinity := getLoadBalancerNodeAffinity(&cloudProviderReservedIP{IP: "192.168.10.20"}, nil)
+	assert.Nil(t, nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+	assert.Equal(t, nodeLabelDedicated, nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Preference.MatchExpressions[0].Key)
+
+	// Public VLAN
+	nodeAffinity = getLoadBalancerNodeAffinity(&cloudProviderReservedIP{IP: "192.168.10.20", VlanID: "1", IsPublic: true}, nil)
+	expression := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0]
+	assert.Equal(t, nodeLabelPublicVlan, expression.Key)
+	assert.Equal(t, []string{"1"}, expression.Values)
+
+	// Private VLAN
+	nodeAffinity = getLoadBalancerNodeAffinity(&cloudProviderReservedIP{IP: "10.10.10.20", VlanID: "2"}, nil)
+	expression = nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0]
+	assert.Equal(t, nodeLabelPrivateVlan, expression.Key)
+	assert.Equal(t, []string{"2"}, expression.Values)
+
+	// Endpoint nodes
+	nodeAffinity = getLoadBalancerNodeAffinity(&cloudProviderReservedIP{IP: "192.168.10.20"}, []string{"node1", "node2"})
+	expression = nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0]
+	assert.Equal(t, v1.LabelHostname, expression.Key)
+	assert.Equal(t, []string{"node1", "node2"}, expression.Values)
+}
+
+func TestGetLoadBalancerVirtualRouterID(t *testing.T) {
+	c, kubeClient, _ := newTestCloud()
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	lbIP := &cloudProviderReservedIP{IP: "192.168.10.20", VlanID: "1", IsPublic: true}
+
+	// New load balancer is allocated an ID in the valid range
+	id, err := c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, id, 1)
+	assert.LessOrEqual(t, id, lbVirtualRouterIDMax)
+
+	// ID used by another load balancer is skipped
+	other := c.generateLoadBalancerDeployment("other", lbIP, id, service, nil)
+	assert.Nil(t, c.createLoadBalancerDeployment(other))
+	next, err := c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, id, next)
+	assert.Equal(t, id%lbVirtualRouterIDMax+1, next)
+
+	// ID used by a load balancer on another VLAN can be allocated
+	otherVlan := c.generateLoadBalancerDeployment("other-vlan", &cloudProviderReservedIP{IP: "10.10.10.20", VlanID: "2"}, next, service, nil)
+	assert.Nil(t, c.createLoadBalancerDeployment(otherVlan))
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, next, id)
+
+	// ID used by a load balancer on an unknown VLAN is skipped, as is any ID if the VLAN is not known
+	unknownVlan := c.generateLoadBalancerDeployment("unknown-vlan", &cloudProviderReservedIP{IP: "10.10.10.21"}, next, service, nil)
+	assert.Nil(t, c.createLoadBalancerDeployment(unknownVlan))
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, next%lbVirtualRouterIDMax+1, id)
+	assert.Nil(t, c.deleteLoadBalancerDeployment("unknown-vlan"))
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, next%lbVirtualRouterIDMax+1, id)
+
+	// Existing load balancer keeps the ID in its annotation
+	existing := c.generateLoadBalancerDeployment(lbName, lbIP, 20, service, nil)
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "1", existing)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, id)
+
+	// Existing load balancer without the annotation keeps the ID in its environment unless it is in use
+	delete(existing.Annotations, lbAnnotationVirtualRouterID)
+	assert.Equal(t, 20, getLoadBalancerDeploymentVirtualRouterID(existing))
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "1", existing)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, id)
+	existing.Spec.Template.Spec.Containers[0].Env[1].Value = other.Annotations[lbAnnotationVirtualRouterID]
+	id, err = c.getLoadBalancerVirtualRouterID(lbName, "1", existing)
+	assert.Nil(t, err)
+	assert.Equal(t, next, id)
+
+	// All IDs are in use
+	for i := 1; i <= lbVirtualRouterIDMax; i++ {
+		_ = c.createLoadBalancerDeployment(c.generateLoadBalancerDeployment(fmt.Sprintf("lb%d", i), lbIP, i, service, nil))
+	}
+	_, err = c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "No free VRRP virtual router ID")
+
+	// Failed to list the load balancer deployments
+	kubeClient.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("list failed")
+	})
+	_, err = c.getLoadBalancerVirtualRouterID(lbName, "1", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "list failed")
+}
+
+func TestGetLoadBalancerDeploymentVlan(t *testing.T) {
+	c, _, _ := newTestCloud()
+	service := newTestService("echo-server", "1")
+
+	assert.Equal(t, "", getLoadBalancerDeploymentVlan(nil))
+
+	// VLAN in the annotation
+	deployment := c.generateLoadBalancerDeployment("lb", &cloudProviderReservedIP{IP: "192.168.10.20", VlanID: "1", IsPublic: true}, 1, service, nil)
+	assert.Equal(t, "1", deployment.Annotations[lbAnnotationVlan])
+	assert.Equal(t, "1", getLoadBalancerDeploymentVlan(deployment))
+
+	// VLAN in the node affinity of an older deployment
+	delete(deployment.Annotations, lbAnnotationVlan)
+	assert.Equal(t, "1", getLoadBalancerDeploymentVlan(deployment))
+
+	// VLAN not known
+	deployment = c.generateLoadBalancerDeployment("lb", &cloudProviderReservedIP{IP: "192.168.10.20"}, 1, service, []string{"node1"})
+	assert.NotContains(t, deployment.Annotations, lbAnnotationVlan)
+	assert.Equal(t, "", getLoadBalancerDeploymentVlan(deployment))
+}
+
+func TestUpdateLoadBalancerDeployment(t *testing.T) {
+	c, _, _ := newTestCloud()
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	lbIP := &cloudProviderReservedIP{IP: "192.168.10.20"}
+	desired := c.generateLoadBalancerDeployment(lbName, lbIP, 10, service, nil)
+	err := c.createLoadBalancerDeployment(desired)
+	assert.Nil(t, err)
+	existing, _ := c.getLoadBalancerDeployment(lbName)
+
+	// No update needed
+	updated, err := c.updateLoadBalancerDeployment(existing, desired)
+	assert.False(t, updated)
+	assert.Nil(t, err)
+
+	// Update needed
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443})
+	desired = c.generateLoadBalancerDeployment(lbName, lbIP, 10, service, nil)
+	updated, err = c.updateLoadBalancerDeployment(existing, desired)
+	assert.True(t, updated)
+	assert.Nil(t, err)
+
+	// Update needed when a port is removed
+	existing, _ = c.getLoadBalancerDeployment(lbName)
+	service.Spec.Ports = service.Spec.Ports[:1]
+	desired = c.generateLoadBalancerDeployment(lbName, lbIP, 10, service, nil)
+	updated, err = c.updateLoadBalancerDeployment(existing, desired)
+	assert.True(t, updated)
+	assert.Nil(t, err)
+	existing, _ = c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, "tcp:80:30080", existing.Spec.Template.Spec.Containers[0].Env[2].Value)
+}
@@ -0,0 +1,411 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestCloud(objects ...runtime.Object) (*Cloud, *fake.Clientset, *record.FakeRecorder) {
+	kubeClient := fake.NewSimpleClientset(objects...)
+	recorder := record.NewFakeRecorder(100)
+	config := &CloudConfig{
+		Application:     "keepalived",
+		CalicoDatastore: "KDD",
+		ClusterID:       "clusterID",
+		Image:           "registry.ng.bluemix.net/armada-master/keepalived:1328",
+		VlanIPConfigMap: "ibm-cloud-provider-vlan-ip-config",
+	}
+	c := NewCloud(kubeClient, config, recorder)
+	c.calico = newCalicoKDDPolicyManager(newTestDynamicClient())
+	return c, kubeClient, recorder
+}
+
+func newTestDynamicClient() *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{calicoGlobalNetworkPolicyResource: "GlobalNetworkPolicyList"})
+}
+
+func newTestVlanIPConfigMap(t *testing.T) *v1.ConfigMap {
+	data, err := os.ReadFile("../../test-fixtures/ibm-cloud-provider-vlan-ip-config.yaml")
+	assert.Nil(t, err)
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	assert.Nil(t, err)
+	return obj.(*v1.ConfigMap)
+}
+
+func newTestNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func newTestService(name, uid string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(uid)},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080}},
+		},
+	}
+}
+
+func TestGetCloudProviderLoadBalancerName(t *testing.T) {
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{UID: "12345678-90ab-cdef-1234-567890abcdef"}}
+	assert.Equal(t, "a1234567890abcdef1234567890abcde", GetCloudProviderLoadBalancerName(service))
+}
+
+func TestEnsureLoadBalancer(t *testing.T) {
+	c, kubeClient, recorder := newTestCloud()
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+	nodes := []*v1.Node{newTestNode("node1", map[string]string{nodeLabelPublicVlan: "1", nodeLabelPrivateVlan: "2"})}
+
+	// VLAN IP config map not found
+	status, err := c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, status)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Config map ibm-cloud-provider-vlan-ip-config not found")
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Load balancer deployment created with a public IP on VLAN 1
+	_, err = kubeClient.CoreV1().ConfigMaps("kube-system").Create(context.Background(), newTestVlanIPConfigMap(t), metav1.CreateOptions{})
+	assert.Nil(t, err)
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.20", status.Ingress[0].IP)
+	deployment, err := c.getLoadBalancerDeployment(lbName)
+	assert.Nil(t, err)
+	assert.NotNil(t, deployment)
+	assert.Equal(t, "192.168.10.20", getLoadBalancerDeploymentIP(deployment))
+	nodeSelector := deployment.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	assert.Equal(t, nodeLabelPublicVlan, nodeSelector.NodeSelectorTerms[0].MatchExpressions[0].Key)
+	assert.Equal(t, []string{"1"}, nodeSelector.NodeSelectorTerms[0].MatchExpressions[0].Values)
+	assert.NotNil(t, getTestCalicoPolicy(c, lbName))
+
+	// Load balancer deployment updated, IP is not changed
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443})
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.20", status.Ingress[0].IP)
+	deployment, _ = c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, "tcp:443:30443,tcp:80:30080", deployment.Spec.Template.Spec.Containers[0].Env[2].Value)
+
+	// Failed to get the load balancer deployment
+	kubeClient.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("get failed")
+	})
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, status)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "get failed")
+}
+
+func TestEnsureLoadBalancerCreateFailed(t *testing.T) {
+	c, kubeClient, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	service := newTestService("echo-server", "1")
+	service.Spec.LoadBalancerIP = "192.168.10.20"
+	kubeClient.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("create failed")
+	})
+	status, err := c.EnsureLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, status)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "create failed")
+}
+
+func TestEnsureLoadBalancerRequestedIP(t *testing.T) {
+	c, _, recorder := newTestCloud(newTestVlanIPConfigMap(t))
+	service := newTestService("echo-server", "1")
+	nodes := []*v1.Node{newTestNode("node1", map[string]string{nodeLabelPublicVlan: "1", nodeLabelPrivateVlan: "2"})}
+
+	// Reserved IP requested by a service that is not an ingress controller
+	service.Spec.LoadBalancerIP = "192.168.10.15"
+	status, err := c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, status)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "192.168.10.15 is reserved for the cluster's ingress controllers")
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning RequestedLoadBalancerIPUnavailable")
+
+	// Reserved IP requested by the ingress controller
+	service.Annotations = map[string]string{serviceAnnotationIngressPublic: ""}
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.15", status.Ingress[0].IP)
+
+	// Requested IP is changed, the load balancer moves to the new IP
+	service.Annotations = nil
+	service.Spec.LoadBalancerIP = "192.168.10.21"
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.21", status.Ingress[0].IP)
+	deployment, _ := c.getLoadBalancerDeployment(GetCloudProviderLoadBalancerName(service))
+	assert.Equal(t, "192.168.10.21", getLoadBalancerDeploymentIP(deployment))
+
+	// Requested IP does not match the service annotations, the load balancer keeps its IP
+	service.Annotations = map[string]string{serviceAnnotationIPType: servicePrivateLB}
+	service.Spec.LoadBalancerIP = "192.168.10.22"
+	status, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+	assert.Nil(t, status)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "192.168.10.22 does not match the service request for a private IPv4")
+	assert.Contains(t, <-recorder.Events, "Warning RequestedLoadBalancerIPUnavailable")
+	deployment, _ = c.getLoadBalancerDeployment(GetCloudProviderLoadBalancerName(service))
+	assert.Equal(t, "192.168.10.21", getLoadBalancerDeploymentIP(deployment))
+}
+
+func TestEnsureLoadBalancerConcurrent(t *testing.T) {
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	nodes := []*v1.Node{newTestNode("node1", map[string]string{nodeLabelPublicVlan: "1", nodeLabelPrivateVlan: "2"})}
+
+	// The service status is not updated by the test, so only the lock and the
+	// deployment IPs keep concurrent creates from getting the same IP
+	services := []*v1.Service{newTestService("svc1", "1"), newTestService("svc2", "2"), newTestService("svc3", "3")}
+	ips := make(chan string, len(services))
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		go func(service *v1.Service) {
+			defer wg.Done()
+			status, err := c.EnsureLoadBalancer(context.Background(), "cluster", service, nodes)
+			assert.Nil(t, err)
+			ips <- status.Ingress[0].IP
+		}(service)
+	}
+	wg.Wait()
+	close(ips)
+	allocated := map[string]bool{}
+	for ip := range ips {
+		allocated[ip] = true
+	}
+	assert.Equal(t, map[string]bool{"192.168.10.20": true, "192.168.10.21": true, "192.168.10.22": true}, allocated)
+}
+
+func TestGetLoadBalancer(t *testing.T) {
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	service := newTestService("echo-server", "1")
+
+	// Load balancer not found
+	status, exists, err := c.GetLoadBalancer(context.Background(), "cluster", service)
+	assert.Nil(t, status)
+	assert.False(t, exists)
+	assert.Nil(t, err)
+
+	// Load balancer found
+	service.Spec.LoadBalancerIP = "192.168.10.20"
+	_, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	status, exists, err = c.GetLoadBalancer(context.Background(), "cluster", service)
+	assert.True(t, exists)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.20", status.Ingress[0].IP)
+}
+
+func TestUpdateLoadBalancer(t *testing.T) {
+	c, kubeClient, recorder := newTestCloud(newTestVlanIPConfigMap(t))
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+
+	// Load balancer not found
+	err := c.UpdateLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+
+	// Load balancer updated
+	service.Spec.LoadBalancerIP = "192.168.10.20"
+	_, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	c.Config.Image = "registry.ng.bluemix.net/armada-master/keepalived:1547"
+	err = c.UpdateLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	deployment, _ := c.getLoadBalancerDeployment(lbName)
+	assert.Equal(t, "registry.ng.bluemix.net/armada-master/keepalived:1547", deployment.Spec.Template.Spec.Containers[0].Image)
+
+	// Deleted Calico policy is applied again
+	assert.Nil(t, c.deleteCalicoPolicy(lbName))
+	assert.Nil(t, getTestCalicoPolicy(c, lbName))
+	err = c.UpdateLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	assert.NotNil(t, getTestCalicoPolicy(c, lbName))
+
+	// Calico policy apply failed, the load balancer update is not failed
+	kdd := c.calico
+	defer func(orig func([]byte, ...string) ([]byte, error)) { execCalicoctl = orig }(execCalicoctl)
+	execCalicoctl = func(input []byte, args ...string) ([]byte, error) {
+		return []byte("calicoctl output"), errors.New("exit status 1")
+	}
+	c.calico = &calicoETCDPolicyManager{}
+	err = c.UpdateLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	assert.Contains(t, <-recorder.Events, "calicoctl apply failed")
+	c.calico = kdd
+	deployment, _ = c.getLoadBalancerDeployment(lbName)
+
+	// Deployment without an IP and the requested IP is not available
+	delete(deployment.Annotations, lbAnnotationIP)
+	_, err = kubeClient.AppsV1().Deployments(lbDeploymentNamespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	service.Spec.LoadBalancerIP = "192.168.10.99"
+	err = c.UpdateLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "192.168.10.99 is not in the VLAN IP config map")
+	assert.Contains(t, <-recorder.Events, "Warning RequestedLoadBalancerIPUnavailable")
+}
+
+func TestEnsureLoadBalancerDeleted(t *testing.T) {
+	c, kubeClient, recorder := newTestCloud(newTestVlanIPConfigMap(t))
+	service := newTestService("echo-server", "1")
+	lbName := GetCloudProviderLoadBalancerName(service)
+
+	// Load balancer does not exist
+	err := c.EnsureLoadBalancerDeleted(context.Background(), "cluster", service)
+	assert.Nil(t, err)
+
+	// Load balancer deleted
+	service.Spec.LoadBalancerIP = "192.168.10.20"
+	_, err = c.EnsureLoadBalancer(context.Background(), "cluster", service, []*v1.Node{})
+	assert.Nil(t, err)
+	err = c.EnsureLoadBalancerDeleted(context.Background(), "cluster", service)
+	assert.Nil(t, err)
+	deployment, _ := c.getLoadBalancerDeployment(lbName)
+	assert.Nil(t, deployment)
+	assert.Nil(t, getTestCalicoPolicy(c, lbName))
+
+	// Calico policy delete failed, the load balancer delete is not blocked
+	defer func(orig func([]byte, ...string) ([]byte, error)) { execCalicoctl = orig }(execCalicoctl)
+	execCalicoctl = func(input []byte, args ...string) ([]byte, error) {
+		return []byte("calicoctl output"), errors.New("exit status 1")
+	}
+	c.calico = &calicoETCDPolicyManager{}
+	err = c.EnsureLoadBalancerDeleted(context.Background(), "cluster", service)
+	assert.Nil(t, err)
+	assert.Contains(t, <-recorder.Events, "calicoctl delete failed")
+
+	// Delete failed
+	kubeClient.PrependReactor("delete", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("delete failed")
+	})
+	err = c.EnsureLoadBalancerDeleted(context.Background(), "cluster", service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "delete failed")
+}
+
+func TestMonitorLoadBalancers(t *testing.T) {
+	c, kubeClient, recorder := newTestCloud(newTestVlanIPConfigMap(t))
+	config, err := c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, err)
+	createDeployment := func(service *v1.Service, lbIP string, vrid int, available int32) {
+		cloudIP := config.findIP(lbIP)
+		if cloudIP == nil {
+			cloudIP = &cloudProviderReservedIP{IP: lbIP}
+		}
+		deployment := c.generateLoadBalancerDeployment(GetCloudProviderLoadBalancerName(service), cloudIP, vrid, service, nil)
+		deployment.Status.AvailableReplicas = available
+		_, err := kubeClient.AppsV1().Deployments(lbDeploymentNamespace).Create(context.Background(), deployment, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+	healthy := newTestService("healthy", "1")
+	createDeployment(healthy, "192.168.10.20", 1, 2)
+	degraded := newTestService("degraded", "2")
+	createDeployment(degraded, "192.168.10.21", 2, 1)
+	unavailable := newTestService("unavailable", "3")
+	createDeployment(unavailable, "192.168.10.22", 3, 0)
+	notInConfig := newTestService("not-in-config", "4")
+	createDeployment(notInConfig, "192.168.10.99", 4, 2)
+	duplicate1 := newTestService("duplicate1", "5")
+	createDeployment(duplicate1, "10.10.10.20", 5, 2)
+	duplicate2 := newTestService("duplicate2", "6")
+	createDeployment(duplicate2, "10.10.10.20", 6, 2)
+	notFound := newTestService("not-found", "7")
+	// Shares the virtual router ID with duplicate1, which reports the duplicate IP first
+	duplicateVRID := newTestService("duplicate-vrid", "8")
+	createDeployment(duplicateVRID, "10.10.10.21", 5, 2)
+	// Shares the virtual router ID with duplicate1 on another VLAN
+	otherVlanVRID := newTestService("other-vlan-vrid", "9")
+	createDeployment(otherVlanVRID, "2001:db8::1", 5, 2)
+	services := &v1.ServiceList{Items: []v1.Service{*healthy, *degraded, *unavailable, *notInConfig, *duplicate1, *duplicate2, *notFound, *duplicateVRID, *otherVlanVRID}}
+	data := map[string]string{}
+
+	// No services
+	c.MonitorLoadBalancers(nil, data)
+	assert.Len(t, data, 0)
+
+	// First monitor only generates a normal event for the healthy service
+	c.MonitorLoadBalancers(services, data)
+	assert.Equal(t, map[string]string{
+		"1": lbStatusHealthy,
+		"2": lbStatusDegraded,
+		"3": lbStatusUnavailable,
+		"4": lbStatusIPNotInConfig,
+		"5": lbStatusDuplicateIP,
+		"6": lbStatusDuplicateIP,
+		"7": lbStatusNotFound,
+		"8": lbStatusDuplicateVRID,
+		"9": lbStatusHealthy,
+	}, data)
+	assert.Len(t, recorder.Events, 2)
+	assert.Contains(t, <-recorder.Events, "Normal CloudLoadBalancerNormalEvent")
+	assert.Contains(t, <-recorder.Events, "Normal CloudLoadBalancerNormalEvent")
+
+	// Second monitor generates warning events for the failed services
+	c.MonitorLoadBalancers(services, data)
+	assert.Len(t, recorder.Events, 7)
+	for i := 0; i < 7; i++ {
+		assert.Contains(t, <-recorder.Events, "Warning VerifyingCloudLoadBalancerFailed")
+	}
+
+	// Failed service is restored
+	deployment, _ := c.getLoadBalancerDeployment(GetCloudProviderLoadBalancerName(unavailable))
+	deployment.Status.AvailableReplicas = 2
+	_, err = kubeClient.AppsV1().Deployments(lbDeploymentNamespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	c.MonitorLoadBalancers(&v1.ServiceList{Items: []v1.Service{*unavailable}}, data)
+	assert.Equal(t, lbStatusHealthy, data["3"])
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "is available")
+
+	// Failed to get one load balancer deployment, the other load balancers are still monitored
+	kubeClient.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == GetCloudProviderLoadBalancerName(unavailable) {
+			return true, nil, errors.New("get failed")
+		}
+		return false, nil, nil
+	})
+	data = map[string]string{"3": lbStatusHealthy}
+	c.MonitorLoadBalancers(&v1.ServiceList{Items: []v1.Service{*unavailable, *degraded, *healthy}}, data)
+	assert.Equal(t, map[string]string{"1": lbStatusHealthy, "2": lbStatusDegraded, "3": lbStatusHealthy}, data)
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Normal CloudLoadBalancerNormalEvent")
+}
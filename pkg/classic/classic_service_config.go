@@ -0,0 +1,92 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	serviceAnnotationEnableFeatures = "service.kubernetes.io/ibm-load-balancer-cloud-provider-enable-features"
+	serviceAnnotationIPVSScheduler  = "service.kubernetes.io/ibm-load-balancer-cloud-provider-ipvs-scheduler"
+
+	lbFeatureIPVS = "ipvs"
+
+	lbVersion1 = "1.0"
+	lbVersion2 = "2.0"
+
+	ipvsSchedulerRoundRobin    = "rr"
+	ipvsSchedulerSourceHashing = "sh"
+)
+
+// getServiceEnabledFeatures returns the features enabled by the service annotation
+func getServiceEnabledFeatures(service *v1.Service) string {
+	return strings.ToLower(strings.ReplaceAll(service.Annotations[serviceAnnotationEnableFeatures], " ", ""))
+}
+
+// isFeatureEnabled returns true if the feature is enabled for the service
+func isFeatureEnabled(service *v1.Service, feature string) bool {
+	for _, enabled := range strings.Split(getServiceEnabledFeatures(service), ",") {
+		if enabled == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// getLoadBalancerVersion returns the load balancer version for the service.
+// Version 2.0 load balancers are implemented with IPVS.
+func getLoadBalancerVersion(service *v1.Service) string {
+	if isFeatureEnabled(service, lbFeatureIPVS) {
+		return lbVersion2
+	}
+	return lbVersion1
+}
+
+// getIPVSScheduler returns the IPVS scheduling algorithm for the service
+func getIPVSScheduler(service *v1.Service) string {
+	scheduler := strings.ToLower(strings.TrimSpace(service.Annotations[serviceAnnotationIPVSScheduler]))
+	if scheduler == "" {
+		return ipvsSchedulerRoundRobin
+	}
+	return scheduler
+}
+
+// IsServiceConfigurationSupported verifies that the classic load balancer
+// configuration requested by the service is supported.
+func IsServiceConfigurationSupported(service *v1.Service) error {
+	if getLoadBalancerVersion(service) != lbVersion2 {
+		return nil
+	}
+	if service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal {
+		return fmt.Errorf("Version %s load balancer requires the service externalTrafficPolicy to be %s",
+			lbVersion2, v1.ServiceExternalTrafficPolicyTypeLocal)
+	}
+	switch scheduler := getIPVSScheduler(service); scheduler {
+	case ipvsSchedulerRoundRobin, ipvsSchedulerSourceHashing:
+	default:
+		return fmt.Errorf("Value for service annotation %s must be '%s' or '%s', not '%s'",
+			serviceAnnotationIPVSScheduler, ipvsSchedulerRoundRobin, ipvsSchedulerSourceHashing, scheduler)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIsFeatureEnabled(t *testing.T) {
+	service := newTestService("echo-server", "1")
+	assert.False(t, isFeatureEnabled(service, lbFeatureIPVS))
+	assert.Equal(t, lbVersion1, getLoadBalancerVersion(service))
+
+	service.Annotations = map[string]string{serviceAnnotationEnableFeatures: "proxy-protocol, IPVS"}
+	assert.True(t, isFeatureEnabled(service, lbFeatureIPVS))
+	assert.Equal(t, lbVersion2, getLoadBalancerVersion(service))
+}
+
+func TestGetIPVSScheduler(t *testing.T) {
+	service := newTestService("echo-server", "1")
+	assert.Equal(t, ipvsSchedulerRoundRobin, getIPVSScheduler(service))
+	service.Annotations = map[string]string{serviceAnnotationIPVSScheduler: " SH "}
+	assert.Equal(t, ipvsSchedulerSourceHashing, getIPVSScheduler(service))
+}
+
+func TestIsServiceConfigurationSupported(t *testing.T) {
+	service := newTestService("echo-server", "1")
+
+	// Version 1.0 load balancer
+	service.Annotations = map[string]string{serviceAnnotationIPVSScheduler: "invalid"}
+	assert.Nil(t, IsServiceConfigurationSupported(service))
+
+	// Version 2.0 load balancer requires externalTrafficPolicy Local
+	service.Annotations = map[string]string{serviceAnnotationEnableFeatures: lbFeatureIPVS}
+	err := IsServiceConfigurationSupported(service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "requires the service externalTrafficPolicy to be Local")
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	assert.Nil(t, IsServiceConfigurationSupported(service))
+
+	// Version 2.0 load balancer scheduler
+	service.Annotations[serviceAnnotationIPVSScheduler] = ipvsSchedulerSourceHashing
+	assert.Nil(t, IsServiceConfigurationSupported(service))
+	service.Annotations[serviceAnnotationIPVSScheduler] = "wlc"
+	err = IsServiceConfigurationSupported(service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be 'rr' or 'sh', not 'wlc'")
+}
@@ -0,0 +1,403 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	vlanIPConfigMapKey = "vlanipmap.json"
+
+	nodeLabelPrivateVlan = "privateVLAN"
+	nodeLabelPublicVlan  = "publicVLAN"
+
+	serviceAnnotationIngressPrivate = "service.kubernetes.io/ibm-ingress-controller-private"
+	serviceAnnotationIngressPublic  = "service.kubernetes.io/ibm-ingress-controller-public"
+	serviceAnnotationIPType         = "service.kubernetes.io/ibm-load-balancer-cloud-provider-ip-type"
+	serviceAnnotationVlan           = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vlan"
+	serviceAnnotationZone           = "service.kubernetes.io/ibm-load-balancer-cloud-provider-zone"
+	servicePrivateLB                = "private"
+	servicePublicLB                 = "public"
+)
+
+// errRequestedIPUnavailable is returned when the IP address requested by
+// the service spec.loadBalancerIP can not be used for the load balancer.
+var errRequestedIPUnavailable = errors.New("Requested load balancer IP address is not available")
+
+// vlanIPConfigMapNamespaces are the namespaces searched, in order, for the
+// VLAN IP config map.
+var vlanIPConfigMapNamespaces = []string{"kube-system", lbDeploymentNamespace}
+
+// cloudProviderReservedIP describes a cloud provider IP address and where it
+// is located. It is used both for the reserved IPs in the VLAN IP config map
+// and for the IPs allocated to load balancers.
+type cloudProviderReservedIP struct {
+	IP       string `json:"ip"`
+	SubnetID string `json:"subnet_id"`
+	VlanID   string `json:"vlan_id"`
+	IsPublic bool   `json:"is_public"`
+	Zone     string `json:"zone"`
+}
+
+type cloudProviderSubnet struct {
+	ID       string   `json:"id"`
+	IPs      []string `json:"ips"`
+	IsPublic bool     `json:"is_public"`
+}
+
+type subnetConfigErrorField struct {
+	IsPublic        bool   `json:"is_public"`
+	IsBYOIP         bool   `json:"is_byoip"`
+	ErrorReasonCode string `json:"error_reason_code"`
+	ErrorMessage    string `json:"error_message"`
+	Status          string `json:"status"`
+}
+
+type cloudProviderVlan struct {
+	ID      string                `json:"id"`
+	Subnets []cloudProviderSubnet `json:"subnets"`
+	Zone    string                `json:"zone"`
+}
+
+type vlanConfigErrorField struct {
+	ID      string                   `json:"id"`
+	Subnets []subnetConfigErrorField `json:"subnets"`
+	Zone    string                   `json:"zone"`
+	Region  string                   `json:"region"`
+}
+
+// cloudProviderVlanIPConfig is the content of the VLAN IP config map
+type cloudProviderVlanIPConfig struct {
+	ReservedIPs []cloudProviderReservedIP `json:"reserved_ips"`
+	Vlans       []cloudProviderVlan       `json:"vlans"`
+	VlanErrors  []vlanConfigErrorField    `json:"vlan_errors"`
+}
+
+// cloudProviderIPRequest describes the cloud provider IP address requested
+// for a load balancer service.
+type cloudProviderIPRequest struct {
+	IPFamily v1.IPFamily
+	IsPublic bool
+	Reserved bool // Reserved IP for the cluster's ingress controllers
+	VlanID   string
+	Zone     string
+}
+
+// String returns a readable description of the IP request
+func (r cloudProviderIPRequest) String() string {
+	ipType := servicePrivateLB
+	if r.IsPublic {
+		ipType = servicePublicLB
+	}
+	desc := fmt.Sprintf("%s %s", ipType, r.IPFamily)
+	if r.Reserved {
+		desc = "reserved " + desc
+	}
+	if r.Zone != "" {
+		desc += " in zone " + r.Zone
+	}
+	if r.VlanID != "" {
+		desc += " on VLAN " + r.VlanID
+	}
+	return desc
+}
+
+// parseCloudProviderVlanIPConfig parses the VLAN IP config map data
+func parseCloudProviderVlanIPConfig(data string) (*cloudProviderVlanIPConfig, error) {
+	config := &cloudProviderVlanIPConfig{}
+	err := json.Unmarshal([]byte(data), config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %v", vlanIPConfigMapKey, err)
+	}
+	return config, nil
+}
+
+// getCloudProviderVlanIPConfig reads and parses the VLAN IP config map. The
+// config map is searched for in the kube-system and then ibm-system namespace.
+func (c *Cloud) getCloudProviderVlanIPConfig() (*cloudProviderVlanIPConfig, error) {
+	if c.Config.VlanIPConfigMap == "" {
+		return nil, fmt.Errorf("VLAN IP config map name is not configured")
+	}
+	for _, namespace := range vlanIPConfigMapNamespaces {
+		cm, err := c.KubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), c.Config.VlanIPConfigMap, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("Failed to get config map %s/%s: %v", namespace, c.Config.VlanIPConfigMap, err)
+		}
+		data, exists := cm.Data[vlanIPConfigMapKey]
+		if !exists {
+			return nil, fmt.Errorf("Config map %s/%s does not contain %s", namespace, c.Config.VlanIPConfigMap, vlanIPConfigMapKey)
+		}
+		return parseCloudProviderVlanIPConfig(data)
+	}
+	return nil, fmt.Errorf("Config map %s not found in namespaces: %s", c.Config.VlanIPConfigMap, strings.Join(vlanIPConfigMapNamespaces, ", "))
+}
+
+// isReservedIP returns true if the IP is one of the reserved IPs
+func (config *cloudProviderVlanIPConfig) isReservedIP(ip string) bool {
+	for _, reservedIP := range config.ReservedIPs {
+		if reservedIP.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// findIP returns the location of the IP in the VLAN IP config, or nil if
+// the IP is not found.
+func (config *cloudProviderVlanIPConfig) findIP(ip string) *cloudProviderReservedIP {
+	for i := range config.ReservedIPs {
+		if config.ReservedIPs[i].IP == ip {
+			reservedIP := config.ReservedIPs[i]
+			return &reservedIP
+		}
+	}
+	for _, vlan := range config.Vlans {
+		for _, subnet := range vlan.Subnets {
+			for _, subnetIP := range subnet.IPs {
+				if subnetIP == ip {
+					return &cloudProviderReservedIP{IP: ip, SubnetID: subnet.ID, VlanID: vlan.ID, IsPublic: subnet.IsPublic, Zone: vlan.Zone}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matches returns true if the IP satisfies the IP request
+func (r cloudProviderIPRequest) matches(ip *cloudProviderReservedIP) bool {
+	if ip.IsPublic != r.IsPublic {
+		return false
+	}
+	if r.Zone != "" && ip.Zone != r.Zone {
+		return false
+	}
+	if r.VlanID != "" && ip.VlanID != r.VlanID {
+		return false
+	}
+	return getIPFamily(ip.IP) == r.IPFamily
+}
+
+// getIPFamily returns the IP family of the IP address
+func getIPFamily(ip string) v1.IPFamily {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP != nil && parsedIP.To4() == nil {
+		return v1.IPv6Protocol
+	}
+	return v1.IPv4Protocol
+}
+
+// getCloudProviderIPRequest returns the cloud provider IP address requested
+// by the service annotations. The ingress controller annotations request one
+// of the reserved IPs. If the IP type is not specified, a public IP is
+// requested if at least one node is on the public network.
+func getCloudProviderIPRequest(service *v1.Service, nodes []*v1.Node) (cloudProviderIPRequest, error) {
+	request := cloudProviderIPRequest{
+		IPFamily: v1.IPv4Protocol,
+		VlanID:   service.Annotations[serviceAnnotationVlan],
+		Zone:     service.Annotations[serviceAnnotationZone],
+	}
+	if len(service.Spec.IPFamilies) > 0 {
+		request.IPFamily = service.Spec.IPFamilies[0]
+	}
+	_, ingressPublic := service.Annotations[serviceAnnotationIngressPublic]
+	_, ingressPrivate := service.Annotations[serviceAnnotationIngressPrivate]
+	switch {
+	case ingressPublic && ingressPrivate:
+		return request, fmt.Errorf("Service annotations %s and %s can not both be specified",
+			serviceAnnotationIngressPublic, serviceAnnotationIngressPrivate)
+	case ingressPublic || ingressPrivate:
+		request.Reserved = true
+		request.IsPublic = ingressPublic
+		return request, nil
+	}
+	switch ipType := service.Annotations[serviceAnnotationIPType]; ipType {
+	case servicePublicLB:
+		request.IsPublic = true
+	case servicePrivateLB:
+		request.IsPublic = false
+	case "":
+		request.IsPublic = isAnyNodePublic(nodes)
+	default:
+		return request, fmt.Errorf("Value for service annotation %s must be '%s' or '%s', not '%s'",
+			serviceAnnotationIPType, servicePublicLB, servicePrivateLB, ipType)
+	}
+	return request, nil
+}
+
+// isAnyNodePublic returns true if at least one of the nodes is on the public network
+func isAnyNodePublic(nodes []*v1.Node) bool {
+	for _, node := range nodes {
+		if node.Labels[nodeLabelPublicVlan] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// listServices returns all of the services in the cluster. The informer cache
+// is used if the informers were set, otherwise the services are listed from
+// the API server.
+func (c *Cloud) listServices() ([]*v1.Service, error) {
+	if c.serviceLister != nil {
+		return c.serviceLister.List(labels.Everything())
+	}
+	serviceList, err := c.KubeClient.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services := []*v1.Service{}
+	for i := range serviceList.Items {
+		services = append(services, &serviceList.Items[i])
+	}
+	return services, nil
+}
+
+// getInUseLoadBalancerIPs returns the cloud provider IP addresses that are
+// used by all load balancer services other than the specified service. The
+// IPs of the load balancer deployments are included since the status of a
+// service is only updated after its load balancer deployment was created.
+func (c *Cloud) getInUseLoadBalancerIPs(service *v1.Service) (map[string]bool, error) {
+	services, err := c.listServices()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list services: %v", err)
+	}
+	inUse := map[string]bool{}
+	for _, svc := range services {
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer || svc.UID == service.UID {
+			continue
+		}
+		if svc.Spec.LoadBalancerIP != "" {
+			inUse[svc.Spec.LoadBalancerIP] = true
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				inUse[ingress.IP] = true
+			}
+		}
+	}
+	deployments, err := c.KubeClient.AppsV1().Deployments(lbDeploymentNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: lbLabelName})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list load balancer deployments: %v", err)
+	}
+	lbName := GetCloudProviderLoadBalancerName(service)
+	for i := range deployments.Items {
+		if lbIP := getLoadBalancerDeploymentIP(&deployments.Items[i]); lbIP != "" && deployments.Items[i].Name != lbName {
+			inUse[lbIP] = true
+		}
+	}
+	return inUse, nil
+}
+
+// allocateLoadBalancerIP returns a free cloud provider IP address from the
+// VLAN IP config that satisfies the IP request. Reserved IPs are only
+// allocated when requested, otherwise they are skipped.
+func (config *cloudProviderVlanIPConfig) allocateLoadBalancerIP(request cloudProviderIPRequest, inUse map[string]bool) (*cloudProviderReservedIP, error) {
+	if request.Reserved {
+		for i := range config.ReservedIPs {
+			candidate := config.ReservedIPs[i]
+			if !inUse[candidate.IP] && request.matches(&candidate) {
+				return &candidate, nil
+			}
+		}
+		return nil, fmt.Errorf("No cloud provider IP address is available for the load balancer: %v", request)
+	}
+	for _, vlan := range config.Vlans {
+		for _, subnet := range vlan.Subnets {
+			for _, ip := range subnet.IPs {
+				if inUse[ip] || config.isReservedIP(ip) {
+					continue
+				}
+				candidate := &cloudProviderReservedIP{IP: ip, SubnetID: subnet.ID, VlanID: vlan.ID, IsPublic: subnet.IsPublic, Zone: vlan.Zone}
+				if request.matches(candidate) {
+					return candidate, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("No cloud provider IP address is available for the load balancer: %v", request)
+}
+
+// getRequestedLoadBalancerIP validates the IP address requested by the
+// service spec.loadBalancerIP. The IP must be in the VLAN IP config, must
+// satisfy the IP request, must not be used by another load balancer and, if it
+// is a reserved IP, must only be requested by an ingress controller service.
+func (config *cloudProviderVlanIPConfig) getRequestedLoadBalancerIP(requestedIP string, request cloudProviderIPRequest, inUse map[string]bool) (*cloudProviderReservedIP, error) {
+	ip := config.findIP(requestedIP)
+	switch {
+	case ip == nil:
+		return nil, fmt.Errorf("%w: %s is not in the VLAN IP config map", errRequestedIPUnavailable, requestedIP)
+	case config.isReservedIP(requestedIP) && !request.Reserved:
+		return nil, fmt.Errorf("%w: %s is reserved for the cluster's ingress controllers", errRequestedIPUnavailable, requestedIP)
+	case !request.matches(ip):
+		return nil, fmt.Errorf("%w: %s does not match the service request for a %v", errRequestedIPUnavailable, requestedIP, request)
+	case inUse[requestedIP]:
+		return nil, fmt.Errorf("%w: %s is used by another load balancer service", errRequestedIPUnavailable, requestedIP)
+	}
+	return ip, nil
+}
+
+// getLoadBalancerIP returns the cloud provider IP address for the load
+// balancer. An IP address that was previously assigned to the load balancer is
+// kept. Otherwise the IP address requested by the service is validated or,
+// if none was requested, a free IP address is allocated from the VLAN IP config.
+// The caller must hold the lbAllocationLock until the deployment with the IP
+// is created or updated, otherwise a concurrent allocation can return the same IP.
+func (c *Cloud) getLoadBalancerIP(lbIP string, service *v1.Service, nodes []*v1.Node) (*cloudProviderReservedIP, error) {
+	config, err := c.getCloudProviderVlanIPConfig()
+	if err != nil {
+		return nil, err
+	}
+	if lbIP != "" {
+		if ip := config.findIP(lbIP); ip != nil {
+			return ip, nil
+		}
+		return &cloudProviderReservedIP{IP: lbIP}, nil
+	}
+	request, err := getCloudProviderIPRequest(service, nodes)
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := c.getInUseLoadBalancerIPs(service)
+	if err != nil {
+		return nil, err
+	}
+	if requestedIP := service.Spec.LoadBalancerIP; requestedIP != "" {
+		// If the service does not set the IP type, the type of the requested IP is used
+		if ip := config.findIP(requestedIP); ip != nil && !request.Reserved && service.Annotations[serviceAnnotationIPType] == "" {
+			request.IsPublic = ip.IsPublic
+		}
+		return config.getRequestedLoadBalancerIP(requestedIP, request, inUse)
+	}
+	return config.allocateLoadBalancerIP(request, inUse)
+}
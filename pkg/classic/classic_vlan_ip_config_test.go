@@ -0,0 +1,296 @@
+/*******************************************************************************
+* IBM Cloud Kubernetes Service, 5737-D43
+* (C) Copyright IBM Corp. 2017, 2024 All Rights Reserved.
+*
+* SPDX-License-Identifier: Apache2.0
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*******************************************************************************/
+
+package classic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+func TestGetCloudProviderVlanIPConfig(t *testing.T) {
+	c, kubeClient, _ := newTestCloud()
+
+	// Config map not found
+	config, err := c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, config)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not found in namespaces: kube-system, ibm-system")
+
+	// Config map found in the ibm-system namespace
+	cm := newTestVlanIPConfigMap(t)
+	cm.Namespace = lbDeploymentNamespace
+	_, err = kubeClient.CoreV1().ConfigMaps(cm.Namespace).Create(context.Background(), cm, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	config, err = c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, err)
+	assert.Len(t, config.ReservedIPs, 2)
+	assert.Len(t, config.Vlans, 3)
+	assert.Len(t, config.VlanErrors, 2)
+	assert.Equal(t, cloudProviderReservedIP{IP: "192.168.10.15", SubnetID: "11", VlanID: "1", IsPublic: true}, config.ReservedIPs[0])
+	assert.Equal(t, []string{"10.10.10.20", "10.10.10.21"}, config.Vlans[1].Subnets[0].IPs)
+	assert.Equal(t, "ErrorSubnetLimitReached", config.VlanErrors[0].Subnets[0].ErrorReasonCode)
+
+	// Config map is not valid
+	cm.Data[vlanIPConfigMapKey] = "{"
+	_, err = kubeClient.CoreV1().ConfigMaps(cm.Namespace).Update(context.Background(), cm, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	config, err = c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, config)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Failed to parse vlanipmap.json")
+
+	// Config map is missing the VLAN IP map
+	delete(cm.Data, vlanIPConfigMapKey)
+	_, err = kubeClient.CoreV1().ConfigMaps(cm.Namespace).Update(context.Background(), cm, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	config, err = c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, config)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not contain vlanipmap.json")
+
+	// Config map name is not set
+	c.Config.VlanIPConfigMap = ""
+	config, err = c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, config)
+	assert.NotNil(t, err)
+}
+
+func TestFindIP(t *testing.T) {
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	config, err := c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, err)
+
+	assert.Equal(t, &cloudProviderReservedIP{IP: "10.10.10.15", SubnetID: "22", VlanID: "2"}, config.findIP("10.10.10.15"))
+	assert.Equal(t, &cloudProviderReservedIP{IP: "192.168.10.21", SubnetID: "11", VlanID: "1", IsPublic: true}, config.findIP("192.168.10.21"))
+	assert.Nil(t, config.findIP("192.168.10.99"))
+	assert.True(t, config.isReservedIP("192.168.10.15"))
+	assert.False(t, config.isReservedIP("192.168.10.20"))
+}
+
+func TestGetCloudProviderIPRequest(t *testing.T) {
+	service := newTestService("echo-server", "1")
+	publicNode := newTestNode("node1", map[string]string{nodeLabelPublicVlan: "1", nodeLabelPrivateVlan: "2"})
+	privateNode := newTestNode("node2", map[string]string{nodeLabelPrivateVlan: "2"})
+
+	// Default IP type depends on the nodes
+	request, err := getCloudProviderIPRequest(service, []*v1.Node{privateNode})
+	assert.Nil(t, err)
+	assert.Equal(t, cloudProviderIPRequest{IPFamily: v1.IPv4Protocol}, request)
+	request, err = getCloudProviderIPRequest(service, []*v1.Node{privateNode, publicNode})
+	assert.Nil(t, err)
+	assert.True(t, request.IsPublic)
+
+	// IP type, zone, VLAN and IP family requested
+	service.Annotations = map[string]string{
+		serviceAnnotationIPType: servicePrivateLB,
+		serviceAnnotationVlan:   "2",
+		serviceAnnotationZone:   "dal10",
+	}
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+	request, err = getCloudProviderIPRequest(service, []*v1.Node{publicNode})
+	assert.Nil(t, err)
+	assert.Equal(t, cloudProviderIPRequest{IPFamily: v1.IPv6Protocol, VlanID: "2", Zone: "dal10"}, request)
+	assert.Equal(t, "private IPv6 in zone dal10 on VLAN 2", request.String())
+
+	// Invalid IP type
+	service.Annotations[serviceAnnotationIPType] = "invalid"
+	_, err = getCloudProviderIPRequest(service, []*v1.Node{publicNode})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be 'public' or 'private', not 'invalid'")
+
+	// Reserved IP requested for the ingress controller
+	service.Annotations = map[string]string{serviceAnnotationIngressPrivate: ""}
+	service.Spec.IPFamilies = nil
+	request, err = getCloudProviderIPRequest(service, []*v1.Node{publicNode})
+	assert.Nil(t, err)
+	assert.Equal(t, cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, Reserved: true}, request)
+	assert.Equal(t, "reserved private IPv4", request.String())
+	service.Annotations = map[string]string{serviceAnnotationIngressPublic: ""}
+	request, err = getCloudProviderIPRequest(service, []*v1.Node{privateNode})
+	assert.Nil(t, err)
+	assert.Equal(t, cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, IsPublic: true, Reserved: true}, request)
+	service.Annotations[serviceAnnotationIngressPrivate] = ""
+	_, err = getCloudProviderIPRequest(service, []*v1.Node{privateNode})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "can not both be specified")
+}
+
+func TestAllocateLoadBalancerIP(t *testing.T) {
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	config, err := c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, err)
+
+	// First free IP for each request
+	ip, err := config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, IsPublic: true}, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, &cloudProviderReservedIP{IP: "192.168.10.20", SubnetID: "11", VlanID: "1", IsPublic: true}, ip)
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol}, map[string]bool{"10.10.10.20": true})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.10.10.21", ip.IP)
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv6Protocol, IsPublic: true}, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, "2001:db8::1", ip.IP)
+
+	// No IP available on the VLAN or in the zone
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, IsPublic: true, VlanID: "2"}, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "No cloud provider IP address is available for the load balancer: public IPv4 on VLAN 2")
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, Zone: "dal10"}, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.NotNil(t, err)
+
+	// All IPs in use
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol}, map[string]bool{"10.10.10.20": true, "10.10.10.21": true})
+	assert.Nil(t, ip)
+	assert.NotNil(t, err)
+
+	// Reserved IP
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, IsPublic: true, Reserved: true}, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, &cloudProviderReservedIP{IP: "192.168.10.15", SubnetID: "11", VlanID: "1", IsPublic: true}, ip)
+	ip, err = config.allocateLoadBalancerIP(cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, Reserved: true}, map[string]bool{"10.10.10.15": true})
+	assert.Nil(t, ip)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "reserved private IPv4")
+}
+
+func TestGetRequestedLoadBalancerIP(t *testing.T) {
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t))
+	config, err := c.getCloudProviderVlanIPConfig()
+	assert.Nil(t, err)
+	request := cloudProviderIPRequest{IPFamily: v1.IPv4Protocol}
+	ingressRequest := cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, Reserved: true}
+
+	// Requested IP is available
+	ip, err := config.getRequestedLoadBalancerIP("10.10.10.21", request, map[string]bool{"10.10.10.20": true})
+	assert.Nil(t, err)
+	assert.Equal(t, &cloudProviderReservedIP{IP: "10.10.10.21", SubnetID: "22", VlanID: "2"}, ip)
+	ip, err = config.getRequestedLoadBalancerIP("10.10.10.15", ingressRequest, map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.10.10.15", ip.IP)
+
+	// Requested IP is not available
+	ip, err = config.getRequestedLoadBalancerIP("10.10.10.99", request, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	assert.Contains(t, err.Error(), "10.10.10.99 is not in the VLAN IP config map")
+	ip, err = config.getRequestedLoadBalancerIP("10.10.10.15", request, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	assert.Contains(t, err.Error(), "10.10.10.15 is reserved for the cluster's ingress controllers")
+	ip, err = config.getRequestedLoadBalancerIP("10.10.10.20", request, map[string]bool{"10.10.10.20": true})
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	assert.Contains(t, err.Error(), "10.10.10.20 is used by another load balancer service")
+
+	// Requested IP does not match the IP request
+	ip, err = config.getRequestedLoadBalancerIP("192.168.10.21", request, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	assert.Contains(t, err.Error(), "192.168.10.21 does not match the service request for a private IPv4")
+	ip, err = config.getRequestedLoadBalancerIP("10.10.10.21", cloudProviderIPRequest{IPFamily: v1.IPv4Protocol, VlanID: "1"}, map[string]bool{})
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	assert.Contains(t, err.Error(), "on VLAN 1")
+}
+
+func TestGetInUseLoadBalancerIPs(t *testing.T) {
+	svc1 := newTestService("svc1", "1")
+	svc1.Spec.LoadBalancerIP = "192.168.10.20"
+	svc2 := newTestService("svc2", "2")
+	svc2.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "192.168.10.21"}}
+	svc3 := newTestService("svc3", "3")
+	svc3.Spec.Type = v1.ServiceTypeClusterIP
+	svc3.Spec.LoadBalancerIP = "192.168.10.22"
+	c, _, _ := newTestCloud(svc1, svc2, svc3)
+
+	inUse, err := c.getInUseLoadBalancerIPs(svc1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"192.168.10.21": true}, inUse)
+	inUse, err = c.getInUseLoadBalancerIPs(newTestService("svc4", "4"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"192.168.10.20": true, "192.168.10.21": true}, inUse)
+
+	// IPs of the other load balancer deployments are in use
+	svc5 := newTestService("svc5", "5")
+	assert.Nil(t, c.createLoadBalancerDeployment(c.generateLoadBalancerDeployment(GetCloudProviderLoadBalancerName(svc5), &cloudProviderReservedIP{IP: "10.10.10.20"}, 1, svc5, nil)))
+	inUse, err = c.getInUseLoadBalancerIPs(svc1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"192.168.10.21": true, "10.10.10.20": true}, inUse)
+	inUse, err = c.getInUseLoadBalancerIPs(svc5)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"192.168.10.20": true, "192.168.10.21": true}, inUse)
+
+	// Services are read from the informer cache
+	c.serviceLister = corelisters.NewServiceLister(newTestIndexer(svc2))
+	inUse, err = c.getInUseLoadBalancerIPs(svc5)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"192.168.10.21": true}, inUse)
+}
+
+func TestGetLoadBalancerIP(t *testing.T) {
+	existing := newTestService("svc1", "1")
+	existing.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "10.10.10.20"}}
+	c, _, _ := newTestCloud(newTestVlanIPConfigMap(t), existing)
+	service := newTestService("echo-server", "2")
+	nodes := []*v1.Node{newTestNode("node1", map[string]string{nodeLabelPrivateVlan: "2"})}
+
+	// Existing IP is kept
+	ip, err := c.getLoadBalancerIP("192.168.10.22", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, &cloudProviderReservedIP{IP: "192.168.10.22", SubnetID: "11", VlanID: "1", IsPublic: true}, ip)
+	ip, err = c.getLoadBalancerIP("192.168.10.99", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, &cloudProviderReservedIP{IP: "192.168.10.99"}, ip)
+
+	// Free private IP is allocated
+	ip, err = c.getLoadBalancerIP("", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.10.10.21", ip.IP)
+
+	// Requested IP is validated
+	service.Spec.LoadBalancerIP = "192.168.10.21"
+	ip, err = c.getLoadBalancerIP("", service, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.10.21", ip.IP)
+	service.Spec.LoadBalancerIP = "10.10.10.20"
+	ip, err = c.getLoadBalancerIP("", service, nodes)
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	service.Annotations = map[string]string{serviceAnnotationIPType: servicePrivateLB}
+	service.Spec.LoadBalancerIP = "192.168.10.21"
+	ip, err = c.getLoadBalancerIP("", service, nodes)
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, errRequestedIPUnavailable)
+	service.Annotations = nil
+	service.Spec.LoadBalancerIP = ""
+
+	// Invalid IP type
+	service.Annotations = map[string]string{serviceAnnotationIPType: "invalid"}
+	ip, err = c.getLoadBalancerIP("", service, nodes)
+	assert.Nil(t, ip)
+	assert.NotNil(t, err)
+}
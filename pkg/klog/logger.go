@@ -64,3 +64,14 @@ func Warningf(format string, v ...interface{}) {
 		klog.WarningDepth(1, s)
 	}
 }
+
+// V2Infof ... only logged if the verbosity is at least 2
+func V2Infof(format string, v ...interface{}) {
+	if logStdout {
+		timestamp := time.Now().Format("15:04:05.0000")
+		fmt.Printf("INFO: ["+timestamp+"] "+format+"\n", v...)
+	} else if klog.V(2).Enabled() {
+		s := fmt.Sprintf(format, v...)
+		klog.V(2).InfoDepth(1, s)
+	}
+}
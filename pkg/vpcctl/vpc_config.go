@@ -21,9 +21,12 @@ package vpcctl
 
 import (
 	"fmt"
+	"net"
 	"sort"
+	"strconv"
 	"strings"
 
+	"cloud.ibm.com/cloud-provider-ibm/pkg/klog"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/informers"
 )
@@ -35,19 +38,37 @@ const (
 	iamStagePrivateTokenExchangeURL    = "https://private.iam.test.cloud.ibm.com" // #nosec G101 IBM Cloud iam stage private URL
 	iamStageTestPublicTokenExchangeURL = "https://iam.stage1.bluemix.net"         // #nosec G101 IBM Cloud iam stage public URL
 
-	nodeLabelDedicated  = "dedicated"
-	nodeLabelInternalIP = "ibm-cloud.kubernetes.io/internal-ip"
-	nodeLabelValueEdge  = "edge"
-	nodeLabelZone       = "ibm-cloud.kubernetes.io/zone"
-
-	serviceAnnotationEnableFeatures = "service.kubernetes.io/ibm-load-balancer-cloud-provider-enable-features"
-	serviceAnnotationIPType         = "service.kubernetes.io/ibm-load-balancer-cloud-provider-ip-type"
-	serviceAnnotationLbName         = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-lb-name"
-	serviceAnnotationNodeSelector   = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-node-selector"
-	serviceAnnotationSubnets        = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-subnets"
-	serviceAnnotationZone           = "service.kubernetes.io/ibm-load-balancer-cloud-provider-zone"
-	servicePrivateLB                = "private"
-	servicePublicLB                 = "public"
+	nodeLabelDedicated    = "dedicated"
+	nodeLabelInternalIP   = "ibm-cloud.kubernetes.io/internal-ip"
+	nodeLabelMachineType  = "ibm-cloud.kubernetes.io/machine-type"
+	nodeLabelMemberWeight = "ibm-cloud.kubernetes.io/lb-member-weight"
+	nodeLabelValueEdge    = "edge"
+	nodeLabelWorkerID     = "ibm-cloud.kubernetes.io/worker-id"
+	nodeLabelZone         = "ibm-cloud.kubernetes.io/zone"
+
+	serviceAnnotationCertificateCRN     = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-certificate-crn"
+	serviceAnnotationConnectionLimit    = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-connection-limit"
+	serviceAnnotationEnableFeatures     = "service.kubernetes.io/ibm-load-balancer-cloud-provider-enable-features"
+	serviceAnnotationHealthCheckDelay   = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-delay"
+	serviceAnnotationHealthCheckPath    = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-path"
+	serviceAnnotationHealthCheckPort    = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-port"
+	serviceAnnotationHealthCheckProto   = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-protocol"
+	serviceAnnotationHealthCheckRetries = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-retries"
+	serviceAnnotationHealthCheckTimeout = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-health-check-timeout"
+	serviceAnnotationHTTPSPorts         = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-https-ports"
+	serviceAnnotationHTTPSRedirect      = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-https-redirect"
+	serviceAnnotationIdleTimeout        = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-idle-connection-timeout"
+	serviceAnnotationIPType             = "service.kubernetes.io/ibm-load-balancer-cloud-provider-ip-type"
+	serviceAnnotationLbName             = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-lb-name"
+	serviceAnnotationNodeSelector       = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-node-selector"
+	serviceAnnotationPoolAlgorithm      = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-pool-algorithm"
+	serviceAnnotationPortRange          = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-port-range"
+	serviceAnnotationSecurityGroups     = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-security-groups"
+	serviceAnnotationSessionPersistence = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-session-persistence"
+	serviceAnnotationSubnets            = "service.kubernetes.io/ibm-load-balancer-cloud-provider-vpc-subnets"
+	serviceAnnotationZone               = "service.kubernetes.io/ibm-load-balancer-cloud-provider-zone"
+	servicePrivateLB                    = "private"
+	servicePublicLB                     = "public"
 
 	// VpcEndpointIaaSBaseURL - baseURL for constructing the VPC infrastructure API Endpoint URL
 	vpcEndpointIaaSProdURL  = "iaas.cloud.ibm.com"
@@ -92,6 +113,7 @@ type ConfigVpc struct {
 	EnablePrivate              bool
 	IamEndpointOverride        string
 	IKSPrivateEndpointHostname string
+	NodeInternalIPSubnetNames  string
 	ProviderType               string
 	Region                     string
 	ResourceGroupName          string
@@ -241,24 +263,102 @@ func (c *CloudVpc) findNodesMatchingLabelValue(nodes []*v1.Node, filterLabel, fi
 	return matchingNodes
 }
 
-// getNodeIDs - get the node identifier for each node in the list
-func (c *CloudVpc) getNodeIDs(nodeList []*v1.Node) []string {
+// getNodeIDs - get the node identifier for each node in the list. If subnets were configured for the node internal IPs,
+// the address of a node with multiple network interfaces that is in one of those subnets is used. If the service
+// requested instance targets, the VPC instance ID of each node is returned instead of the IP address. If the service
+// requested member weights, the weight of each node is saved in the service options
+func (c *CloudVpc) getNodeIDs(nodeList []*v1.Node, vpcSubnets []*VpcSubnet, options *ServiceOptions) []string {
 	nodeIDs := []string{}
+	if options.isMemberWeights() {
+		options.memberWeights = map[string]int64{}
+	}
+	if options.isInstanceTargets() {
+		for _, node := range nodeList {
+			instanceID := c.getNodeInstanceID(node)
+			if instanceID != "" {
+				nodeIDs = append(nodeIDs, instanceID)
+				c.setNodeMemberWeight(node, instanceID, options)
+			}
+		}
+		c.setUnknownMemberWeights(options)
+		return nodeIDs
+	}
+	nodeSubnets := []*VpcSubnet{}
+	for _, nameID := range strings.Split(c.Config.NodeInternalIPSubnetNames, ",") {
+		nameID = strings.TrimSpace(nameID)
+		for _, subnet := range vpcSubnets {
+			if nameID != "" && (nameID == subnet.Name || nameID == subnet.ID) {
+				nodeSubnets = append(nodeSubnets, subnet)
+			}
+		}
+	}
 	for _, node := range nodeList {
-		nodeInternalAddress := c.getNodeInternalIP(node)
+		nodeInternalAddress := c.getNodeInternalIPInSubnets(node, nodeSubnets)
+		if nodeInternalAddress == "" {
+			nodeInternalAddress = c.getNodeInternalIP(node)
+		}
 		if nodeInternalAddress != "" {
 			nodeIDs = append(nodeIDs, nodeInternalAddress)
+			c.setNodeMemberWeight(node, nodeInternalAddress, options)
 		}
 	}
+	c.setUnknownMemberWeights(options)
 	return nodeIDs
 }
 
-// getNodeInternalIP - get the Internal IP of the node from label or status
+// setNodeMemberWeight - save the pool member weight of the node in the service options if member weights were requested.
+// If the weight of the node can not be determined, -1 is saved and the weight is set by setUnknownMemberWeights
+func (c *CloudVpc) setNodeMemberWeight(node *v1.Node, nodeID string, options *ServiceOptions) {
+	if options.memberWeights != nil {
+		weight, ok := c.getNodeMemberWeight(node)
+		if !ok {
+			weight = -1
+		}
+		options.memberWeights[nodeID] = weight
+	}
+}
+
+// setUnknownMemberWeights - set the weight of the nodes whose weight could not be determined to the median weight of
+// the other nodes, so that all of the weights are on the same scale. If no weight is known, the default weight is used
+func (c *CloudVpc) setUnknownMemberWeights(options *ServiceOptions) {
+	knownWeights := []int64{}
+	for _, weight := range options.memberWeights {
+		if weight >= 0 {
+			knownWeights = append(knownWeights, weight)
+		}
+	}
+	medianWeight := int64(LoadBalancerMemberWeightDefault)
+	if len(knownWeights) > 0 {
+		sort.Slice(knownWeights, func(i, j int) bool { return knownWeights[i] < knownWeights[j] })
+		medianWeight = knownWeights[len(knownWeights)/2]
+	}
+	for nodeID, weight := range options.memberWeights {
+		if weight < 0 {
+			klog.Warningf("Weight of pool member %s could not be determined, using the median weight %d", nodeID, medianWeight)
+			options.memberWeights[nodeID] = medianWeight
+		}
+	}
+}
+
+// getNodeInstanceID - get the VPC instance ID of the node from the provider ID or the worker ID label. The provider ID
+// has the format: ibm://<account>///<cluster>/<instance-id>
+func (c *CloudVpc) getNodeInstanceID(node *v1.Node) string {
+	if node.Spec.ProviderID != "" {
+		fields := strings.Split(node.Spec.ProviderID, "/")
+		if instanceID := fields[len(fields)-1]; instanceID != "" {
+			return instanceID
+		}
+	}
+	return node.Labels[nodeLabelWorkerID]
+}
+
+// getNodeInternalIP - get the Internal IP of the node from label or status. VPC load balancer pool members must
+// be IPv4 addresses, so the IPv6 addresses of a dual-stack node are skipped
 func (c *CloudVpc) getNodeInternalIP(node *v1.Node) string {
 	nodeInternalAddress := node.Labels[nodeLabelInternalIP]
 	if nodeInternalAddress == "" {
 		for _, address := range node.Status.Addresses {
-			if address.Type == v1.NodeInternalIP {
+			if address.Type == v1.NodeInternalIP && isIPv4(address.Address) {
 				nodeInternalAddress = address.Address
 				break
 			}
@@ -267,11 +367,52 @@ func (c *CloudVpc) getNodeInternalIP(node *v1.Node) string {
 	return nodeInternalAddress
 }
 
-// getPoolMemberTargets - get the targets (IP address) for all of the pool members
+// getNodeInternalIPInSubnets - get the Internal IP of the node that is in one of the specified subnets
+func (c *CloudVpc) getNodeInternalIPInSubnets(node *v1.Node, subnets []*VpcSubnet) string {
+	for _, subnet := range subnets {
+		_, cidr, err := net.ParseCIDR(subnet.Ipv4CidrBlock)
+		if err != nil {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == v1.NodeInternalIP && cidr.Contains(net.ParseIP(address.Address)) {
+				return address.Address
+			}
+		}
+	}
+	return ""
+}
+
+// getNodeMemberWeight - get the pool member weight of the node. The weight is taken from the member weight label on the
+// node. If the label is not set, the number of vCPUs in the machine type label is used, for example: bx2.4x16 = 4.
+// False is returned if the weight can not be determined
+func (c *CloudVpc) getNodeMemberWeight(node *v1.Node) (int64, bool) {
+	if value := strings.TrimSpace(node.Labels[nodeLabelMemberWeight]); value != "" {
+		weight, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && weight >= 0 && weight <= LoadBalancerMemberWeightMax {
+			return weight, true
+		}
+		klog.Warningf("Node %s has invalid %s label: %s", node.Name, nodeLabelMemberWeight, value)
+	}
+	machineType := node.Labels[nodeLabelMachineType]
+	if i := strings.Index(machineType, "."); i >= 0 {
+		vcpus, err := strconv.ParseInt(strings.Split(machineType[i+1:], "x")[0], 10, 64)
+		if err == nil && vcpus > 0 {
+			if vcpus > LoadBalancerMemberWeightMax {
+				return LoadBalancerMemberWeightMax, true
+			}
+			return vcpus, true
+		}
+	}
+	klog.Warningf("Node %s weight can not be determined from the %s label: %s", node.Name, nodeLabelMachineType, machineType)
+	return 0, false
+}
+
+// getPoolMemberTargets - get the targets (IP address or instance ID) for all of the pool members
 func (c *CloudVpc) getPoolMemberTargets(members []*VpcLoadBalancerPoolMember) []string {
 	memberTargets := []string{}
 	for _, member := range members {
-		memberTargets = append(memberTargets, member.TargetIPAddress)
+		memberTargets = append(memberTargets, member.getTarget())
 	}
 	return memberTargets
 }
@@ -309,23 +450,72 @@ func (c *CloudVpc) getServiceNodeSelectorFilter(service *v1.Service) (string, st
 	return "", ""
 }
 
-// getServicePoolNames - get list of pool names for the service ports
+// getServicePoolNames - get list of pool names for the service ports.
+// The ports of a port range only have a single pool for the first port of the range
 func (c *CloudVpc) getServicePoolNames(service *v1.Service) ([]string, error) {
 	poolList := []string{}
 	if service == nil {
 		return poolList, fmt.Errorf("Service not specified")
 	}
+	options := c.getServiceOptions(service)
 	for _, kubePort := range service.Spec.Ports {
+		if options.isPortRangeMember(int(kubePort.Port)) {
+			continue
+		}
 		poolList = append(poolList, genLoadBalancerPoolName(kubePort))
 	}
 	return poolList, nil
 }
 
+// getServiceSourceRanges - retrieve the loadBalancerSourceRanges of the service
+func (c *CloudVpc) getServiceSourceRanges(service *v1.Service) []string {
+	sourceRanges := []string{}
+	for _, sourceRange := range service.Spec.LoadBalancerSourceRanges {
+		if sourceRange = strings.TrimSpace(sourceRange); sourceRange != "" {
+			sourceRanges = append(sourceRanges, sourceRange)
+		}
+	}
+	return sourceRanges
+}
+
 // getServiceSubnets - retrieve the vpc-subnets annotation
 func (c *CloudVpc) getServiceSubnets(service *v1.Service) string {
 	return strings.ReplaceAll(service.ObjectMeta.Annotations[serviceAnnotationSubnets], " ", "")
 }
 
+// getServiceSecurityGroupRules - generate the rules of the security group that is managed for the load balancer. Inbound traffic
+// is allowed from each of the loadBalancerSourceRanges of the service to each of the listener ports. If no source ranges are set,
+// traffic is allowed from any source. Outbound traffic is allowed so the load balancer can reach the node ports and health checks
+func (c *CloudVpc) getServiceSecurityGroupRules(service *v1.Service, options *ServiceOptions) []*VpcSecurityGroupRule {
+	sourceRanges := options.getSourceRanges()
+	if len(sourceRanges) == 0 {
+		sourceRanges = []string{SecurityGroupRuleRemoteAny}
+	}
+	rules := []*VpcSecurityGroupRule{}
+	for _, kubePort := range service.Spec.Ports {
+		// The ports of a port range are covered by a single rule
+		if options.isPortRangeMember(int(kubePort.Port)) {
+			continue
+		}
+		portMin, portMax := options.getPortRange(int(kubePort.Port))
+		for _, sourceRange := range sourceRanges {
+			rules = append(rules, &VpcSecurityGroupRule{
+				Direction: SecurityGroupRuleDirectionInbound,
+				PortMax:   int64(portMax),
+				PortMin:   int64(portMin),
+				Protocol:  strings.ToLower(string(kubePort.Protocol)),
+				Remote:    sourceRange,
+			})
+		}
+	}
+	rules = append(rules, &VpcSecurityGroupRule{
+		Direction: SecurityGroupRuleDirectionOutbound,
+		Protocol:  SecurityGroupRuleProtocolAll,
+		Remote:    SecurityGroupRuleRemoteAny,
+	})
+	return rules
+}
+
 // getSubnetIDs - get the IDs for all of the subnets that were passed in
 func (c *CloudVpc) getSubnetIDs(subnets []*VpcSubnet) []string {
 	subnetIDs := []string{}
@@ -341,10 +531,32 @@ func (c *CloudVpc) initialize() error {
 	return c.Config.initialize()
 }
 
-// isServicePortEqualListener - does the specified service port equal the values specified
-func (c *CloudVpc) isServicePortEqualListener(kubePort v1.ServicePort, listener *VpcLoadBalancerListener) bool {
-	return int(listener.Port) == int(kubePort.Port) &&
-		strings.EqualFold(listener.Protocol, string(kubePort.Protocol))
+// isIPv4 - is the specified address an IPv4 address
+func isIPv4(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() != nil
+}
+
+// isServiceProtocolUDP - does the service have any UDP ports
+func (c *CloudVpc) isServiceProtocolUDP(service *v1.Service) bool {
+	for _, kubePort := range service.Spec.Ports {
+		if kubePort.Protocol == v1.ProtocolUDP {
+			return true
+		}
+	}
+	return false
+}
+
+// isServicePortEqualListener - does the specified service port equal the values specified.
+// The listener protocol and port range must match the ones requested for the port by the service annotations
+func (c *CloudVpc) isServicePortEqualListener(kubePort v1.ServicePort, listener *VpcLoadBalancerListener, options *ServiceOptions) bool {
+	portMin, portMax := options.getPortRange(int(kubePort.Port))
+	listenerPortMax := listener.Port
+	if listener.IsPortRange() {
+		listenerPortMax = listener.PortMax
+	}
+	return int(listener.Port) == portMin && int(listenerPortMax) == portMax &&
+		strings.EqualFold(listener.Protocol, options.getListenerProtocol(genLoadBalancerPoolNameFields(kubePort)))
 }
 
 // isServicePortEqualPoolName - does the specified service port equal the fields of a pool name
@@ -356,17 +568,308 @@ func (c *CloudVpc) isServicePortEqualPoolName(kubePort v1.ServicePort, poolName
 // validateService - validate the service and the requested features on the service
 func (c *CloudVpc) validateService(service *v1.Service) (*ServiceOptions, error) {
 	options := c.getServiceOptions(service)
-	// Only TCP is supported
+	// Only TCP and UDP are supported
 	for _, kubePort := range service.Spec.Ports {
-		if kubePort.Protocol != v1.ProtocolTCP {
-			return nil, fmt.Errorf("Service %s/%s is a %s load balancer. Only TCP is supported",
+		if kubePort.Protocol != v1.ProtocolTCP && kubePort.Protocol != v1.ProtocolUDP {
+			return nil, fmt.Errorf("Service %s/%s is a %s load balancer. Only TCP and UDP are supported",
 				service.ObjectMeta.Namespace, service.ObjectMeta.Name, kubePort.Protocol)
 		}
 	}
+	// Validate the health check annotations
+	err := c.validateServiceHealthCheck(service, options)
+	if err != nil {
+		return nil, err
+	}
+	// Validate the pool algorithm and session persistence annotations
+	switch algorithm := options.getPoolAlgorithm(); algorithm {
+	case LoadBalancerAlgorithmRoundRobin, LoadBalancerAlgorithmWeightedRoundRobin:
+	case LoadBalancerAlgorithmLeastConnections:
+		if options.isNLB() {
+			return nil, fmt.Errorf("Service %s/%s requests a network load balancer. The %s algorithm is not supported by network load balancers",
+				service.ObjectMeta.Namespace, service.ObjectMeta.Name, algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("The annotation %s on service %s/%s contains invalid algorithm %s. The algorithm must be %s, %s, or %s",
+			serviceAnnotationPoolAlgorithm, service.ObjectMeta.Namespace, service.ObjectMeta.Name, algorithm,
+			LoadBalancerAlgorithmLeastConnections, LoadBalancerAlgorithmRoundRobin, LoadBalancerAlgorithmWeightedRoundRobin)
+	}
+	switch sessionPersistence := options.getSessionPersistence(); sessionPersistence {
+	case LoadBalancerSessionPersistenceNone, LoadBalancerSessionPersistenceSourceIP:
+	default:
+		return nil, fmt.Errorf("The annotation %s on service %s/%s contains invalid session persistence %s. The session persistence must be %s",
+			serviceAnnotationSessionPersistence, service.ObjectMeta.Namespace, service.ObjectMeta.Name, sessionPersistence,
+			LoadBalancerSessionPersistenceSourceIP)
+	}
+	// Only one version of the PROXY protocol can be requested
+	proxyProtocolOption := LoadBalancerOptionProxyProtocol
+	if isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionProxyProtocolV2) {
+		if isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionProxyProtocol) {
+			return nil, fmt.Errorf("The annotation %s on service %s/%s contains both the %s and %s options. Only one of these options can be specified",
+				serviceAnnotationEnableFeatures, service.ObjectMeta.Namespace, service.ObjectMeta.Name,
+				LoadBalancerOptionProxyProtocol, LoadBalancerOptionProxyProtocolV2)
+		}
+		proxyProtocolOption = LoadBalancerOptionProxyProtocolV2
+	}
+	// Network load balancers do not support the PROXY protocol
+	if options.isNLB() && options.isProxyProtocol() {
+		return nil, fmt.Errorf("Service %s/%s requests a network load balancer. The %s option is not supported by network load balancers",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name, proxyProtocolOption)
+	}
+	// Instance targets are only supported by network load balancers
+	if options.isInstanceTargets() && !options.isNLB() {
+		return nil, fmt.Errorf("Service %s/%s requests an application load balancer. The %s option is only supported by network load balancers",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name, LoadBalancerOptionInstanceTargets)
+	}
+	// Verify the listener settings
+	if err := c.validateServiceListener(service, options); err != nil {
+		return nil, err
+	}
+	if err := c.validateServiceHTTPS(service, options); err != nil {
+		return nil, err
+	}
+	if err := c.validateServicePortRange(service, options); err != nil {
+		return nil, err
+	}
+	if err := c.validateServiceSourceRanges(service, options); err != nil {
+		return nil, err
+	}
 	// All other service annotation options we ignore and just pass through
 	return options, nil
 }
 
+// Validate the health check annotations on the service
+func (c *CloudVpc) validateServiceHealthCheck(service *v1.Service, options *ServiceOptions) error {
+	// Verify that each of the numeric annotations is within the range supported by VPC
+	numericAnnotations := []struct {
+		name     string
+		min, max int
+	}{
+		{serviceAnnotationHealthCheckDelay, 2, 60},
+		{serviceAnnotationHealthCheckPort, 1, 65535},
+		{serviceAnnotationHealthCheckRetries, 1, 10},
+		{serviceAnnotationHealthCheckTimeout, 1, 59},
+	}
+	for _, annotation := range numericAnnotations {
+		if err := c.validateServiceAnnotationRange(service, annotation.name, annotation.min, annotation.max); err != nil {
+			return err
+		}
+	}
+	// Verify the health check protocol and path
+	protocol := options.getHealthCheckProtocol()
+	switch protocol {
+	case "", LoadBalancerProtocolHTTP, LoadBalancerProtocolHTTPS, LoadBalancerProtocolTCP:
+	default:
+		return fmt.Errorf("The annotation %s on service %s/%s contains invalid protocol %s. The protocol must be %s, %s, or %s",
+			serviceAnnotationHealthCheckProto, service.ObjectMeta.Namespace, service.ObjectMeta.Name, protocol,
+			LoadBalancerProtocolHTTP, LoadBalancerProtocolHTTPS, LoadBalancerProtocolTCP)
+	}
+	path := options.getHealthCheckPath()
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("The annotation %s on service %s/%s contains invalid path %s. The path must start with /",
+			serviceAnnotationHealthCheckPath, service.ObjectMeta.Namespace, service.ObjectMeta.Name, path)
+	}
+	if path != "" && protocol == LoadBalancerProtocolTCP {
+		return fmt.Errorf("The annotation %s on service %s/%s can not be used with the %s health check protocol",
+			serviceAnnotationHealthCheckPath, service.ObjectMeta.Namespace, service.ObjectMeta.Name, protocol)
+	}
+	// The health check delay must be greater than the timeout
+	healthMonitor := options.getHealthMonitor(&VpcPoolNameFields{})
+	if healthMonitor.Delay <= healthMonitor.Timeout {
+		return fmt.Errorf("The health check delay %d on service %s/%s must be greater than the health check timeout %d",
+			healthMonitor.Delay, service.ObjectMeta.Namespace, service.ObjectMeta.Name, healthMonitor.Timeout)
+	}
+	return nil
+}
+
+// Validate the listener annotations on the service
+func (c *CloudVpc) validateServiceListener(service *v1.Service, options *ServiceOptions) error {
+	err := c.validateServiceAnnotationRange(service, serviceAnnotationConnectionLimit, 1, LoadBalancerConnectionLimitDefault)
+	if err != nil {
+		return err
+	}
+	err = c.validateServiceAnnotationRange(service, serviceAnnotationIdleTimeout,
+		LoadBalancerIdleConnectionTimeoutDefault, LoadBalancerIdleConnectionTimeoutMax)
+	if err != nil {
+		return err
+	}
+	// Network load balancers do not support the idle connection timeout
+	if options.isNLB() && options.annotations[serviceAnnotationIdleTimeout] != "" {
+		return fmt.Errorf("Service %s/%s requests a network load balancer. The annotation %s is not supported by network load balancers",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name, serviceAnnotationIdleTimeout)
+	}
+	return nil
+}
+
+// Validate the HTTPS listener annotations on the service
+func (c *CloudVpc) validateServiceHTTPS(service *v1.Service, options *ServiceOptions) error {
+	httpsPorts := strings.TrimSpace(options.annotations[serviceAnnotationHTTPSPorts])
+	httpsRedirect := strings.TrimSpace(options.annotations[serviceAnnotationHTTPSRedirect])
+	if httpsPorts == "" && httpsRedirect == "" {
+		return nil
+	}
+	if options.isNLB() {
+		return fmt.Errorf("Service %s/%s requests a network load balancer. HTTPS listeners are not supported by network load balancers",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+	}
+	if httpsPorts == "" {
+		return fmt.Errorf("The annotation %s on service %s/%s requires the annotation %s",
+			serviceAnnotationHTTPSRedirect, service.ObjectMeta.Namespace, service.ObjectMeta.Name, serviceAnnotationHTTPSPorts)
+	}
+	if options.getCertificateCRN() == "" {
+		return fmt.Errorf("The annotation %s on service %s/%s requires the annotation %s",
+			serviceAnnotationHTTPSPorts, service.ObjectMeta.Namespace, service.ObjectMeta.Name, serviceAnnotationCertificateCRN)
+	}
+	// Each of the HTTPS ports must be a TCP port of the service
+	for _, value := range strings.Split(httpsPorts, ",") {
+		port, _ := strconv.Atoi(strings.TrimSpace(value))
+		if !c.isServiceTCPPort(service, port) {
+			return fmt.Errorf("The annotation %s on service %s/%s contains invalid port %s. The port must be a TCP port of the service",
+				serviceAnnotationHTTPSPorts, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value))
+		}
+	}
+	// Each redirect must be from a TCP port of the service to one of the HTTPS ports
+	if httpsRedirect == "" {
+		return nil
+	}
+	for _, value := range strings.Split(httpsRedirect, ",") {
+		ports := strings.Split(value, ":")
+		httpPort, httpsPort := 0, 0
+		if len(ports) == 2 {
+			httpPort, _ = strconv.Atoi(strings.TrimSpace(ports[0]))
+			httpsPort, _ = strconv.Atoi(strings.TrimSpace(ports[1]))
+		}
+		if !c.isServiceTCPPort(service, httpPort) || options.isHTTPSPort(httpPort) || !options.isHTTPSPort(httpsPort) {
+			return fmt.Errorf("The annotation %s on service %s/%s contains invalid redirect %s. The redirect must be <http-port>:<https-port> where <https-port> is listed in the annotation %s",
+				serviceAnnotationHTTPSRedirect, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value), serviceAnnotationHTTPSPorts)
+		}
+	}
+	return nil
+}
+
+// Validate the port range annotation on the service
+func (c *CloudVpc) validateServicePortRange(service *v1.Service, options *ServiceOptions) error {
+	portRanges := strings.TrimSpace(options.annotations[serviceAnnotationPortRange])
+	if portRanges == "" {
+		return nil
+	}
+	if !options.isNLB() || !options.isPublic() {
+		return fmt.Errorf("The annotation %s on service %s/%s is only supported by public network load balancers",
+			serviceAnnotationPortRange, service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+	}
+	previousRanges := [][2]int{}
+	for _, value := range strings.Split(portRanges, ",") {
+		portMin, portMax := parsePortRange(value)
+		if portMin == 0 {
+			return fmt.Errorf("The annotation %s on service %s/%s contains invalid port range %s. The range must be <min>-<max>",
+				serviceAnnotationPortRange, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value))
+		}
+		// The ranges must not overlap
+		for _, previous := range previousRanges {
+			if portMin <= previous[1] && portMax >= previous[0] {
+				return fmt.Errorf("The annotation %s on service %s/%s contains overlapping port range %s",
+					serviceAnnotationPortRange, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value))
+			}
+		}
+		previousRanges = append(previousRanges, [2]int{portMin, portMax})
+		// Each port in the range must be a service port with the same protocol. The listener forwards the traffic
+		// to the port that it was received on, so the node port of each of these service ports must match its port
+		protocols := map[v1.Protocol]int{}
+		for _, kubePort := range service.Spec.Ports {
+			if int(kubePort.Port) < portMin || int(kubePort.Port) > portMax {
+				continue
+			}
+			if kubePort.NodePort != kubePort.Port {
+				return fmt.Errorf("The service port %d on service %s/%s is in port range %s. The node port of the service port must be %d",
+					kubePort.Port, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value), kubePort.Port)
+			}
+			protocols[kubePort.Protocol]++
+		}
+		for protocol, count := range protocols {
+			if count != portMax-portMin+1 {
+				return fmt.Errorf("The annotation %s on service %s/%s contains port range %s. Each port in the range must be a %s port of the service",
+					serviceAnnotationPortRange, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value), protocol)
+			}
+		}
+		if len(protocols) == 0 {
+			return fmt.Errorf("The annotation %s on service %s/%s contains port range %s which does not contain any service ports",
+				serviceAnnotationPortRange, service.ObjectMeta.Namespace, service.ObjectMeta.Name, strings.TrimSpace(value))
+		}
+	}
+	return nil
+}
+
+// isServiceTCPPort - is the port a TCP port of the service
+func (c *CloudVpc) isServiceTCPPort(service *v1.Service, port int) bool {
+	for _, kubePort := range service.Spec.Ports {
+		if kubePort.Protocol == v1.ProtocolTCP && int(kubePort.Port) == port {
+			return true
+		}
+	}
+	return false
+}
+
+// validateServiceIPFamilies - VPC subnets and load balancers only support IPv4. A dual-stack service is only allowed
+// if the IP family policy is PreferDualStack, in which case the load balancer only handles the IPv4 traffic. This is only
+// checked when the load balancer is created, see checkServiceIPFamilies() for existing load balancers
+func (c *CloudVpc) validateServiceIPFamilies(service *v1.Service) error {
+	ipv4Requested := false
+	for _, family := range service.Spec.IPFamilies {
+		if family == v1.IPv4Protocol {
+			ipv4Requested = true
+		}
+	}
+	preferDualStack := service.Spec.IPFamilyPolicy != nil && *service.Spec.IPFamilyPolicy == v1.IPFamilyPolicyPreferDualStack
+	for _, family := range service.Spec.IPFamilies {
+		if family != v1.IPv4Protocol && !(ipv4Requested && preferDualStack) {
+			return fmt.Errorf("Service %s/%s requests IP family %s. VPC load balancers only support the %s IP family",
+				service.ObjectMeta.Namespace, service.ObjectMeta.Name, family, v1.IPv4Protocol)
+		}
+	}
+	return nil
+}
+
+// validateServiceLoadBalancerIP - the VPC load balancer API does not support requesting a specific or reserved IP
+// address, so a service that sets the loadBalancerIP is rejected rather than being assigned a different IP. This is only
+// checked when the load balancer is created, see checkServiceLoadBalancerIP() for existing load balancers
+func (c *CloudVpc) validateServiceLoadBalancerIP(service *v1.Service) error {
+	if service.Spec.LoadBalancerIP != "" {
+		return fmt.Errorf("Service %s/%s requests load balancer IP %s. VPC load balancers do not support requesting a specific IP address, remove the loadBalancerIP from the service",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name, service.Spec.LoadBalancerIP)
+	}
+	return nil
+}
+
+// validateServiceSourceRanges - verify that each of the loadBalancerSourceRanges of the service is an IPv4 CIDR. The
+// source ranges are only enforced by the managed security group, which only supports IPv4. Without the managed security
+// group the source ranges are not used, see checkServiceSourceRanges()
+func (c *CloudVpc) validateServiceSourceRanges(service *v1.Service, options *ServiceOptions) error {
+	if !options.isManagedSecurityGroup() {
+		return nil
+	}
+	for _, sourceRange := range options.getSourceRanges() {
+		ip, _, err := net.ParseCIDR(sourceRange)
+		if err != nil || ip.To4() == nil {
+			return fmt.Errorf("Service %s/%s contains invalid loadBalancerSourceRanges entry %s. The entry must be an IPv4 CIDR, for example 10.0.0.0/8",
+				service.ObjectMeta.Namespace, service.ObjectMeta.Name, sourceRange)
+		}
+	}
+	return nil
+}
+
+// Validate that a numeric annotation on the service is within the range supported by VPC
+func (c *CloudVpc) validateServiceAnnotationRange(service *v1.Service, annotation string, min, max int) error {
+	value := strings.TrimSpace(service.ObjectMeta.Annotations[annotation])
+	if value == "" {
+		return nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return fmt.Errorf("The annotation %s on service %s/%s contains invalid value %s. The value must be a number between %d and %d",
+			annotation, service.ObjectMeta.Namespace, service.ObjectMeta.Name, value, min, max)
+	}
+	return nil
+}
+
 // Validate the subnets annotation on the service
 func (c *CloudVpc) validateServiceSubnets(service *v1.Service, serviceSubnets, vpcID string, vpcSubnets []*VpcSubnet) ([]string, error) {
 	desiredSubnetMap := map[string]bool{}
@@ -437,7 +940,39 @@ func (c *CloudVpc) validateServiceSubnetsNotUpdated(service *v1.Service, lb *Vpc
 	return nil
 }
 
-// Validate that the public/private annotation on the service was not updated
+// Validate that the security groups of the service were not updated
+func (c *CloudVpc) validateServiceSecurityGroupsNotUpdated(options *ServiceOptions, lb *VpcLoadBalancer) error {
+	requested := options.getSecurityGroups()
+	if options.isManagedSecurityGroup() {
+		requested = append(requested, lb.Name)
+	}
+	// If no security groups were requested, the load balancer is using the default security group of the VPC
+	if len(requested) == 0 {
+		return nil
+	}
+	// Each of the security groups attached to the load balancer must have been requested by name or ID
+	actual := []string{}
+	for _, securityGroup := range lb.SecurityGroups {
+		for _, nameID := range requested {
+			if nameID == securityGroup.ID || nameID == securityGroup.Name {
+				actual = append(actual, securityGroup.Name)
+				break
+			}
+		}
+	}
+	if len(actual) != len(lb.SecurityGroups) || len(requested) != len(lb.SecurityGroups) {
+		actual = []string{}
+		for _, securityGroup := range lb.SecurityGroups {
+			actual = append(actual, securityGroup.Name)
+		}
+		sort.Strings(actual)
+		return fmt.Errorf("The load balancer was created with security groups %s. This setting can not be changed", strings.Join(actual, ","))
+	}
+	// No update was detected
+	return nil
+}
+
+// Validate that the public/private annotation and the load balancer profile of the service were not updated
 func (c *CloudVpc) validateServiceTypeNotUpdated(options *ServiceOptions, lb *VpcLoadBalancer) error {
 	if options.isPublic() != lb.IsPublic {
 		lbType := servicePrivateLB
@@ -446,6 +981,17 @@ func (c *CloudVpc) validateServiceTypeNotUpdated(options *ServiceOptions, lb *Vp
 		}
 		return fmt.Errorf("The load balancer was created as a %s load balancer. This setting can not be changed", lbType)
 	}
+	// The profile of an existing load balancer can not be changed. UDP ports require a network load balancer
+	if options.isNLB() != lb.IsNLB() {
+		switch {
+		case lb.IsNLB():
+			return fmt.Errorf("The load balancer was created as a network load balancer. This setting can not be changed")
+		case options.udpPorts:
+			return fmt.Errorf("The load balancer was created as an application load balancer and does not support UDP. This setting can not be changed")
+		default:
+			return fmt.Errorf("The load balancer was created as an application load balancer. This setting can not be changed")
+		}
+	}
 	return nil
 }
 
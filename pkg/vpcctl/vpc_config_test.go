@@ -307,11 +307,107 @@ func TestCloudVpc_FindNodesMatchingLabelValue(t *testing.T) {
 
 func TestCloudVpc_GetNodeIDs(t *testing.T) {
 	nodes := []*v1.Node{mockNode1, mockNode2, mockNode3}
-	c := CloudVpc{}
-	nodeIDs := c.getNodeIDs(nodes)
+	c := CloudVpc{Config: &ConfigVpc{}}
+	subnets := []*VpcSubnet{
+		{ID: "subnetID", Name: "subnet", Ipv4CidrBlock: "192.168.1.0/24"},
+		{ID: "storageID", Name: "storage", Ipv4CidrBlock: "10.10.0.0/24"},
+	}
+	options := &ServiceOptions{}
+	nodeIDs := c.getNodeIDs(nodes, subnets, options)
 	assert.Equal(t, len(nodeIDs), 2)
 	assert.Equal(t, nodeIDs[0], mockNode1.Name)
 	assert.Equal(t, nodeIDs[1], mockNode2.Name)
+
+	// Node with multiple network interfaces, the address in the configured subnet is used
+	multiNicNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.5"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+		{Address: "192.168.1.5", Type: v1.NodeInternalIP},
+		{Address: "10.10.0.5", Type: v1.NodeInternalIP},
+	}}}
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"192.168.1.5"})
+	c.Config.NodeInternalIPSubnetNames = "missing, storage"
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode, mockNode2}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"10.10.0.5", "192.168.2.2"})
+	c.Config.NodeInternalIPSubnetNames = "subnetID"
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"192.168.1.5"})
+
+	// Instance targets requested, the VPC instance ID of the node is used
+	options.enabledFeatures = LoadBalancerOptionInstanceTargets
+	multiNicNode.Spec.ProviderID = "ibm://accountID///clusterID/instance-1"
+	labelNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.5", Labels: map[string]string{nodeLabelWorkerID: "instance-2"}}}
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode, labelNode, mockNode2}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"instance-1", "instance-2"})
+	assert.Nil(t, options.memberWeights)
+
+	// Member weights requested, the weight of each node is saved in the options
+	options.enabledFeatures = LoadBalancerOptionMemberWeights
+	multiNicNode.Labels = map[string]string{nodeLabelMachineType: "bx2.8x32"}
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode, mockNode2}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"192.168.1.5", "192.168.2.2"})
+	assert.Equal(t, options.memberWeights, map[string]int64{"192.168.1.5": 8, "192.168.2.2": 8})
+
+	// Weight of a node with an unknown machine type is the median weight of the other nodes
+	labelNode.Labels = map[string]string{nodeLabelInternalIP: "192.168.2.5", nodeLabelMachineType: "bx2.2x8"}
+	nodeIDs = c.getNodeIDs([]*v1.Node{multiNicNode, labelNode, mockNode2}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"192.168.1.5", "192.168.2.5", "192.168.2.2"})
+	assert.Equal(t, options.memberWeights, map[string]int64{"192.168.1.5": 8, "192.168.2.5": 2, "192.168.2.2": 8})
+
+	// Weight of the nodes is the default weight if no weight is known
+	nodeIDs = c.getNodeIDs([]*v1.Node{mockNode2}, subnets, options)
+	assert.Equal(t, nodeIDs, []string{"192.168.2.2"})
+	assert.Equal(t, options.memberWeights, map[string]int64{"192.168.2.2": LoadBalancerMemberWeightDefault})
+}
+
+func TestCloudVpc_GetNodeMemberWeight(t *testing.T) {
+	c := CloudVpc{}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1", Labels: map[string]string{}}}
+	weight, ok := c.getNodeMemberWeight(node)
+	assert.False(t, ok)
+	assert.Equal(t, weight, int64(0))
+
+	// Weight from the vCPUs of the machine type
+	node.Labels[nodeLabelMachineType] = "bx2.4x16"
+	weight, ok = c.getNodeMemberWeight(node)
+	assert.True(t, ok)
+	assert.Equal(t, weight, int64(4))
+	node.Labels[nodeLabelMachineType] = "gx2.16x128xv100"
+	weight, _ = c.getNodeMemberWeight(node)
+	assert.Equal(t, weight, int64(16))
+	node.Labels[nodeLabelMachineType] = "mx2.128x1024"
+	weight, _ = c.getNodeMemberWeight(node)
+	assert.Equal(t, weight, int64(LoadBalancerMemberWeightMax))
+	node.Labels[nodeLabelMachineType] = "invalid"
+	_, ok = c.getNodeMemberWeight(node)
+	assert.False(t, ok)
+
+	// Weight from the member weight label
+	node.Labels[nodeLabelMachineType] = "bx2.4x16"
+	node.Labels[nodeLabelMemberWeight] = "0"
+	weight, ok = c.getNodeMemberWeight(node)
+	assert.True(t, ok)
+	assert.Equal(t, weight, int64(0))
+	node.Labels[nodeLabelMemberWeight] = " 75 "
+	weight, _ = c.getNodeMemberWeight(node)
+	assert.Equal(t, weight, int64(75))
+	node.Labels[nodeLabelMemberWeight] = "101"
+	weight, _ = c.getNodeMemberWeight(node)
+	assert.Equal(t, weight, int64(4))
+	node.Labels[nodeLabelMemberWeight] = "heavy"
+	weight, _ = c.getNodeMemberWeight(node)
+	assert.Equal(t, weight, int64(4))
+}
+
+func TestCloudVpc_GetNodeInstanceID(t *testing.T) {
+	c := CloudVpc{}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1", Labels: map[string]string{nodeLabelWorkerID: "labelID"}}}
+	assert.Equal(t, c.getNodeInstanceID(node), "labelID")
+	node.Spec.ProviderID = "ibm://accountID///clusterID/providerID"
+	assert.Equal(t, c.getNodeInstanceID(node), "providerID")
+	node.Spec.ProviderID = "ibm://accountID///clusterID/"
+	assert.Equal(t, c.getNodeInstanceID(node), "labelID")
+	node.Labels = map[string]string{}
+	assert.Equal(t, c.getNodeInstanceID(node), "")
 }
 
 func TestCloudVpc_GetNodeInteralIP(t *testing.T) {
@@ -327,6 +423,19 @@ func TestCloudVpc_GetNodeInteralIP(t *testing.T) {
 
 	internalIP = c.getNodeInternalIP(mockNode4)
 	assert.Equal(t, "", internalIP)
+
+	// Dual-stack node, the IPv4 address is returned
+	dualStackNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.5.5"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+		{Address: "2001:db8::5", Type: v1.NodeInternalIP},
+		{Address: "192.168.5.5", Type: v1.NodeInternalIP},
+	}}}
+	internalIP = c.getNodeInternalIP(dualStackNode)
+	assert.Equal(t, "192.168.5.5", internalIP)
+
+	// IPv6 only node, no address is returned
+	dualStackNode.Status.Addresses = dualStackNode.Status.Addresses[0:1]
+	internalIP = c.getNodeInternalIP(dualStackNode)
+	assert.Equal(t, "", internalIP)
 }
 
 func TestCloudVpc_GetPoolMemberTargets(t *testing.T) {
@@ -334,6 +443,10 @@ func TestCloudVpc_GetPoolMemberTargets(t *testing.T) {
 	result := mockCloud.getPoolMemberTargets(members)
 	assert.Equal(t, len(result), 1)
 	assert.Equal(t, result[0], "192.168.1.1")
+
+	members = []*VpcLoadBalancerPoolMember{{TargetInstanceID: "1234-56-7890"}}
+	result = mockCloud.getPoolMemberTargets(members)
+	assert.Equal(t, result, []string{"1234-56-7890"})
 }
 
 func TestCloudVpc_GetServiceNodeSelectorFilter(t *testing.T) {
@@ -374,6 +487,51 @@ func TestCloudVpc_getServicePoolNames(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, len(poolNames), 1)
 	assert.Equal(t, poolNames[0], "tcp-80-30123")
+
+	// getPoolNamesForService with a port range, only the first port of the range has a pool
+	service.ObjectMeta.Annotations[serviceAnnotationPortRange] = "30000-30002"
+	service.Spec.Ports = []v1.ServicePort{
+		{Protocol: v1.ProtocolTCP, Port: 30000, NodePort: 30000},
+		{Protocol: v1.ProtocolTCP, Port: 30001, NodePort: 30001},
+		{Protocol: v1.ProtocolTCP, Port: 30002, NodePort: 30002},
+		{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30123},
+	}
+	poolNames, err = c.getServicePoolNames(service)
+	assert.Nil(t, err)
+	assert.Equal(t, poolNames, []string{"tcp-30000-30000", "tcp-80-30123"})
+}
+
+func TestCloudVpc_getServiceSecurityGroupRules(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+			{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080},
+			{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053},
+		}},
+	}
+	outboundRule := &VpcSecurityGroupRule{Direction: SecurityGroupRuleDirectionOutbound, Protocol: SecurityGroupRuleProtocolAll, Remote: SecurityGroupRuleRemoteAny}
+
+	// No source ranges, traffic is allowed from any source
+	rules := mockCloud.getServiceSecurityGroupRules(service, mockCloud.getServiceOptions(service))
+	assert.Equal(t, rules, []*VpcSecurityGroupRule{
+		{Direction: SecurityGroupRuleDirectionInbound, PortMax: 80, PortMin: 80, Protocol: LoadBalancerProtocolTCP, Remote: SecurityGroupRuleRemoteAny},
+		{Direction: SecurityGroupRuleDirectionInbound, PortMax: 53, PortMin: 53, Protocol: LoadBalancerProtocolUDP, Remote: SecurityGroupRuleRemoteAny},
+		outboundRule,
+	})
+
+	// Source ranges and port range, a rule is generated for each listener and source range
+	service.Spec.LoadBalancerSourceRanges = []string{"192.168.1.0/24", " 10.0.0.0/8 "}
+	service.Spec.Ports = []v1.ServicePort{
+		{Protocol: v1.ProtocolTCP, Port: 30000, NodePort: 30000},
+		{Protocol: v1.ProtocolTCP, Port: 30001, NodePort: 30001},
+	}
+	service.ObjectMeta.Annotations[serviceAnnotationPortRange] = "30000-30001"
+	rules = mockCloud.getServiceSecurityGroupRules(service, mockCloud.getServiceOptions(service))
+	assert.Equal(t, rules, []*VpcSecurityGroupRule{
+		{Direction: SecurityGroupRuleDirectionInbound, PortMax: 30001, PortMin: 30000, Protocol: LoadBalancerProtocolTCP, Remote: "192.168.1.0/24"},
+		{Direction: SecurityGroupRuleDirectionInbound, PortMax: 30001, PortMin: 30000, Protocol: LoadBalancerProtocolTCP, Remote: "10.0.0.0/8"},
+		outboundRule,
+	})
 }
 
 func TestCloudVpc_getSubnetIDs(t *testing.T) {
@@ -390,12 +548,19 @@ func TestCloudVpc_validateService(t *testing.T) {
 			Annotations: map[string]string{serviceAnnotationEnableFeatures: ""}},
 		Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}}},
 	}
-	// validateService, only TCP protocol is supported
-	service.Spec.Ports[0].Protocol = v1.ProtocolUDP
+	// validateService, only TCP and UDP protocols are supported
+	service.Spec.Ports[0].Protocol = v1.ProtocolSCTP
 	options, err := mockCloud.validateService(service)
 	assert.Empty(t, options)
 	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "Only TCP is supported")
+	assert.Contains(t, err.Error(), "Only TCP and UDP are supported")
+
+	// validateService, UDP requires a network load balancer
+	service.Spec.Ports[0].Protocol = v1.ProtocolUDP
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+	assert.True(t, options.isNLB())
 
 	// validateService, other options passed on through
 	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = "generic-option"
@@ -403,6 +568,332 @@ func TestCloudVpc_validateService(t *testing.T) {
 	options, err = mockCloud.validateService(service)
 	assert.Equal(t, options.enabledFeatures, "generic-option")
 	assert.Nil(t, err)
+
+	// validateService, network load balancer requested
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionNLB
+	options, err = mockCloud.validateService(service)
+	assert.True(t, options.isNLB())
+	assert.Nil(t, err)
+
+	// validateService, network load balancer does not support proxy protocol
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionNLB + "," + LoadBalancerOptionProxyProtocol
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "proxy-protocol option is not supported by network load balancers")
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionNLB + "," + LoadBalancerOptionProxyProtocolV2
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "proxy-protocol-v2 option is not supported by network load balancers")
+
+	// validateService, instance targets are only supported by network load balancers
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionInstanceTargets
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "instance-targets option is only supported by network load balancers")
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionNLB + "," + LoadBalancerOptionInstanceTargets
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, err)
+	assert.True(t, options.isInstanceTargets())
+
+	// validateService, both versions of the proxy protocol requested
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionProxyProtocol + "," + LoadBalancerOptionProxyProtocolV2
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Only one of these options can be specified")
+
+	// validateService, proxy protocol v2 requested
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionProxyProtocolV2
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, err)
+	assert.Equal(t, options.getProxyProtocol(), LoadBalancerProxyProtocolV2)
+
+	// validateService, invalid pool algorithm
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationPoolAlgorithm: "random"}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contains invalid algorithm random")
+
+	// validateService, network load balancer does not support least connections
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+		serviceAnnotationPoolAlgorithm:  LoadBalancerAlgorithmLeastConnections,
+	}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "least_connections algorithm is not supported by network load balancers")
+
+	// validateService, invalid session persistence
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationSessionPersistence: "app_cookie"}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contains invalid session persistence app_cookie")
+
+	// validateService, valid pool algorithm and session persistence
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationPoolAlgorithm:      "Least_Connections",
+		serviceAnnotationSessionPersistence: "source_ip",
+	}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, err)
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmLeastConnections)
+	assert.Equal(t, options.getSessionPersistence(), LoadBalancerSessionPersistenceSourceIP)
+
+	// validateService, HTTPS listener annotations
+	service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 443}}
+	for errorMessage, annotations := range map[string]map[string]string{
+		"HTTPS listeners are not supported by network load balancers": {
+			serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+			serviceAnnotationHTTPSPorts:     "443",
+		},
+		"requires the annotation " + serviceAnnotationHTTPSPorts: {
+			serviceAnnotationHTTPSRedirect: "80:443",
+		},
+		"requires the annotation " + serviceAnnotationCertificateCRN: {
+			serviceAnnotationHTTPSPorts: "443",
+		},
+		"contains invalid port 8443": {
+			serviceAnnotationCertificateCRN: "crn",
+			serviceAnnotationHTTPSPorts:     "443,8443",
+		},
+		"contains invalid redirect 80:8443": {
+			serviceAnnotationCertificateCRN: "crn",
+			serviceAnnotationHTTPSPorts:     "443",
+			serviceAnnotationHTTPSRedirect:  "80:8443",
+		},
+		"contains invalid redirect 443:443": {
+			serviceAnnotationCertificateCRN: "crn",
+			serviceAnnotationHTTPSPorts:     "443",
+			serviceAnnotationHTTPSRedirect:  "443:443",
+		},
+	} {
+		service.ObjectMeta.Annotations = annotations
+		options, err = mockCloud.validateService(service)
+		assert.Nil(t, options)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), errorMessage)
+	}
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationCertificateCRN: "crn",
+		serviceAnnotationHTTPSPorts:     "443",
+		serviceAnnotationHTTPSRedirect:  "80:443",
+	}
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+
+	// validateService, invalid connection limit
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationConnectionLimit: "20000"}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "The value must be a number between 1 and 15000")
+
+	// validateService, invalid idle connection timeout
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationIdleTimeout: "10"}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "The value must be a number between 50 and 7200")
+
+	// validateService, network load balancer does not support the idle connection timeout
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+		serviceAnnotationIdleTimeout:    "300",
+	}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not supported by network load balancers")
+
+	// validateService, invalid port range annotations
+	service.Spec.Ports = []v1.ServicePort{
+		{Protocol: v1.ProtocolTCP, Port: 30000, NodePort: 30000},
+		{Protocol: v1.ProtocolTCP, Port: 30001, NodePort: 30001},
+		{Protocol: v1.ProtocolTCP, Port: 30002, NodePort: 30002},
+	}
+	for errorMessage, annotations := range map[string]map[string]string{
+		"is only supported by public network load balancers": {
+			serviceAnnotationPortRange: "30000-30002",
+		},
+		"contains invalid port range 30002-30000": {
+			serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+			serviceAnnotationPortRange:      "30002-30000",
+		},
+		"contains overlapping port range 30001-30002": {
+			serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+			serviceAnnotationPortRange:      "30000-30001,30001-30002",
+		},
+		"contains port range 30000-30003. Each port in the range must be a TCP port of the service": {
+			serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+			serviceAnnotationPortRange:      "30000-30003",
+		},
+		"which does not contain any service ports": {
+			serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+			serviceAnnotationPortRange:      "31000-31002",
+		},
+	} {
+		service.ObjectMeta.Annotations = annotations
+		options, err = mockCloud.validateService(service)
+		assert.Nil(t, options)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), errorMessage)
+	}
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationEnableFeatures: LoadBalancerOptionNLB,
+		serviceAnnotationPortRange:      "30001-30002",
+	}
+	service.Spec.Ports[1].NodePort = 31001
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "The node port of the service port must be 30001")
+	service.Spec.Ports[1].NodePort = 30001
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Protocol: v1.ProtocolUDP, Port: 30000, NodePort: 30000})
+	service.ObjectMeta.Annotations[serviceAnnotationPortRange] = "30000-30002"
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Each port in the range must be a UDP port of the service")
+	service.Spec.Ports = service.Spec.Ports[0:3]
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+	service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}}
+
+	// validateService, IPv6 is not supported unless dual-stack is only preferred. The IP families are only rejected
+	// when the load balancer is created
+	requireDualStack := v1.IPFamilyPolicyRequireDualStack
+	preferDualStack := v1.IPFamilyPolicyPreferDualStack
+	service.ObjectMeta.Annotations = map[string]string{}
+	for _, spec := range []struct {
+		families []v1.IPFamily
+		policy   *v1.IPFamilyPolicy
+	}{
+		{[]v1.IPFamily{v1.IPv6Protocol}, nil},
+		{[]v1.IPFamily{v1.IPv6Protocol}, &preferDualStack},
+		{[]v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}, &requireDualStack},
+	} {
+		service.Spec.IPFamilies = spec.families
+		service.Spec.IPFamilyPolicy = spec.policy
+		options, err = mockCloud.validateService(service)
+		assert.NotNil(t, options)
+		assert.Nil(t, err)
+		err = mockCloud.validateServiceIPFamilies(service)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "requests IP family IPv6. VPC load balancers only support the IPv4 IP family")
+	}
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	service.Spec.IPFamilyPolicy = &preferDualStack
+	err = mockCloud.validateServiceIPFamilies(service)
+	assert.Nil(t, err)
+	service.Spec.IPFamilies = nil
+	service.Spec.IPFamilyPolicy = nil
+
+	// validateService, loadBalancerIP is only rejected when the load balancer is created
+	service.Spec.LoadBalancerIP = "192.168.0.1"
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+	err = mockCloud.validateServiceLoadBalancerIP(service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "requests load balancer IP 192.168.0.1. VPC load balancers do not support requesting a specific IP address")
+	service.Spec.LoadBalancerIP = ""
+	err = mockCloud.validateServiceLoadBalancerIP(service)
+	assert.Nil(t, err)
+
+	// validateService, loadBalancerSourceRanges are not validated without the managed security group
+	service.ObjectMeta.Annotations = map[string]string{}
+	service.Spec.LoadBalancerSourceRanges = []string{"192.168.1.0/24", "2001:db8::/32"}
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+
+	// validateService, invalid loadBalancerSourceRanges
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionManagedSecurityGroup}
+	for _, sourceRange := range []string{"10.0.0.0", "10.0.0.0/33", "2001:db8::/32"} {
+		service.Spec.LoadBalancerSourceRanges = []string{"192.168.1.0/24", sourceRange}
+		options, err = mockCloud.validateService(service)
+		assert.Nil(t, options)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "contains invalid loadBalancerSourceRanges entry "+sourceRange)
+	}
+	service.Spec.LoadBalancerSourceRanges = []string{"192.168.1.0/24", " 10.0.0.0/8"}
+	options, err = mockCloud.validateService(service)
+	assert.NotNil(t, options)
+	assert.Nil(t, err)
+	service.ObjectMeta.Annotations = map[string]string{}
+	service.Spec.LoadBalancerSourceRanges = nil
+
+	// validateService, invalid health check annotation
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationHealthCheckProto: "udp"}
+	options, err = mockCloud.validateService(service)
+	assert.Nil(t, options)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contains invalid protocol udp")
+}
+
+func TestCloudVpc_validateServiceHealthCheck(t *testing.T) {
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", Annotations: map[string]string{}}}
+
+	// Success, no annotations
+	err := mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.Nil(t, err)
+
+	// Success, all annotations valid
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationHealthCheckDelay:   "10",
+		serviceAnnotationHealthCheckPath:    "/healthz",
+		serviceAnnotationHealthCheckPort:    "8080",
+		serviceAnnotationHealthCheckProto:   "http",
+		serviceAnnotationHealthCheckRetries: "3",
+		serviceAnnotationHealthCheckTimeout: "5",
+	}
+	err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.Nil(t, err)
+
+	// Failed, numeric values out of range or not numbers
+	for annotation, value := range map[string]string{
+		serviceAnnotationHealthCheckDelay:   "61",
+		serviceAnnotationHealthCheckPort:    "port",
+		serviceAnnotationHealthCheckRetries: "0",
+		serviceAnnotationHealthCheckTimeout: "-1",
+	} {
+		service.ObjectMeta.Annotations = map[string]string{annotation: value}
+		err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "contains invalid value "+value)
+	}
+
+	// Failed, invalid protocol
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationHealthCheckProto: "udp"}
+	err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contains invalid protocol udp")
+
+	// Failed, invalid path
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationHealthCheckPath: "healthz"}
+	err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "The path must start with /")
+
+	// Failed, path used with TCP health check
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationHealthCheckPath: "/healthz", serviceAnnotationHealthCheckProto: "tcp"}
+	err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "can not be used with the tcp health check protocol")
+
+	// Failed, delay is not greater than timeout
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationHealthCheckDelay: "5", serviceAnnotationHealthCheckTimeout: "5"}
+	err = mockCloud.validateServiceHealthCheck(service, mockCloud.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be greater than the health check timeout 5")
 }
 
 func TestCloudVpc_ValidateServiceSubnets(t *testing.T) {
@@ -457,6 +948,41 @@ func TestCloudVpc_ValidateServiceSubnetsNotUpdated(t *testing.T) {
 	assert.Contains(t, err.Error(), "setting can not be changed")
 }
 
+func TestCloudVpc_ValidateServiceSecurityGroupsNotUpdated(t *testing.T) {
+	lb := &VpcLoadBalancer{Name: "lbName", SecurityGroups: []VpcObjectReference{{ID: "sgID1", Name: "sg1"}}}
+	options := newServiceOptions()
+
+	// validateServiceSecurityGroupsNotUpdated, success - annotation not set
+	err := mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.Nil(t, err)
+
+	// validateServiceSecurityGroupsNotUpdated, success - no change in annotation, name or ID can be used
+	options.annotations[serviceAnnotationSecurityGroups] = "sg1"
+	err = mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.Nil(t, err)
+	options.annotations[serviceAnnotationSecurityGroups] = "sgID1"
+	err = mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.Nil(t, err)
+
+	// validateServiceSecurityGroupsNotUpdated, failed - security group added to the annotation
+	options.annotations[serviceAnnotationSecurityGroups] = "sg1,sg2"
+	err = mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "The load balancer was created with security groups sg1. This setting can not be changed")
+
+	// validateServiceSecurityGroupsNotUpdated, failed - managed security group requested after the load balancer was created
+	options.annotations[serviceAnnotationSecurityGroups] = "sg1"
+	options.enabledFeatures = LoadBalancerOptionManagedSecurityGroup
+	err = mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "setting can not be changed")
+
+	// validateServiceSecurityGroupsNotUpdated, success - managed security group is attached
+	lb.SecurityGroups = append(lb.SecurityGroups, VpcObjectReference{ID: "sgID2", Name: "lbName"})
+	err = mockCloud.validateServiceSecurityGroupsNotUpdated(options, lb)
+	assert.Nil(t, err)
+}
+
 func TestCloudVpc_ValidateServiceTypeNotUpdated(t *testing.T) {
 	lb := &VpcLoadBalancer{IsPublic: true}
 	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{
@@ -484,6 +1010,38 @@ func TestCloudVpc_ValidateServiceTypeNotUpdated(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "setting can not be changed")
 	lb.IsPublic = true
+	service.ObjectMeta.Annotations = map[string]string{}
+
+	// validateServiceTypeNotUpdated, failed - lb application, service UDP
+	service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053}}
+	options = mockCloud.getServiceOptions(service)
+	err = mockCloud.validateServiceTypeNotUpdated(options, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "created as an application load balancer and does not support UDP")
+
+	// validateServiceTypeNotUpdated, success - lb network, service UDP
+	lb.ProfileFamily = LoadBalancerProfileFamilyNetwork
+	err = mockCloud.validateServiceTypeNotUpdated(options, lb)
+	assert.Nil(t, err)
+
+	// validateServiceTypeNotUpdated, failed - lb network, service TCP
+	service.Spec.Ports[0].Protocol = v1.ProtocolTCP
+	options = mockCloud.getServiceOptions(service)
+	err = mockCloud.validateServiceTypeNotUpdated(options, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "created as a network load balancer")
+
+	// validateServiceTypeNotUpdated, success - lb network, service TCP with nlb annotation
+	service.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionNLB
+	options = mockCloud.getServiceOptions(service)
+	err = mockCloud.validateServiceTypeNotUpdated(options, lb)
+	assert.Nil(t, err)
+
+	// validateServiceTypeNotUpdated, failed - lb application, service TCP with nlb annotation
+	lb.ProfileFamily = "application"
+	err = mockCloud.validateServiceTypeNotUpdated(options, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "created as an application load balancer. This setting can not be changed")
 }
 
 func TestCloudVpc_ValidateServiceZone(t *testing.T) {
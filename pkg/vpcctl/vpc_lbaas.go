@@ -20,7 +20,10 @@
 package vpcctl
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,19 +32,26 @@ import (
 )
 
 const (
-	actionCreateListener     = "CREATE-LISTENER"
-	actionCreatePool         = "CREATE-POOL"
-	actionCreatePoolMember   = "CREATE-POOL-MEMBER"
-	actionDeleteListener     = "DELETE-LISTENER"
-	actionDeletePool         = "DELETE-POOL"
-	actionDeletePoolMember   = "DELETE-POOL-MEMBER"
-	actionReplacePoolMembers = "REPLACE-POOL-MEMBERS"
-	actionUpdatePool         = "UPDATE-POOL"
+	actionCreateListener      = "CREATE-LISTENER"
+	actionCreatePool          = "CREATE-POOL"
+	actionCreatePoolMember    = "CREATE-POOL-MEMBER"
+	actionDeleteListener      = "DELETE-LISTENER"
+	actionDeletePool          = "DELETE-POOL"
+	actionDeletePoolMember    = "DELETE-POOL-MEMBER"
+	actionReplacePoolMembers  = "REPLACE-POOL-MEMBERS"
+	actionUpdateListener      = "UPDATE-LISTENER"
+	actionUpdatePool          = "UPDATE-POOL"
+	actionUpdatePoolMember    = "UPDATE-POOL-MEMBER"
+	actionUpdateSecurityGroup = "UPDATE-SECURITY-GROUP"
 
 	poolToBeDeleted = "POOL-TO-BE-DELETED"
 )
 
-// checkForMultiplePoolMemberUpdates - replace multiple CREATE-POOL-MEMBER / DELETE-POOL-MEMBER actions with a single REPLACE-POOL-MEMBERS
+// errSecurityGroupDeletePending - the delete of the load balancer was started, but the managed security group can only
+// be deleted after the load balancer is gone
+var errSecurityGroupDeletePending = errors.New("Security group delete pending")
+
+// checkForMultiplePoolMemberUpdates - replace multiple CREATE-POOL-MEMBER / DELETE-POOL-MEMBER / UPDATE-POOL-MEMBER actions with a single REPLACE-POOL-MEMBERS
 //
 // Each time that a CREATE-POOL-MEMBER or DELETE-POOL-MEMBER operation needs to be done against an existing LB it takes 30 seconds.
 // If there are multiple of these operations queued up for a given LB pool, it is more efficient to do a single REPLACE-POOL-MEMBERS.
@@ -59,7 +69,7 @@ func (c *CloudVpc) checkForMultiplePoolMemberUpdates(updatesRequired []string) [
 		updateArgs := strings.Fields(update)
 		cmd := updateArgs[0]
 		poolName := updateArgs[1]
-		if cmd == actionCreatePoolMember || cmd == actionDeletePoolMember {
+		if cmd == actionCreatePoolMember || cmd == actionDeletePoolMember || cmd == actionUpdatePoolMember {
 			poolUpdates[poolName]++
 		}
 	}
@@ -83,7 +93,7 @@ func (c *CloudVpc) checkForMultiplePoolMemberUpdates(updatesRequired []string) [
 	for _, update := range updatesRequired {
 		updateArgs := strings.Fields(update)
 		cmd := updateArgs[0]
-		if cmd != actionCreatePoolMember && cmd != actionDeletePoolMember {
+		if cmd != actionCreatePoolMember && cmd != actionDeletePoolMember && cmd != actionUpdatePoolMember {
 			// Keep all non-pool member update operations
 			filteredUpdates = append(filteredUpdates, update)
 			continue
@@ -105,10 +115,14 @@ func (c *CloudVpc) checkForMultiplePoolMemberUpdates(updatesRequired []string) [
 	return filteredUpdates
 }
 
-// checkListenersForExtPortAddedToService - check to see if we have existing listener for the specified Kube service
-func (c *CloudVpc) checkListenersForExtPortAddedToService(updatesRequired []string, listeners []*VpcLoadBalancerListener, servicePort v1.ServicePort) []string {
+// checkListenersForExtPortAddedToService - check to see if we have existing listener for the external port and protocol of the Kube service
+func (c *CloudVpc) checkListenersForExtPortAddedToService(updatesRequired []string, listeners []*VpcLoadBalancerListener, servicePort v1.ServicePort, options *ServiceOptions) []string {
+	// The listener of a port range is handled by the first port of the range
+	if options.isPortRangeMember(int(servicePort.Port)) {
+		return updatesRequired
+	}
 	for _, listener := range listeners {
-		if c.isServicePortEqualListener(servicePort, listener) {
+		if c.isServicePortEqualListener(servicePort, listener, options) {
 			// Found an existing listener for the external port, no additional update needed
 			return updatesRequired
 		}
@@ -120,10 +134,10 @@ func (c *CloudVpc) checkListenersForExtPortAddedToService(updatesRequired []stri
 }
 
 // checkListenerForExtPortDeletedFromService - check if there is a Kube service for the specified listener
-func (c *CloudVpc) checkListenerForExtPortDeletedFromService(updatesRequired []string, listener *VpcLoadBalancerListener, ports []v1.ServicePort) []string {
-	// Search for a matching port
+func (c *CloudVpc) checkListenerForExtPortDeletedFromService(updatesRequired []string, listener *VpcLoadBalancerListener, ports []v1.ServicePort, options *ServiceOptions) []string {
+	// Search for a matching port. If the listener protocol no longer matches, the listener is deleted and then re-created
 	for _, kubePort := range ports {
-		if c.isServicePortEqualListener(kubePort, listener) {
+		if c.isServicePortEqualListener(kubePort, listener, options) {
 			// A service was found for the listener.  No updated needed.
 			return updatesRequired
 		}
@@ -139,7 +153,11 @@ func (c *CloudVpc) checkListenerForExtPortDeletedFromService(updatesRequired []s
 }
 
 // checkPoolsForExtPortAddedToService - check to see if we have existing pool for the specified Kube service
-func (c *CloudVpc) checkPoolsForExtPortAddedToService(updatesRequired []string, pools []*VpcLoadBalancerPool, servicePort v1.ServicePort) ([]string, error) {
+func (c *CloudVpc) checkPoolsForExtPortAddedToService(updatesRequired []string, pools []*VpcLoadBalancerPool, servicePort v1.ServicePort, options *ServiceOptions) ([]string, error) {
+	// The pool of a port range is handled by the first port of the range
+	if options.isPortRangeMember(int(servicePort.Port)) {
+		return updatesRequired, nil
+	}
 	poolName := genLoadBalancerPoolName(servicePort)
 	for _, pool := range pools {
 		if pool.Name == poolName {
@@ -164,14 +182,15 @@ func (c *CloudVpc) checkPoolsForExtPortAddedToService(updatesRequired []string,
 }
 
 // checkPoolForExtPortDeletedFromService - check to see if we have a Kube service for the specific pool
-func (c *CloudVpc) checkPoolForExtPortDeletedFromService(updatesRequired []string, pool *VpcLoadBalancerPool, ports []v1.ServicePort) ([]string, error) {
+func (c *CloudVpc) checkPoolForExtPortDeletedFromService(updatesRequired []string, pool *VpcLoadBalancerPool, ports []v1.ServicePort, options *ServiceOptions) ([]string, error) {
 	// Search through the service ports to find a matching external port
 	poolNameFields, err := extractFieldsFromPoolName(pool.Name)
 	if err != nil {
 		return updatesRequired, err
 	}
 	for _, kubePort := range ports {
-		if c.isServicePortEqualPoolName(kubePort, poolNameFields) {
+		// Ports that are part of a port range, other than the first port, no longer need their own pool
+		if c.isServicePortEqualPoolName(kubePort, poolNameFields) && !options.isPortRangeMember(int(kubePort.Port)) {
 			// Found a service for the pool, no additional update needed
 			return updatesRequired, nil
 		}
@@ -209,7 +228,7 @@ func (c *CloudVpc) checkPoolForNodesToAdd(updatesRequired []string, pool *VpcLoa
 	for _, nodeID := range nodeList {
 		foundMember := false
 		for _, member := range pool.Members {
-			memberTarget := member.TargetIPAddress
+			memberTarget := member.getTarget()
 			if nodeID == memberTarget && poolNameFields.NodePort == int(member.Port) {
 				// There is a pool member for this node.  Move on to the next node
 				foundMember = true
@@ -224,6 +243,40 @@ func (c *CloudVpc) checkPoolForNodesToAdd(updatesRequired []string, pool *VpcLoa
 	return updatesRequired, nil
 }
 
+// checkPoolForMemberWeights - check to see if the weight of any of the existing members of a VPC pool needs to be updated
+func (c *CloudVpc) checkPoolForMemberWeights(updatesRequired []string, pool *VpcLoadBalancerPool, ports []v1.ServicePort, options *ServiceOptions) ([]string, error) {
+	// If member weights were not requested or the pool was marked for deletion, don't bother checking the members
+	if !options.isMemberWeights() || pool.Name == poolToBeDeleted {
+		return updatesRequired, nil
+	}
+	// Extract the fields from the pool name
+	poolNameFields, err := extractFieldsFromPoolName(pool.Name)
+	if err != nil {
+		return updatesRequired, err
+	}
+	// Make sure that the node port of the pool is correct, i.e. generated poolName for Kube service must match actual pool name
+	for _, kubePort := range ports {
+		if c.isServicePortEqualPoolName(kubePort, poolNameFields) {
+			// Found the correct kube service port for the specified pool
+			if poolNameFields.NodePort != int(kubePort.NodePort) {
+				// Node port for the pool has changed.
+				// All members (nodes) will be refreshed by a REPLACE-POOL-MEMBERS update when checkPoolForServiceChanges() runs
+				return updatesRequired, nil
+			}
+		}
+	}
+	// Verify that the weight of each pool member matches the weight of the node. Members of nodes that are no longer
+	// in the node list are deleted by checkPoolForNodesToDelete()
+	for _, member := range pool.Members {
+		memberTarget := member.getTarget()
+		weight, ok := options.getMemberWeight(memberTarget)
+		if ok && poolNameFields.NodePort == int(member.Port) && member.Weight != weight {
+			updatesRequired = append(updatesRequired, fmt.Sprintf("%s %s %s %s %s %d", actionUpdatePoolMember, pool.Name, pool.ID, member.ID, memberTarget, weight))
+		}
+	}
+	return updatesRequired, nil
+}
+
 // checkPoolForNodesToDelete - check to see if any of the existing members of a VPC pool need to be deleted
 func (c *CloudVpc) checkPoolForNodesToDelete(updatesRequired []string, pool *VpcLoadBalancerPool, ports []v1.ServicePort, nodeList []string) ([]string, error) {
 	// If the pool was marked for deletion, don't bother checking the members
@@ -249,7 +302,7 @@ func (c *CloudVpc) checkPoolForNodesToDelete(updatesRequired []string, pool *Vpc
 	// Verify that each pool member refers to a node AND the node port in the member is correct
 	nodeString := " " + strings.Join(nodeList, " ") + " "
 	for _, member := range pool.Members {
-		memberTarget := member.TargetIPAddress
+		memberTarget := member.getTarget()
 		if !strings.Contains(nodeString, " "+memberTarget+" ") || poolNameFields.NodePort != int(member.Port) {
 			updatesRequired = append(updatesRequired, fmt.Sprintf("%s %s %s %s %s", actionDeletePoolMember, pool.Name, pool.ID, member.ID, memberTarget))
 		}
@@ -257,8 +310,82 @@ func (c *CloudVpc) checkPoolForNodesToDelete(updatesRequired []string, pool *Vpc
 	return updatesRequired, nil
 }
 
+// isHealthMonitorEqual - check to see if the actual health monitor of a pool matches the desired settings
+//
+// The type and the port are always checked. The delay, retries, timeout, and URL path are only checked if they are
+// set by a service annotation, so that pools created with the earlier default settings are not updated
+func (options *ServiceOptions) isHealthMonitorEqual(actual, desired VpcLoadBalancerPoolHealthMonitor) bool {
+	switch {
+	case actual.Type != desired.Type || actual.Port != desired.Port:
+		return false
+	case options.getAnnotationInt(serviceAnnotationHealthCheckDelay) > 0 && actual.Delay != desired.Delay:
+		return false
+	case options.getAnnotationInt(serviceAnnotationHealthCheckRetries) > 0 && actual.MaxRetries != desired.MaxRetries:
+		return false
+	case options.getAnnotationInt(serviceAnnotationHealthCheckTimeout) > 0 && actual.Timeout != desired.Timeout:
+		return false
+	// The URL path is only used by the HTTP and HTTPS health checks
+	case options.getHealthCheckPath() != "" && desired.Type != LoadBalancerProtocolTCP && actual.URLPath != desired.URLPath:
+		return false
+	}
+	return true
+}
+
+// isSecurityGroupRuleEqual - check if the actual security group rule matches the desired rule
+func isSecurityGroupRuleEqual(actual, desired *VpcSecurityGroupRule) bool {
+	return actual.Direction == desired.Direction &&
+		actual.PortMax == desired.PortMax &&
+		actual.PortMin == desired.PortMin &&
+		actual.Protocol == desired.Protocol &&
+		actual.Remote == desired.Remote
+}
+
+// checkListenerForServiceChanges - check to see if the connection, certificate, or redirect settings of the listener need to be updated
+func (c *CloudVpc) checkListenerForServiceChanges(updatesRequired []string, listener *VpcLoadBalancerListener, listeners []*VpcLoadBalancerListener, service *v1.Service) []string {
+	options := c.getServiceOptions(service)
+	for _, kubePort := range service.Spec.Ports {
+		if !c.isServicePortEqualListener(kubePort, listener, options) || options.isPortRangeMember(int(kubePort.Port)) {
+			// If this is not the correct Kube service port, move on to the next one
+			continue
+		}
+		desired := c.getDesiredListener(listener, listeners, options)
+		if listener.ConnectionLimit != desired.ConnectionLimit ||
+			listener.IdleConnectionTimeout != desired.IdleConnectionTimeout ||
+			listener.CertificateInstance != desired.CertificateInstance ||
+			listener.HTTPSRedirectListener != desired.HTTPSRedirectListener {
+			poolName := genLoadBalancerPoolName(kubePort)
+			updatesRequired = append(updatesRequired, fmt.Sprintf("%s %s %s", actionUpdateListener, poolName, listener.ID))
+		}
+		break
+	}
+	return updatesRequired
+}
+
+// getDesiredListener - return the settings requested by the service for an existing listener
+func (c *CloudVpc) getDesiredListener(listener *VpcLoadBalancerListener, listeners []*VpcLoadBalancerListener, options *ServiceOptions) *VpcLoadBalancerListener {
+	desired := *listener
+	desired.ConnectionLimit = options.getConnectionLimit()
+	desired.IdleConnectionTimeout = options.getIdleConnectionTimeout()
+	desired.CertificateInstance = ""
+	if listener.Protocol == LoadBalancerProtocolHTTPS {
+		desired.CertificateInstance = options.getCertificateCRN()
+	}
+	// The redirect can only be set once the HTTPS listener exists. Until then, the current setting is kept
+	redirectPort := options.getHTTPSRedirectPort(int(listener.Port))
+	if listener.Protocol != LoadBalancerProtocolHTTP || redirectPort == 0 {
+		desired.HTTPSRedirectListener = ""
+	}
+	for _, target := range listeners {
+		if listener.Protocol == LoadBalancerProtocolHTTP && int(target.Port) == redirectPort && target.Protocol == LoadBalancerProtocolHTTPS {
+			desired.HTTPSRedirectListener = target.ID
+			break
+		}
+	}
+	return &desired
+}
+
 // checkPoolForServiceChanges - check to see if we have a Kube service for the specific pool
-func (c *CloudVpc) checkPoolForServiceChanges(updatesRequired []string, pool *VpcLoadBalancerPool, service *v1.Service) ([]string, error) {
+func (c *CloudVpc) checkPoolForServiceChanges(updatesRequired []string, pool *VpcLoadBalancerPool, service *v1.Service, options *ServiceOptions) ([]string, error) {
 	// If the pool was marked for deletion, don't bother checking to see if needs to get updated
 	if pool.Name == poolToBeDeleted {
 		return updatesRequired, nil
@@ -277,25 +404,25 @@ func (c *CloudVpc) checkPoolForServiceChanges(updatesRequired []string, pool *Vp
 		poolName := genLoadBalancerPoolName(kubePort)
 		updatePool := false
 		replacePoolMembers := false
-		options := c.getServiceOptions(service)
-		proxyProtocolRequested := options.isProxyProtocol()
+		healthMonitor := options.getHealthMonitor(genLoadBalancerPoolNameFields(kubePort))
 		switch {
 		case poolName != pool.Name:
 			updatePool = true
 			replacePoolMembers = true
 
-		case service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && service.Spec.HealthCheckNodePort > 0 &&
-			(pool.HealthMonitor.Type != LoadBalancerProtocolHTTP || pool.HealthMonitor.Port != int64(service.Spec.HealthCheckNodePort)):
+		case !options.isHealthMonitorEqual(pool.HealthMonitor, healthMonitor):
 			updatePool = true
 
-		case service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeCluster &&
-			(pool.HealthMonitor.Type != LoadBalancerProtocolTCP || pool.HealthMonitor.Port != int64(kubePort.NodePort)):
+		case pool.Algorithm != options.getPoolAlgorithm():
 			updatePool = true
 
-		case proxyProtocolRequested && pool.ProxyProtocol != LoadBalancerProxyProtocolV1:
+		case pool.SessionPersistence != options.getSessionPersistence():
 			updatePool = true
 
-		case !proxyProtocolRequested && pool.ProxyProtocol != LoadBalancerProxyProtocolDisabled:
+		case pool.ProxyProtocol != options.getProxyProtocol():
+			updatePool = true
+
+		case pool.Protocol != options.getPoolProtocol(genLoadBalancerPoolNameFields(kubePort)):
 			updatePool = true
 		}
 
@@ -310,6 +437,30 @@ func (c *CloudVpc) checkPoolForServiceChanges(updatesRequired []string, pool *Vp
 	return updatesRequired, nil
 }
 
+// checkSecurityGroupForServiceChanges - check if the rules of the managed security group match the ports and source ranges of the service
+func (c *CloudVpc) checkSecurityGroupForServiceChanges(updatesRequired []string, securityGroup *VpcSecurityGroup, service *v1.Service, options *ServiceOptions) []string {
+	if securityGroup == nil {
+		return updatesRequired
+	}
+	desiredRules := c.getServiceSecurityGroupRules(service, options)
+	if len(desiredRules) == len(securityGroup.Rules) {
+		rulesFound := 0
+		for _, desired := range desiredRules {
+			for _, actual := range securityGroup.Rules {
+				if isSecurityGroupRuleEqual(actual, desired) {
+					rulesFound++
+					break
+				}
+			}
+		}
+		if rulesFound == len(desiredRules) {
+			return updatesRequired
+		}
+	}
+	updatesRequired = append(updatesRequired, fmt.Sprintf("%s %s %s", actionUpdateSecurityGroup, securityGroup.Name, securityGroup.ID))
+	return updatesRequired
+}
+
 // CreateLoadBalancer - create a VPC load balancer
 func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes []*v1.Node) (*VpcLoadBalancer, error) {
 	if lbName == "" || service == nil || nodes == nil {
@@ -322,6 +473,17 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 		return nil, err
 	}
 
+	// The IP families and loadBalancerIP are only rejected when the load balancer is created, an existing load balancer
+	// keeps being updated
+	err = c.validateServiceIPFamilies(service)
+	if err != nil {
+		return nil, err
+	}
+	err = c.validateServiceLoadBalancerIP(service)
+	if err != nil {
+		return nil, err
+	}
+
 	// Determine what VPC subnets to associate with this load balancer
 	allSubnets, err := c.Sdk.ListSubnets()
 	if err != nil {
@@ -335,8 +497,8 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 	subnetList := c.getSubnetIDs(clusterSubnets)
 	serviceSubnets := options.getServiceSubnets()
 	serviceZone := options.getServiceZone()
+	vpcID := clusterSubnets[0].Vpc.ID
 	if serviceSubnets != "" {
-		vpcID := clusterSubnets[0].Vpc.ID
 		subnetList, err = c.validateServiceSubnets(service, serviceSubnets, vpcID, vpcSubnets)
 	} else if serviceZone != "" {
 		subnetList, err = c.validateServiceZone(service, serviceZone, clusterSubnets)
@@ -346,6 +508,12 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 	}
 	klog.Infof("Subnets: %+v", subnetList)
 
+	// Network load balancers can only be placed in a single subnet
+	if options.isNLB() && len(subnetList) != 1 {
+		return nil, fmt.Errorf("Service %s/%s requires a network load balancer which must be placed in a single VPC subnet. Use the %s or %s annotation to select the subnet",
+			service.ObjectMeta.Namespace, service.ObjectMeta.Name, serviceAnnotationSubnets, serviceAnnotationZone)
+	}
+
 	// Filter node list by the service annotations (if specified) and node edge label (if set)
 	filterLabel, filterValue := c.getServiceNodeSelectorFilter(service)
 	if filterLabel != "" {
@@ -358,8 +526,8 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 		return nil, fmt.Errorf("There are no available nodes for this service")
 	}
 
-	// Determine the IP address for each of the nodes
-	nodeList := c.getNodeIDs(nodes)
+	// Determine the IP address (or instance ID) for each of the nodes
+	nodeList := c.getNodeIDs(nodes, vpcSubnets, options)
 	klog.Infof("Nodes: %v", nodeList)
 
 	// Determine what ports are associated with the service
@@ -370,8 +538,17 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 	}
 	klog.Infof("Pools: %+v", poolList)
 
+	// Determine what security groups to attach to the load balancer
+	securityGroupList, err := c.getServiceSecurityGroups(lbName, service, options, vpcID)
+	if err != nil {
+		return nil, err
+	}
+	if len(securityGroupList) > 0 {
+		klog.Infof("Security groups: %+v", securityGroupList)
+	}
+
 	// Create the load balancer
-	lb, err := c.Sdk.CreateLoadBalancer(lbName, nodeList, poolList, subnetList, options)
+	lb, err := c.Sdk.CreateLoadBalancer(lbName, nodeList, poolList, subnetList, securityGroupList, options)
 	if err != nil {
 		return nil, err
 	}
@@ -379,7 +556,7 @@ func (c *CloudVpc) CreateLoadBalancer(lbName string, service *v1.Service, nodes
 }
 
 // createLoadBalancerListener - create a VPC load balancer listener
-func (c *CloudVpc) createLoadBalancerListener(lb *VpcLoadBalancer, poolName string) error {
+func (c *CloudVpc) createLoadBalancerListener(lb *VpcLoadBalancer, poolName string, options *ServiceOptions) error {
 	poolID := ""
 	for _, pool := range lb.Pools {
 		if poolName == pool.Name {
@@ -390,7 +567,7 @@ func (c *CloudVpc) createLoadBalancerListener(lb *VpcLoadBalancer, poolName stri
 	if poolID == "" {
 		return fmt.Errorf("Unable to create listener. Pool %s not found", poolName)
 	}
-	_, err := c.Sdk.CreateLoadBalancerListener(lb.ID, poolName, poolID)
+	_, err := c.Sdk.CreateLoadBalancerListener(lb.ID, poolName, poolID, options)
 	return err
 }
 
@@ -401,7 +578,7 @@ func (c *CloudVpc) createLoadBalancerPool(lb *VpcLoadBalancer, poolName string,
 }
 
 // createLoadBalancerPoolMember - create a VPC load balancer pool member
-func (c *CloudVpc) createLoadBalancerPoolMember(lb *VpcLoadBalancer, args string) error {
+func (c *CloudVpc) createLoadBalancerPoolMember(lb *VpcLoadBalancer, args string, options *ServiceOptions) error {
 	argsArray := strings.Fields(args)
 	if lb == nil || len(argsArray) != 3 {
 		return fmt.Errorf("Required argument is missing")
@@ -409,7 +586,7 @@ func (c *CloudVpc) createLoadBalancerPoolMember(lb *VpcLoadBalancer, args string
 	poolName := argsArray[0]
 	poolID := argsArray[1]
 	nodeID := argsArray[2]
-	_, err := c.Sdk.CreateLoadBalancerPoolMember(lb.ID, poolName, poolID, nodeID)
+	_, err := c.Sdk.CreateLoadBalancerPoolMember(lb.ID, poolName, poolID, nodeID, options)
 	return err
 }
 
@@ -418,7 +595,27 @@ func (c *CloudVpc) DeleteLoadBalancer(lb *VpcLoadBalancer, service *v1.Service)
 	if lb == nil {
 		return fmt.Errorf("Required argument is missing")
 	}
-	return c.Sdk.DeleteLoadBalancer(lb.ID)
+	// The delete of the load balancer may have already been started by an earlier request
+	if lb.ProvisioningStatus != LoadBalancerProvisioningStatusDeletePending {
+		err := c.Sdk.DeleteLoadBalancer(lb.ID)
+		if err != nil {
+			return err
+		}
+	}
+	// The managed security group can not be deleted until it is no longer attached to the load balancer. Instead of
+	// waiting for the load balancer to be deleted, a retryable error is returned. The security group is deleted by
+	// EnsureLoadBalancerDeleted() once the load balancer is gone, or by MonitorLoadBalancers() for a stale load balancer.
+	// The service annotations are not checked, the service may no longer exist or the annotation may have been removed
+	securityGroup, err := c.findLoadBalancerSecurityGroup(lb.Name)
+	if err != nil {
+		return err
+	}
+	if securityGroup != nil {
+		c.setSecurityGroupDeletePending(lb.Name, true)
+		return fmt.Errorf("%w: Load balancer %s is being deleted, security group %s will be deleted after the load balancer",
+			errSecurityGroupDeletePending, lb.Name, securityGroup.ID)
+	}
+	return nil
 }
 
 // deleteLoadBalancerListener - delete a VPC load balancer listener
@@ -456,6 +653,92 @@ func (c *CloudVpc) deleteLoadBalancerPoolMember(lb *VpcLoadBalancer, args string
 	return c.Sdk.DeleteLoadBalancerPoolMember(lb.ID, poolID, memberID)
 }
 
+// deleteLoadBalancerSecurityGroup - delete the security group that was created for the load balancer (if one exists)
+func (c *CloudVpc) deleteLoadBalancerSecurityGroup(lbName string) error {
+	securityGroup, err := c.findLoadBalancerSecurityGroup(lbName)
+	if err != nil {
+		return err
+	}
+	if securityGroup != nil {
+		klog.Infof("Deleting security group %s", securityGroup.ID)
+		err = c.Sdk.DeleteSecurityGroup(securityGroup.ID)
+		if err != nil {
+			return err
+		}
+	}
+	c.setSecurityGroupDeletePending(lbName, false)
+	return nil
+}
+
+// deleteStaleSecurityGroups - delete the managed security groups of the load balancers that were deleted by the cloud
+// provider and are no longer used by a VPC load balancer or a Kubernetes service. This finishes the cleanup of the stale
+// load balancers deleted by GatherLoadBalancers(). Only the security groups named after a load balancer that the cloud
+// provider deleted are considered, so the other security groups of the cluster are never deleted
+func (c *CloudVpc) deleteStaleSecurityGroups(inUse map[string]bool) error {
+	c.securityGroupDeletesLock.Lock()
+	lbNames := []string{}
+	for lbName := range c.securityGroupDeletes {
+		if !inUse[lbName] {
+			lbNames = append(lbNames, lbName)
+		}
+	}
+	c.securityGroupDeletesLock.Unlock()
+	sort.Strings(lbNames)
+	for _, lbName := range lbNames {
+		klog.Infof("Deleting security group of stale VPC LB %s", lbName)
+		err := c.deleteLoadBalancerSecurityGroup(lbName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSecurityGroupDeletePending - track whether the managed security group of the load balancer still needs to be
+// deleted after the load balancer is gone
+func (c *CloudVpc) setSecurityGroupDeletePending(lbName string, pending bool) {
+	c.securityGroupDeletesLock.Lock()
+	defer c.securityGroupDeletesLock.Unlock()
+	if !pending {
+		delete(c.securityGroupDeletes, lbName)
+		return
+	}
+	if c.securityGroupDeletes == nil {
+		c.securityGroupDeletes = map[string]bool{}
+	}
+	c.securityGroupDeletes[lbName] = true
+}
+
+// findLoadBalancerSecurityGroup - find the security group that was created for the load balancer. Nil is returned if
+// the load balancer does not have a managed security group
+func (c *CloudVpc) findLoadBalancerSecurityGroup(lbName string) (*VpcSecurityGroup, error) {
+	securityGroups, err := c.listVpcSecurityGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, securityGroup := range securityGroups {
+		if securityGroup.Name == lbName {
+			return securityGroup, nil
+		}
+	}
+	return nil, nil
+}
+
+// listVpcSecurityGroups - list the security groups of the cluster VPC. If the subnets of the VPC can not be found,
+// there are no security groups that were created by the cloud provider
+func (c *CloudVpc) listVpcSecurityGroups() ([]*VpcSecurityGroup, error) {
+	allSubnets, err := c.Sdk.ListSubnets()
+	if err != nil {
+		return nil, err
+	}
+	vpcSubnets := c.filterSubnetsByVpcName(allSubnets, c.Config.VpcName)
+	if len(vpcSubnets) == 0 {
+		klog.Warningf("None of the subnets of VPC %s were found", c.Config.VpcName)
+		return nil, nil
+	}
+	return c.Sdk.ListSecurityGroups(vpcSubnets[0].Vpc.ID)
+}
+
 // FindLoadBalancer - locate a VPC load balancer based on the Name, ID, or hostname
 func (c *CloudVpc) FindLoadBalancer(nameID string, service *v1.Service) (*VpcLoadBalancer, error) {
 	if nameID == "" {
@@ -473,22 +756,86 @@ func (c *CloudVpc) FindLoadBalancer(nameID string, service *v1.Service) (*VpcLoa
 	return nil, nil
 }
 
-// GetLoadBalancerStatus returns the load balancer status for a given VPC host name
+// GetLoadBalancerStatus returns the load balancer status for a given VPC host name. The IPs of a network load
+// balancer are static for the life of the load balancer, so they are reported along with the host name. The IPs
+// of an application load balancer are only reported if the publish-ips option was specified on the service.
+//
+// A network load balancer passes the client traffic through to the nodes, so kube-proxy can route traffic sent to
+// the IPs directly (ipMode VIP). An application load balancer terminates the client connection, so traffic sent to
+// the IPs from inside the cluster must go through the load balancer (ipMode Proxy)
 func (c *CloudVpc) GetLoadBalancerStatus(service *v1.Service, lb *VpcLoadBalancer) *v1.LoadBalancerStatus {
 	lbStatus := &v1.LoadBalancerStatus{}
 	lbStatus.Ingress = []v1.LoadBalancerIngress{{Hostname: lb.Hostname}}
+	if !lb.IsNLB() && !c.getServiceOptions(service).isPublishIPs() {
+		return lbStatus
+	}
+	for _, ip := range lb.getIPs() {
+		ipMode := v1.LoadBalancerIPModeVIP
+		if !lb.IsNLB() {
+			ipMode = v1.LoadBalancerIPModeProxy
+		}
+		lbStatus.Ingress = append(lbStatus.Ingress, v1.LoadBalancerIngress{IP: ip, IPMode: &ipMode})
+	}
 	return lbStatus
 }
 
+// getServiceSecurityGroups - return the IDs of the security groups to attach to the load balancer. The security groups in the
+// annotation must already exist in the VPC. If the managed security group was requested, it is created for the load balancer
+func (c *CloudVpc) getServiceSecurityGroups(lbName string, service *v1.Service, options *ServiceOptions, vpcID string) ([]string, error) {
+	securityGroupList := []string{}
+	requested := options.getSecurityGroups()
+	if len(requested) == 0 && !options.isManagedSecurityGroup() {
+		return securityGroupList, nil
+	}
+	securityGroups, err := c.Sdk.ListSecurityGroups(vpcID)
+	if err != nil {
+		return nil, err
+	}
+	for _, nameID := range requested {
+		var found *VpcSecurityGroup
+		for _, securityGroup := range securityGroups {
+			if nameID == securityGroup.ID || nameID == securityGroup.Name {
+				found = securityGroup
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("The annotation %s on service %s/%s contains security group %s which was not found in the VPC",
+				serviceAnnotationSecurityGroups, service.ObjectMeta.Namespace, service.ObjectMeta.Name, nameID)
+		}
+		securityGroupList = append(securityGroupList, found.ID)
+	}
+	if !options.isManagedSecurityGroup() {
+		return securityGroupList, nil
+	}
+	// The managed security group may be left over from an earlier attempt to create the load balancer
+	desiredRules := c.getServiceSecurityGroupRules(service, options)
+	for _, securityGroup := range securityGroups {
+		if securityGroup.Name == lbName {
+			err = c.updateSecurityGroupRules(securityGroup, desiredRules)
+			if err != nil {
+				return nil, err
+			}
+			return append(securityGroupList, securityGroup.ID), nil
+		}
+	}
+	klog.Infof("Creating security group %s", lbName)
+	securityGroup, err := c.Sdk.CreateSecurityGroup(lbName, vpcID, desiredRules)
+	if err != nil {
+		return nil, err
+	}
+	return append(securityGroupList, securityGroup.ID), nil
+}
+
 // replaceLoadBalancerPoolMembers - replace the load balancer pool members
-func (c *CloudVpc) replaceLoadBalancerPoolMembers(lb *VpcLoadBalancer, args string, nodeList []string) error {
+func (c *CloudVpc) replaceLoadBalancerPoolMembers(lb *VpcLoadBalancer, args string, nodeList []string, options *ServiceOptions) error {
 	argsArray := strings.Fields(args)
 	if lb == nil || len(argsArray) != 2 {
 		return fmt.Errorf("Required argument is missing")
 	}
 	poolName := argsArray[0]
 	poolID := argsArray[1]
-	_, err := c.Sdk.ReplaceLoadBalancerPoolMembers(lb.ID, poolName, poolID, nodeList)
+	_, err := c.Sdk.ReplaceLoadBalancerPoolMembers(lb.ID, poolName, poolID, nodeList, options)
 	return err
 }
 
@@ -528,6 +875,12 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 		return nil, err
 	}
 
+	// If the security groups of the service have been changed, detect this case and return error
+	err = c.validateServiceSecurityGroupsNotUpdated(options, lb)
+	if err != nil {
+		return nil, err
+	}
+
 	// Verify that there are nodes available to associate with this load balancer
 	filterLabel, filterValue := c.getServiceNodeSelectorFilter(service)
 	if filterLabel != "" {
@@ -552,8 +905,23 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 		return nil, err
 	}
 
+	// Retrieve the security group that is managed for the current load balancer
+	var securityGroup *VpcSecurityGroup
+	if options.isManagedSecurityGroup() {
+		securityGroups, err := c.Sdk.ListSecurityGroups(lb.getVpcID(vpcSubnets))
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range securityGroups {
+			if item.Name == lb.Name {
+				securityGroup = item
+				break
+			}
+		}
+	}
+
 	// Determine the node list
-	nodeList := c.getNodeIDs(nodes)
+	nodeList := c.getNodeIDs(nodes, vpcSubnets, options)
 
 	// The following array is going to be used to keep track of ALL of the updates that need to be done
 	// There will be 1 line of text for each update that needs to be done.
@@ -564,23 +932,30 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 	// Update operations must be performed in a specific order.  Rules concering the supported operations:
 	//   1. DELETE-LISTENER must be done before the pool can be cleaned up with DELETE-POOL
 	//   2. CREATE-POOL must be done before the pool can be referenced by an CREATE-LISTENER
-	//   3. CREATE-LISTENER can not be done for an external port that is being used by an existing listener
+	//   3. CREATE-LISTENER can not be done for an external port and protocol that is being used by an existing listener.
+	//      A TCP and a UDP listener can share the same external port, each one pointing to the pool of its own protocol.
+	//      The ports of a port range share a single listener and pool, which belong to the first port of the range.
+	//      If the port range changes, the listener is deleted and re-created
 	//   4. Since any CREATE operations cause cause us to hit the account quota, all CREATE operations will be done last
 	//   5. No need to CREATE-POOL-MEMBER or DELETE-POOL-MEMBER if the entire pool was tagged to be deleted by a DELETE-POOL
-	//   6. UPDATE-POOL handles updating the health check settings on the pool and/or changing the name of pool (node port change)
+	//   6. UPDATE-POOL handles updating the health check, algorithm, session persistence, PROXY protocol, and protocol settings on the pool and/or changing the name of pool (node port change)
 	//   7. REPLACE-POOL-MEMBERS handles updating the node port of all the pool members
-	//   8. The listener is never updated. The listener will always points to the same pool once it has been created
+	//   8. UPDATE-LISTENER handles updating the connection limit, idle connection timeout, certificate, and HTTPS redirect of the listener.
+	//      The listener will always point to the same pool once it has been created. If the protocol of the listener needs to change
+	//      (TCP, HTTP, HTTPS), the listener is deleted, the pool protocol is changed by UPDATE-POOL, and the listener is re-created
 	//   9. The load balancer object is never updated or modified.  All update processing is done on the listeners, pools, and members
+	//  10. UPDATE-SECURITY-GROUP handles updating the rules of the managed security group. The security groups attached to the load balancer are not changed
+	//  11. UPDATE-POOL-MEMBER handles updating the weight of an existing pool member
 	updatesRequired := []string{}
 
-	// Step 1: Delete the VPC LB listener if the Kube service external port was deleted
+	// Step 1: Delete the VPC LB listener if the Kube service external port was deleted -OR- if the listener protocol was changed
 	for _, listener := range listeners {
-		updatesRequired = c.checkListenerForExtPortDeletedFromService(updatesRequired, listener, service.Spec.Ports)
+		updatesRequired = c.checkListenerForExtPortDeletedFromService(updatesRequired, listener, service.Spec.Ports, options)
 	}
 
 	// Step 2: Delete the VPC LB pool if the Kube service external port was deleted
 	for _, pool := range pools {
-		updatesRequired, err = c.checkPoolForExtPortDeletedFromService(updatesRequired, pool, service.Spec.Ports)
+		updatesRequired, err = c.checkPoolForExtPortDeletedFromService(updatesRequired, pool, service.Spec.Ports, options)
 		if err != nil {
 			return nil, err
 		}
@@ -594,34 +969,46 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 		}
 	}
 
-	// Step 4: Update the existing pools and pool members if the Kube service node port was changed -OR- if the externalTrafficPolicy was changed
+	// Step 4: Update the existing listeners, pools, and pool members if the Kube service node port was changed -OR-
+	// if the externalTrafficPolicy was changed -OR- if the service annotations were changed
+	for _, listener := range listeners {
+		updatesRequired = c.checkListenerForServiceChanges(updatesRequired, listener, listeners, service)
+	}
 	for _, pool := range pools {
-		updatesRequired, err = c.checkPoolForServiceChanges(updatesRequired, pool, service)
+		updatesRequired, err = c.checkPoolForServiceChanges(updatesRequired, pool, service, options)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Step 5: Create new VPC LB pool members if new nodes were added to the cluster
+	// Step 5: Create new VPC LB pool members if new nodes were added to the cluster -OR- update the weight of the
+	// existing pool members if member weights were requested
 	for _, pool := range pools {
 		updatesRequired, err = c.checkPoolForNodesToAdd(updatesRequired, pool, service.Spec.Ports, nodeList)
 		if err != nil {
 			return nil, err
 		}
+		updatesRequired, err = c.checkPoolForMemberWeights(updatesRequired, pool, service.Spec.Ports, options)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Step 6: Create a new VPC LB pool if a new external port was added to the Kube service
 	for _, servicePort := range service.Spec.Ports {
-		updatesRequired, err = c.checkPoolsForExtPortAddedToService(updatesRequired, pools, servicePort)
+		updatesRequired, err = c.checkPoolsForExtPortAddedToService(updatesRequired, pools, servicePort, options)
 		if err != nil {
 			return nil, err
 		}
 
 		// Step 7: Create a new VPC LB listener if a new external port was added to the Kube service
-		updatesRequired = c.checkListenersForExtPortAddedToService(updatesRequired, listeners, servicePort)
+		updatesRequired = c.checkListenersForExtPortAddedToService(updatesRequired, listeners, servicePort, options)
 	}
 
-	// Step 8: Replace multiple CREATE-POOL-MEMBER / DELETE-POOL-MEMBER actions with a single REPLACE-POOL-MEMBERS
+	// Step 8: Update the rules of the managed security group if the ports or the source ranges of the Kube service were changed
+	updatesRequired = c.checkSecurityGroupForServiceChanges(updatesRequired, securityGroup, service, options)
+
+	// Step 9: Replace multiple CREATE-POOL-MEMBER / DELETE-POOL-MEMBER / UPDATE-POOL-MEMBER actions with a single REPLACE-POOL-MEMBERS
 	updatesRequired = c.checkForMultiplePoolMemberUpdates(updatesRequired)
 
 	// If no updates are required, then return
@@ -662,21 +1049,27 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 		args := strings.TrimSpace(strings.TrimPrefix(update, action))
 		switch action {
 		case actionCreateListener:
-			err = c.createLoadBalancerListener(lb, args)
+			err = c.createLoadBalancerListener(lb, args, options)
 		case actionCreatePool:
 			err = c.createLoadBalancerPool(lb, args, nodeList, options)
 		case actionCreatePoolMember:
-			err = c.createLoadBalancerPoolMember(lb, args)
+			err = c.createLoadBalancerPoolMember(lb, args, options)
 		case actionDeleteListener:
 			err = c.deleteLoadBalancerListener(lb, args)
 		case actionDeletePool:
 			err = c.deleteLoadBalancerPool(lb, args)
 		case actionDeletePoolMember:
 			err = c.deleteLoadBalancerPoolMember(lb, args)
+		case actionUpdateListener:
+			err = c.updateLoadBalancerListener(lb, args, options)
 		case actionUpdatePool:
 			err = c.updateLoadBalancerPool(lb, args, pools, options)
+		case actionUpdatePoolMember:
+			err = c.updateLoadBalancerPoolMember(lb, args)
+		case actionUpdateSecurityGroup:
+			err = c.updateSecurityGroup(args, securityGroup, service, options)
 		case actionReplacePoolMembers:
-			err = c.replaceLoadBalancerPoolMembers(lb, args, nodeList)
+			err = c.replaceLoadBalancerPoolMembers(lb, args, nodeList, options)
 		default:
 			err = fmt.Errorf("Unsupported update operation: %s", update)
 		}
@@ -691,6 +1084,33 @@ func (c *CloudVpc) UpdateLoadBalancer(lb *VpcLoadBalancer, service *v1.Service,
 	return lb, nil
 }
 
+// updateLoadBalancerListener - update the connection, certificate, and redirect settings of a VPC load balancer listener
+func (c *CloudVpc) updateLoadBalancerListener(lb *VpcLoadBalancer, args string, options *ServiceOptions) error {
+	argsArray := strings.Fields(args)
+	if lb == nil || len(argsArray) != 2 {
+		return fmt.Errorf("Required argument is missing")
+	}
+	// poolName := argsArray[0]
+	listenerID := argsArray[1]
+	// Retrieve the current listeners, the HTTPS listener of a redirect may have been created by an earlier update
+	listeners, err := c.Sdk.ListLoadBalancerListeners(lb.ID)
+	if err != nil {
+		return err
+	}
+	var existingListener *VpcLoadBalancerListener
+	for _, listener := range listeners {
+		if listener.ID == listenerID {
+			existingListener = listener
+			break
+		}
+	}
+	if existingListener == nil {
+		return fmt.Errorf("Existing listener not found for listener ID: %s", listenerID)
+	}
+	_, err = c.Sdk.UpdateLoadBalancerListener(lb.ID, existingListener, c.getDesiredListener(existingListener, listeners, options))
+	return err
+}
+
 // updateLoadBalancerPool - create a VPC load balancer pool
 func (c *CloudVpc) updateLoadBalancerPool(lb *VpcLoadBalancer, args string, pools []*VpcLoadBalancerPool, options *ServiceOptions) error {
 	argsArray := strings.Fields(args)
@@ -713,6 +1133,74 @@ func (c *CloudVpc) updateLoadBalancerPool(lb *VpcLoadBalancer, args string, pool
 	return err
 }
 
+// updateLoadBalancerPoolMember - update the weight of a VPC load balancer pool member
+func (c *CloudVpc) updateLoadBalancerPoolMember(lb *VpcLoadBalancer, args string) error {
+	argsArray := strings.Fields(args)
+	if lb == nil || len(argsArray) != 5 {
+		return fmt.Errorf("Required argument is missing")
+	}
+	// poolName := argsArray[0]
+	poolID := argsArray[1]
+	memberID := argsArray[2]
+	// nodeID := argsArray[3]
+	weight, err := strconv.ParseInt(argsArray[4], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid pool member weight: %s", argsArray[4])
+	}
+	_, err = c.Sdk.UpdateLoadBalancerPoolMember(lb.ID, poolID, memberID, weight)
+	return err
+}
+
+// updateSecurityGroup - update the rules of the managed security group
+func (c *CloudVpc) updateSecurityGroup(args string, securityGroup *VpcSecurityGroup, service *v1.Service, options *ServiceOptions) error {
+	argsArray := strings.Fields(args)
+	if len(argsArray) != 2 {
+		return fmt.Errorf("Required argument is missing")
+	}
+	// sgName := argsArray[0]
+	sgID := argsArray[1]
+	if securityGroup == nil || securityGroup.ID != sgID {
+		return fmt.Errorf("Existing security group not found for security group ID: %s", sgID)
+	}
+	return c.updateSecurityGroupRules(securityGroup, c.getServiceSecurityGroupRules(service, options))
+}
+
+// updateSecurityGroupRules - update the rules of the security group to match the desired rules. The new rules are
+// created before the old rules are deleted, so that the traffic that is still allowed is not interrupted
+func (c *CloudVpc) updateSecurityGroupRules(securityGroup *VpcSecurityGroup, desiredRules []*VpcSecurityGroupRule) error {
+	for _, desired := range desiredRules {
+		found := false
+		for _, actual := range securityGroup.Rules {
+			if isSecurityGroupRuleEqual(actual, desired) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			_, err := c.Sdk.CreateSecurityGroupRule(securityGroup.ID, desired)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, actual := range securityGroup.Rules {
+		found := false
+		for _, desired := range desiredRules {
+			if isSecurityGroupRuleEqual(actual, desired) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			err := c.Sdk.DeleteSecurityGroupRule(securityGroup.ID, actual.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // WaitLoadBalancerReady will call the Get() operation on the load balancer every minSleep seconds until the state
 // of the load balancer goes to Online/Active -OR- until the maxWait timeout occurs
 func (c *CloudVpc) WaitLoadBalancerReady(lb *VpcLoadBalancer, minSleep, maxWait int) (*VpcLoadBalancer, error) {
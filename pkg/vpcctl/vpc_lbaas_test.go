@@ -20,6 +20,7 @@
 package vpcctl
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,14 +51,30 @@ func TestCloudVpc_CreateLoadBalancer(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Required argument is missing")
 
-	// Create load balancer failed, service = UDP load balancer
-	service.Spec.Ports[0].Protocol = v1.ProtocolUDP
+	// Create load balancer failed, service = SCTP load balancer
+	service.Spec.Ports[0].Protocol = v1.ProtocolSCTP
 	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{})
 	assert.Nil(t, lb)
 	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "Service default/echo-server is a UDP load balancer")
+	assert.Contains(t, err.Error(), "Service default/echo-server is a SCTP load balancer")
 	service.Spec.Ports[0].Protocol = v1.ProtocolTCP
 
+	// Create load balancer failed, service requests IPv6
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "requests IP family IPv6")
+	service.Spec.IPFamilies = nil
+
+	// Create load balancer failed, service requests a load balancer IP
+	service.Spec.LoadBalancerIP = "192.168.0.1"
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "requests load balancer IP 192.168.0.1")
+	service.Spec.LoadBalancerIP = ""
+
 	// Create load balancer failed, SDK call to list subnets failed
 	c.SetFakeSdkError("ListSubnets")
 	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
@@ -107,6 +124,34 @@ func TestCloudVpc_CreateLoadBalancer(t *testing.T) {
 	assert.NotNil(t, lb)
 	assert.Nil(t, err)
 
+	// Create load balancer - SUCCESS, application load balancer
+	assert.False(t, lb.IsNLB())
+
+	// Create load balancer - SUCCESS, network load balancer requested by annotation
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionNLB}
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.True(t, lb.IsNLB())
+	service.ObjectMeta.Annotations = map[string]string{}
+
+	// Create load balancer - SUCCESS, UDP service with a network load balancer in a single subnet
+	service.Spec.Ports[0].Protocol = v1.ProtocolUDP
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.True(t, lb.IsNLB())
+
+	// Create load balancer failed, UDP service with a network load balancer in multiple subnets
+	c.Sdk.(*VpcSdkFake).Subnet2.Vpc = c.Sdk.(*VpcSdkFake).Subnet1.Vpc
+	c.Config.SubnetNames = "subnet1,subnetVpc2"
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be placed in a single VPC subnet")
+	c.Config.SubnetNames = "subnet1"
+	service.Spec.Ports[0].Protocol = v1.ProtocolTCP
+
 	// SDK create load balancer operation failed
 	c.SetFakeSdkError("CreateLoadBalancer")
 	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
@@ -115,6 +160,219 @@ func TestCloudVpc_CreateLoadBalancer(t *testing.T) {
 	assert.Equal(t, err.Error(), "CreateLoadBalancer failed")
 }
 
+func TestCloudVpc_CreateLoadBalancerSecurityGroups(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.0.1", Labels: map[string]string{nodeLabelZone: "zoneA"}}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.0.1", Type: v1.NodeInternalIP}}}}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "1234"},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 31000}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(),
+		&ConfigVpc{
+			ClusterID:    "clusterID",
+			ProviderType: VpcProviderTypeFake,
+			SubnetNames:  "subnet1",
+			VpcName:      "vpc",
+		}, nil)
+
+	// Create load balancer failed, security group in the annotation was not found
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationSecurityGroups: "missing"}
+	lb, err := c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "contains security group missing which was not found in the VPC")
+
+	// Create load balancer failed, SDK list security groups operation failed
+	c.SetFakeSdkError("ListSecurityGroups")
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "ListSecurityGroups failed")
+	c.ClearFakeSdkError("ListSecurityGroups")
+
+	// Create load balancer - SUCCESS, security group specified by name
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationSecurityGroups: "securityGroup"}
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.Equal(t, lb.SecurityGroups, []VpcObjectReference{{ID: "securityGroupID"}})
+
+	// Create load balancer failed, SDK create security group operation failed
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionManagedSecurityGroup}
+	c.SetFakeSdkError("CreateSecurityGroup")
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "CreateSecurityGroup failed")
+	c.ClearFakeSdkError("CreateSecurityGroup")
+
+	// Create load balancer - SUCCESS, managed security group is created
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.Equal(t, lb.SecurityGroups, []VpcObjectReference{{ID: "load balancer-ID"}})
+
+	// Create load balancer - SUCCESS, existing managed security group already has the correct rules
+	c.SetFakeSdkError("CreateSecurityGroupRule")
+	lb, err = c.CreateLoadBalancer("securityGroup", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.Equal(t, lb.SecurityGroups, []VpcObjectReference{{ID: "securityGroupID"}})
+
+	// Create load balancer failed, rules of the existing managed security group need to be updated
+	service.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+	lb, err = c.CreateLoadBalancer("securityGroup", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "CreateSecurityGroupRule failed")
+	c.ClearFakeSdkError("CreateSecurityGroupRule")
+
+	// Create load balancer failed, old rule of the existing managed security group could not be deleted
+	c.SetFakeSdkError("DeleteSecurityGroupRule")
+	lb, err = c.CreateLoadBalancer("securityGroup", service, []*v1.Node{node})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "DeleteSecurityGroupRule failed")
+	c.ClearFakeSdkError("DeleteSecurityGroupRule")
+
+	// Create load balancer - SUCCESS, managed security group combined with security group from the annotation
+	service.ObjectMeta.Annotations[serviceAnnotationSecurityGroups] = "securityGroupID"
+	lb, err = c.CreateLoadBalancer("load balancer", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.Equal(t, lb.SecurityGroups, []VpcObjectReference{{ID: "securityGroupID"}, {ID: "load balancer-ID"}})
+}
+
+func TestCloudVpc_CreateLoadBalancerMixedProtocol(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.0.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.0.1", Type: v1.NodeInternalIP}}}}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "dns-server", Namespace: "default", UID: "1234"},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{Protocol: v1.ProtocolTCP, Port: 53, NodePort: 30053},
+				{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(),
+		&ConfigVpc{
+			ClusterID:    "clusterID",
+			ProviderType: VpcProviderTypeFake,
+			SubnetNames:  "subnet1",
+			VpcName:      "vpc",
+		}, nil)
+
+	// Create load balancer - SUCCESS, a TCP and a UDP pool are created for the same external port
+	lb, err := c.CreateLoadBalancer("kube-clusterID-Ready", service, []*v1.Node{node})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	assert.Equal(t, lb.Pools, []VpcObjectReference{{Name: "tcp-53-30053"}, {Name: "udp-53-30053"}})
+}
+
+func TestCloudVpc_checkListenersForExtPortAddedToService(t *testing.T) {
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	tcpPort := v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 53, NodePort: 30053}
+	udpPort := v1.ServicePort{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053}
+	tcpListener := &VpcLoadBalancerListener{ID: "tcpListener", Port: 53, Protocol: LoadBalancerProtocolTCP}
+	udpListener := &VpcLoadBalancerListener{ID: "udpListener", Port: 53, Protocol: LoadBalancerProtocolUDP}
+	options := newServiceOptions()
+
+	// Only the TCP listener exists for the port, UDP listener needs to be created
+	updates := c.checkListenersForExtPortAddedToService([]string{}, []*VpcLoadBalancerListener{tcpListener}, tcpPort, options)
+	assert.Equal(t, updates, []string{})
+	updates = c.checkListenersForExtPortAddedToService([]string{}, []*VpcLoadBalancerListener{tcpListener}, udpPort, options)
+	assert.Equal(t, updates, []string{"CREATE-LISTENER udp-53-30053"})
+
+	// Both listeners exist for the port, no updates needed
+	listeners := []*VpcLoadBalancerListener{tcpListener, udpListener}
+	updates = c.checkListenersForExtPortAddedToService([]string{}, listeners, tcpPort, options)
+	updates = c.checkListenersForExtPortAddedToService(updates, listeners, udpPort, options)
+	assert.Equal(t, updates, []string{})
+
+	// The TCP port was removed from the service, only the TCP listener needs to be deleted
+	updates = c.checkListenerForExtPortDeletedFromService([]string{}, tcpListener, []v1.ServicePort{udpPort}, options)
+	updates = c.checkListenerForExtPortDeletedFromService(updates, udpListener, []v1.ServicePort{udpPort}, options)
+	assert.Equal(t, updates, []string{"DELETE-LISTENER unknown tcpListener"})
+
+	// The TCP port is now terminated by an HTTPS listener, the TCP listener is deleted and the HTTPS listener is created
+	options.annotations[serviceAnnotationHTTPSPorts] = "53"
+	updates = c.checkListenerForExtPortDeletedFromService([]string{}, tcpListener, []v1.ServicePort{tcpPort}, options)
+	updates = c.checkListenersForExtPortAddedToService(updates, []*VpcLoadBalancerListener{tcpListener}, tcpPort, options)
+	assert.Equal(t, updates, []string{"DELETE-LISTENER unknown tcpListener", "CREATE-LISTENER tcp-53-30053"})
+	httpsListener := &VpcLoadBalancerListener{ID: "httpsListener", Port: 53, Protocol: LoadBalancerProtocolHTTPS}
+	updates = c.checkListenersForExtPortAddedToService([]string{}, []*VpcLoadBalancerListener{httpsListener}, tcpPort, options)
+	assert.Equal(t, updates, []string{})
+}
+
+func TestCloudVpc_checkPortRangeUpdates(t *testing.T) {
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	ports := []v1.ServicePort{
+		{Protocol: v1.ProtocolTCP, Port: 30000, NodePort: 30000},
+		{Protocol: v1.ProtocolTCP, Port: 30001, NodePort: 30001},
+	}
+	listener1 := &VpcLoadBalancerListener{ID: "listener1", Port: 30000, Protocol: LoadBalancerProtocolTCP}
+	listener2 := &VpcLoadBalancerListener{ID: "listener2", Port: 30001, Protocol: LoadBalancerProtocolTCP}
+	pool1 := &VpcLoadBalancerPool{ID: "pool1", Name: "tcp-30000-30000"}
+	pool2 := &VpcLoadBalancerPool{ID: "pool2", Name: "tcp-30001-30001"}
+	options := newServiceOptions()
+	options.enabledFeatures = LoadBalancerOptionNLB
+
+	// Listener and pool exist for each port, no updates needed
+	listeners := []*VpcLoadBalancerListener{listener1, listener2}
+	pools := []*VpcLoadBalancerPool{pool1, pool2}
+	updates := []string{}
+	for _, listener := range listeners {
+		updates = c.checkListenerForExtPortDeletedFromService(updates, listener, ports, options)
+	}
+	for _, pool := range pools {
+		updates, _ = c.checkPoolForExtPortDeletedFromService(updates, pool, ports, options)
+	}
+	for _, port := range ports {
+		updates, _ = c.checkPoolsForExtPortAddedToService(updates, pools, port, options)
+		updates = c.checkListenersForExtPortAddedToService(updates, listeners, port, options)
+	}
+	assert.Equal(t, updates, []string{})
+
+	// Ports are combined into a port range, single listener and pool are used for the range
+	options.annotations[serviceAnnotationPortRange] = "30000-30001"
+	updates = []string{}
+	for _, listener := range listeners {
+		updates = c.checkListenerForExtPortDeletedFromService(updates, listener, ports, options)
+	}
+	for _, pool := range pools {
+		updates, _ = c.checkPoolForExtPortDeletedFromService(updates, pool, ports, options)
+	}
+	for _, port := range ports {
+		updates, _ = c.checkPoolsForExtPortAddedToService(updates, pools, port, options)
+		updates = c.checkListenersForExtPortAddedToService(updates, []*VpcLoadBalancerListener{}, port, options)
+	}
+	assert.Equal(t, updates, []string{
+		"DELETE-LISTENER unknown listener1",
+		"DELETE-LISTENER unknown listener2",
+		"DELETE-POOL tcp-30001-30001 pool2",
+		"CREATE-LISTENER tcp-30000-30000",
+	})
+
+	// Listener for the port range already exists, no updates needed
+	rangeListener := &VpcLoadBalancerListener{ID: "rangeListener", Port: 30000, PortMax: 30001, PortMin: 30000, Protocol: LoadBalancerProtocolTCP}
+	assert.True(t, rangeListener.IsPortRange())
+	assert.False(t, listener1.IsPortRange())
+	updates = c.checkListenerForExtPortDeletedFromService([]string{}, rangeListener, ports, options)
+	for _, port := range ports {
+		updates = c.checkListenersForExtPortAddedToService(updates, []*VpcLoadBalancerListener{rangeListener}, port, options)
+	}
+	assert.Equal(t, updates, []string{})
+
+	// Port range is extended, listener is re-created
+	ports = append(ports, v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 30002, NodePort: 30002})
+	options.annotations[serviceAnnotationPortRange] = "30000-30002"
+	updates = c.checkListenerForExtPortDeletedFromService([]string{}, rangeListener, ports, options)
+	for _, port := range ports {
+		updates = c.checkListenersForExtPortAddedToService(updates, []*VpcLoadBalancerListener{rangeListener}, port, options)
+	}
+	assert.Equal(t, updates, []string{"DELETE-LISTENER unknown rangeListener", "CREATE-LISTENER tcp-30000-30000"})
+}
+
 func TestCloudVpc_DeleteLoadBalancer(t *testing.T) {
 	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
 
@@ -127,6 +385,92 @@ func TestCloudVpc_DeleteLoadBalancer(t *testing.T) {
 	lb := &VpcLoadBalancer{ID: "Ready"}
 	err = c.DeleteLoadBalancer(lb, nil)
 	assert.Nil(t, err)
+
+	// Delete load balancer worked, delete already in progress
+	c.SetFakeSdkError("DeleteLoadBalancer")
+	lb = &VpcLoadBalancer{ID: "Ready", ProvisioningStatus: LoadBalancerProvisioningStatusDeletePending}
+	err = c.DeleteLoadBalancer(lb, nil)
+	assert.Nil(t, err)
+	c.ClearFakeSdkError("DeleteLoadBalancer")
+
+	// Delete load balancer started, managed security group will be deleted after the load balancer. The security group
+	// is found by the load balancer name, even if the service is not specified
+	c.Config.VpcName = "vpc"
+	lb = &VpcLoadBalancer{ID: "Ready", Name: "securityGroup"}
+	err = c.DeleteLoadBalancer(lb, nil)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, errSecurityGroupDeletePending))
+	assert.Contains(t, err.Error(), "security group securityGroupID will be deleted after the load balancer")
+	assert.Equal(t, map[string]bool{"securityGroup": true}, c.securityGroupDeletes)
+
+	// Delete load balancer failed, SDK list security groups operation failed
+	c.SetFakeSdkError("ListSecurityGroups")
+	err = c.DeleteLoadBalancer(lb, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "ListSecurityGroups failed")
+	c.ClearFakeSdkError("ListSecurityGroups")
+
+	// Delete load balancer worked, load balancer does not have a managed security group
+	lb = &VpcLoadBalancer{ID: "Ready", Name: "kube-clusterID-Ready"}
+	err = c.DeleteLoadBalancer(lb, nil)
+	assert.Nil(t, err)
+}
+
+func TestCloudVpc_DeleteLoadBalancerSecurityGroup(t *testing.T) {
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake, VpcName: "vpc"}, nil)
+
+	// Security group does not exist
+	err := c.deleteLoadBalancerSecurityGroup("kube-clusterID-Ready")
+	assert.Nil(t, err)
+
+	// Security group was deleted
+	err = c.deleteLoadBalancerSecurityGroup("securityGroup")
+	assert.Nil(t, err)
+
+	// SDK delete security group operation failed
+	c.SetFakeSdkError("DeleteSecurityGroup")
+	err = c.deleteLoadBalancerSecurityGroup("securityGroup")
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "DeleteSecurityGroup failed")
+
+	// Security group that was not created for a load balancer deleted by the cloud provider is not deleted,
+	// even if its name has the prefix of the load balancers of the cluster
+	c.Sdk.(*VpcSdkFake).SecurityGroup.Name = "kube-clusterID-workers"
+	c.setSecurityGroupDeletePending("kube-clusterID-Ready", true)
+	err = c.deleteStaleSecurityGroups(map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{}, c.securityGroupDeletes)
+	c.Sdk.(*VpcSdkFake).SecurityGroup.Name = "securityGroup"
+
+	// Stale security group, load balancer still in use
+	c.setSecurityGroupDeletePending("securityGroup", true)
+	err = c.deleteStaleSecurityGroups(map[string]bool{"securityGroup": true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"securityGroup": true}, c.securityGroupDeletes)
+
+	// Stale security group, SDK delete security group operation failed
+	err = c.deleteStaleSecurityGroups(map[string]bool{})
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "DeleteSecurityGroup failed")
+	assert.Equal(t, map[string]bool{"securityGroup": true}, c.securityGroupDeletes)
+	c.ClearFakeSdkError("DeleteSecurityGroup")
+
+	// Stale security group, SDK list security groups operation failed
+	c.SetFakeSdkError("ListSecurityGroups")
+	err = c.deleteStaleSecurityGroups(map[string]bool{})
+	assert.NotNil(t, err)
+	c.ClearFakeSdkError("ListSecurityGroups")
+
+	// Stale security group deleted
+	err = c.deleteStaleSecurityGroups(map[string]bool{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{}, c.securityGroupDeletes)
+
+	// VPC subnets not found, there are no managed security groups
+	c.setSecurityGroupDeletePending("securityGroup", true)
+	c.Config.VpcName = "missing"
+	err = c.deleteStaleSecurityGroups(map[string]bool{})
+	assert.Nil(t, err)
 }
 
 func TestCloudVpc_FindLoadBalancer(t *testing.T) {
@@ -159,6 +503,34 @@ func TestCloudVpc_GetLoadBalancerStatus(t *testing.T) {
 	assert.Equal(t, len(status.Ingress), 1)
 	assert.Equal(t, status.Ingress[0].Hostname, "hostname")
 	assert.Equal(t, status.Ingress[0].IP, "")
+
+	// Network load balancer, IPs are reported along with the hostname
+	vip := v1.LoadBalancerIPModeVIP
+	proxy := v1.LoadBalancerIPModeProxy
+	lb := &VpcLoadBalancer{
+		Hostname:      "hostname",
+		IsPublic:      true,
+		PrivateIps:    []string{"10.0.0.1", "10.0.0.2"},
+		ProfileFamily: LoadBalancerProfileFamilyNetwork,
+		PublicIps:     []string{"192.168.0.1", "192.168.0.2"},
+	}
+	status = c.GetLoadBalancerStatus(service, lb)
+	assert.Equal(t, status.Ingress, []v1.LoadBalancerIngress{{Hostname: "hostname"}, {IP: "192.168.0.1", IPMode: &vip}, {IP: "192.168.0.2", IPMode: &vip}})
+
+	// Private network load balancer, private IPs are reported
+	lb.IsPublic = false
+	status = c.GetLoadBalancerStatus(service, lb)
+	assert.Equal(t, status.Ingress, []v1.LoadBalancerIngress{{Hostname: "hostname"}, {IP: "10.0.0.1", IPMode: &vip}, {IP: "10.0.0.2", IPMode: &vip}})
+
+	// Application load balancer, IPs are not reported unless requested
+	lb.ProfileFamily = "application"
+	status = c.GetLoadBalancerStatus(service, lb)
+	assert.Equal(t, status.Ingress, []v1.LoadBalancerIngress{{Hostname: "hostname"}})
+
+	// Application load balancer, IPs are published with the Proxy IP mode
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionPublishIPs}
+	status = c.GetLoadBalancerStatus(service, lb)
+	assert.Equal(t, status.Ingress, []v1.LoadBalancerIngress{{Hostname: "hostname"}, {IP: "10.0.0.1", IPMode: &proxy}, {IP: "10.0.0.2", IPMode: &proxy}})
 }
 
 func TestCloudVpc_UpdateLoadBalancer(t *testing.T) {
@@ -194,12 +566,12 @@ func TestCloudVpc_UpdateLoadBalancer(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "load balancer is not ready")
 
-	// Update load balancer failed, attempting to update a UDP service
+	// Update load balancer failed, attempting to add a UDP port to an application load balancer
 	service.Spec.Ports[0].Protocol = v1.ProtocolUDP
 	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node})
 	assert.Nil(t, lb)
 	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "Only TCP is supported")
+	assert.Contains(t, err.Error(), "does not support UDP")
 	service.Spec.Ports[0].Protocol = v1.ProtocolTCP
 
 	// Update load balancer failed, attempting to change public LB to a private LB
@@ -386,6 +758,476 @@ func TestCloudVpc_UpdateLoadBalancer(t *testing.T) {
 	service.Spec.Ports[0].NodePort = 30303
 }
 
+func TestCloudVpc_UpdateLoadBalancerUDP(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	networkLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProfileFamily:      LoadBalancerProfileFamilyNetwork,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolUDP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+
+	// Update load balancer failed, TCP listener and pool are replaced by UDP listener and pool
+	c.SetFakeSdkError("CreateLoadBalancerPool")
+	lb, err := c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "CreateLoadBalancerPool failed")
+	c.ClearFakeSdkError("CreateLoadBalancerPool")
+	sdk, _ := NewVpcSdkFake()
+	c.Sdk = sdk
+
+	// Update load balancer successful, TCP listener and pool are replaced by UDP listener and pool
+	c.Sdk.(*VpcSdkFake).LoadBalancerReady.Pools = []VpcObjectReference{{Name: "udp-80-30303", ID: "poolUDP"}}
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	sdk, _ = NewVpcSdkFake()
+	c.Sdk = sdk
+
+	// Update load balancer failed, UDP pool is using TCP health check on the node port
+	fakeSdk := c.Sdk.(*VpcSdkFake)
+	fakeSdk.Listener.Protocol = LoadBalancerProtocolUDP
+	fakeSdk.Listener.DefaultPool.Name = "udp-80-30303"
+	fakeSdk.Pool.Name = "udp-80-30303"
+	fakeSdk.Pool.Protocol = LoadBalancerProtocolUDP
+	fakeSdk.Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+	c.SetFakeSdkError("UpdateLoadBalancerPool")
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+
+	// Update load balancer successful, no updates needed for UDP pool using the kube-proxy health check
+	fakeSdk.Pool.HealthMonitor.Port = kubeProxyHealthCheckPort
+	fakeSdk.Pool.HealthMonitor.Type = LoadBalancerProtocolHTTP
+	fakeSdk.Pool.HealthMonitor.URLPath = kubeProxyHealthCheckPath
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+	c.ClearFakeSdkError("UpdateLoadBalancerPool")
+}
+
+func TestCloudVpc_CheckPoolForNodesInstanceTargets(t *testing.T) {
+	c := CloudVpc{}
+	ports := []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}}
+	pool := &VpcLoadBalancerPool{Name: "tcp-80-30303", ID: "poolID", Members: []*VpcLoadBalancerPoolMember{
+		{ID: "member1", Port: 30303, TargetInstanceID: "instance-1"},
+		{ID: "member2", Port: 30303, TargetInstanceID: "instance-2"},
+	}}
+	nodeList := []string{"instance-2", "instance-3"}
+
+	// Pool member needs to be added for instance-3
+	updates, err := c.checkPoolForNodesToAdd([]string{}, pool, ports, nodeList)
+	assert.Nil(t, err)
+	assert.Equal(t, updates, []string{actionCreatePoolMember + " tcp-80-30303 poolID instance-3"})
+
+	// Pool member needs to be deleted for instance-1
+	updates, err = c.checkPoolForNodesToDelete([]string{}, pool, ports, nodeList)
+	assert.Nil(t, err)
+	assert.Equal(t, updates, []string{actionDeletePoolMember + " tcp-80-30303 poolID member1 instance-1"})
+}
+
+func TestCloudVpc_CheckPoolForMemberWeights(t *testing.T) {
+	c := CloudVpc{}
+	ports := []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}}
+	pool := &VpcLoadBalancerPool{Name: "tcp-80-30303", ID: "poolID", Members: []*VpcLoadBalancerPoolMember{
+		{ID: "member1", Port: 30303, TargetIPAddress: "192.168.1.1", Weight: 50},
+		{ID: "member2", Port: 30303, TargetIPAddress: "192.168.2.2", Weight: 16},
+		{ID: "member3", Port: 30303, TargetIPAddress: "192.168.3.3", Weight: 50},
+	}}
+	options := &ServiceOptions{memberWeights: map[string]int64{"192.168.1.1": 4, "192.168.2.2": 16}}
+
+	// Member weights not requested
+	updates, err := c.checkPoolForMemberWeights([]string{}, pool, ports, options)
+	assert.Nil(t, err)
+	assert.Equal(t, len(updates), 0)
+
+	// Weight of member1 needs to be updated, member3 is not in the node list
+	options.enabledFeatures = LoadBalancerOptionMemberWeights
+	updates, err = c.checkPoolForMemberWeights([]string{}, pool, ports, options)
+	assert.Nil(t, err)
+	assert.Equal(t, updates, []string{actionUpdatePoolMember + " tcp-80-30303 poolID member1 192.168.1.1 4"})
+
+	// Node port changed, all of the members will be replaced
+	ports[0].NodePort = 31313
+	updates, err = c.checkPoolForMemberWeights([]string{}, pool, ports, options)
+	assert.Nil(t, err)
+	assert.Equal(t, len(updates), 0)
+
+	// Invalid pool name
+	pool.Name = "invalid"
+	updates, err = c.checkPoolForMemberWeights([]string{}, pool, ports, options)
+	assert.NotNil(t, err)
+	assert.Equal(t, len(updates), 0)
+}
+
+func TestCloudVpc_UpdateLoadBalancerMemberWeights(t *testing.T) {
+	node1 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1", Labels: map[string]string{nodeLabelMachineType: "bx2.4x16"}},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2", Labels: map[string]string{nodeLabelMachineType: "bx2.16x64"}},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready",
+		Annotations: map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionMemberWeights}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	fakeSdk := c.Sdk.(*VpcSdkFake)
+	fakeSdk.Pool.Algorithm = LoadBalancerAlgorithmWeightedRoundRobin
+	fakeSdk.Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+	fakeSdk.Pool.Members = []*VpcLoadBalancerPoolMember{
+		{ID: "member1", Port: 30303, TargetIPAddress: "192.168.1.1", Weight: 4},
+		{ID: "member2", Port: 30303, TargetIPAddress: "192.168.2.2", Weight: 50},
+	}
+
+	// Update load balancer failed, the weight of the member2 needs to be updated
+	c.SetFakeSdkError("UpdateLoadBalancerPoolMember")
+	lb, err := c.UpdateLoadBalancer(fakeSdk.LoadBalancerReady, service, []*v1.Node{node1, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerPoolMember failed")
+	c.ClearFakeSdkError("UpdateLoadBalancerPoolMember")
+
+	// Update load balancer successful, no updates needed
+	fakeSdk.Pool.Members[1].Weight = 16
+	c.SetFakeSdkError("UpdateLoadBalancerPool")
+	lb, err = c.UpdateLoadBalancer(fakeSdk.LoadBalancerReady, service, []*v1.Node{node1, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer successful, algorithm is not changed when all of the weights are the same
+	node2.Labels[nodeLabelMachineType] = "bx2.4x16"
+	fakeSdk.Pool.Members[1].Weight = 4
+	lb, err = c.UpdateLoadBalancer(fakeSdk.LoadBalancerReady, service, []*v1.Node{node1, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, algorithm changed back to round robin when the member weights option is removed
+	service.ObjectMeta.Annotations = map[string]string{}
+	lb, err = c.UpdateLoadBalancer(fakeSdk.LoadBalancerReady, service, []*v1.Node{node1, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+	c.ClearFakeSdkError("UpdateLoadBalancerPool")
+}
+
+func TestCloudVpc_UpdateLoadBalancerPoolSettings(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	publicLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	c.Sdk.(*VpcSdkFake).Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+	c.SetFakeSdkError("UpdateLoadBalancerPool")
+
+	// Update load balancer successful, health check matches the defaults
+	lb, err := c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, pool is updated for each of the pool annotations
+	for annotation, value := range map[string]string{
+		serviceAnnotationHealthCheckDelay:   "10",
+		serviceAnnotationHealthCheckPath:    "/healthz",
+		serviceAnnotationHealthCheckPort:    "8080",
+		serviceAnnotationHealthCheckProto:   "http",
+		serviceAnnotationHealthCheckRetries: "3",
+		serviceAnnotationHealthCheckTimeout: "1",
+		serviceAnnotationPoolAlgorithm:      LoadBalancerAlgorithmLeastConnections,
+		serviceAnnotationSessionPersistence: LoadBalancerSessionPersistenceSourceIP,
+	} {
+		service.ObjectMeta.Annotations = map[string]string{annotation: value}
+		lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+		assert.Nil(t, lb)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+	}
+
+	// Update load balancer failed, pool is updated to remove session persistence
+	service.ObjectMeta.Annotations = map[string]string{}
+	c.Sdk.(*VpcSdkFake).Pool.SessionPersistence = LoadBalancerSessionPersistenceSourceIP
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+	c.Sdk.(*VpcSdkFake).Pool.SessionPersistence = LoadBalancerSessionPersistenceNone
+
+	// Pool is only updated if the PROXY protocol setting on the pool does not match the service
+	for _, test := range []struct {
+		poolProxyProtocol string
+		enableFeatures    string
+		update            bool
+	}{
+		{LoadBalancerProxyProtocolDisabled, "", false},
+		{LoadBalancerProxyProtocolDisabled, LoadBalancerOptionProxyProtocol, true},
+		{LoadBalancerProxyProtocolDisabled, LoadBalancerOptionProxyProtocolV2, true},
+		{LoadBalancerProxyProtocolV1, "", true},
+		{LoadBalancerProxyProtocolV1, LoadBalancerOptionProxyProtocol, false},
+		{LoadBalancerProxyProtocolV1, LoadBalancerOptionProxyProtocolV2, true},
+		{LoadBalancerProxyProtocolV2, "", true},
+		{LoadBalancerProxyProtocolV2, LoadBalancerOptionProxyProtocol, true},
+		{LoadBalancerProxyProtocolV2, LoadBalancerOptionProxyProtocolV2, false},
+	} {
+		c.Sdk.(*VpcSdkFake).Pool.ProxyProtocol = test.poolProxyProtocol
+		service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: test.enableFeatures}
+		lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+		if test.update {
+			assert.Nil(t, lb)
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+		} else {
+			assert.NotNil(t, lb)
+			assert.Nil(t, err)
+		}
+	}
+	c.ClearFakeSdkError("UpdateLoadBalancerPool")
+}
+
+func TestCloudVpc_UpdateLoadBalancerListenerSettings(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	publicLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	c.Sdk.(*VpcSdkFake).Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+	c.SetFakeSdkError("UpdateLoadBalancerListener")
+
+	// Update load balancer successful, listener settings match the defaults
+	lb, err := c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, listener is updated for each of the listener annotations
+	for annotation, value := range map[string]string{
+		serviceAnnotationConnectionLimit: "2000",
+		serviceAnnotationIdleTimeout:     "300",
+	} {
+		service.ObjectMeta.Annotations = map[string]string{annotation: value}
+		lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+		assert.Nil(t, lb)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "UpdateLoadBalancerListener failed")
+	}
+
+	// Update load balancer successful, listener is updated
+	c.ClearFakeSdkError("UpdateLoadBalancerListener")
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update listener failed, required arguments are missing
+	err = c.updateLoadBalancerListener(publicLB, "tcp-80-30303", c.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Required argument is missing")
+}
+
+func TestCloudVpc_UpdateLoadBalancerSecurityGroup(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	publicLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		Name:               "securityGroup",
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		SecurityGroups:     []VpcObjectReference{{ID: "securityGroupID", Name: "securityGroup"}},
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready",
+		Annotations: map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionManagedSecurityGroup}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	c.Sdk.(*VpcSdkFake).Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+	c.SetFakeSdkError("CreateSecurityGroupRule")
+
+	// Update load balancer successful, rules of the managed security group match the service
+	lb, err := c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, rules of the managed security group need to be updated
+	service.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "CreateSecurityGroupRule failed")
+
+	// Update load balancer successful, rules of the managed security group are updated
+	c.ClearFakeSdkError("CreateSecurityGroupRule")
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, SDK list security groups operation failed
+	c.SetFakeSdkError("ListSecurityGroups")
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ListSecurityGroups failed")
+	c.ClearFakeSdkError("ListSecurityGroups")
+
+	// Update load balancer failed, security groups of the load balancer can not be changed
+	service.ObjectMeta.Annotations[serviceAnnotationSecurityGroups] = "otherSecurityGroup"
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "This setting can not be changed")
+
+	// Update security group failed, required arguments are missing
+	err = c.updateSecurityGroup("securityGroup", nil, service, c.getServiceOptions(service))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Required argument is missing")
+}
+
+func TestCloudVpc_UpdateLoadBalancerHTTPS(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	publicLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	certificateCRN := "crn:v1:bluemix:public:secrets-manager:us-south:a/123456:instance::secret"
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready",
+		Annotations: map[string]string{serviceAnnotationHTTPSPorts: "80", serviceAnnotationCertificateCRN: certificateCRN}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports:                 []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	fakeSdk := c.Sdk.(*VpcSdkFake)
+	fakeSdk.Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+
+	// Update load balancer failed, TCP listener is deleted before the pool protocol is changed to HTTP
+	c.SetFakeSdkError("UpdateLoadBalancerPool")
+	lb, err := c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerPool failed")
+	c.SetFakeSdkError("DeleteLoadBalancerListener")
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "DeleteLoadBalancerListener failed")
+	c.ClearFakeSdkError("DeleteLoadBalancerListener")
+
+	// Update load balancer successful, HTTPS listener and HTTP pool already exist
+	fakeSdk.Listener.Protocol = LoadBalancerProtocolHTTPS
+	fakeSdk.Listener.CertificateInstance = certificateCRN
+	fakeSdk.Pool.Protocol = LoadBalancerProtocolHTTP
+	c.SetFakeSdkError("UpdateLoadBalancerListener")
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, certificate of the HTTPS listener is updated
+	service.ObjectMeta.Annotations[serviceAnnotationCertificateCRN] = certificateCRN + "-new"
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerListener failed")
+
+	// Update load balancer failed, HTTP listener is redirected to the existing HTTPS listener
+	service.ObjectMeta.Annotations = map[string]string{
+		serviceAnnotationCertificateCRN: certificateCRN,
+		serviceAnnotationHTTPSPorts:     "443",
+		serviceAnnotationHTTPSRedirect:  "80:443",
+	}
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443})
+	fakeSdk.Listener.Protocol = LoadBalancerProtocolHTTP
+	fakeSdk.Listener.CertificateInstance = ""
+	httpsListener := &VpcLoadBalancerListener{
+		CertificateInstance:   certificateCRN,
+		ConnectionLimit:       LoadBalancerConnectionLimitDefault,
+		DefaultPool:           VpcObjectReference{Name: "tcp-443-30443"},
+		ID:                    "httpsListener",
+		IdleConnectionTimeout: LoadBalancerIdleConnectionTimeoutDefault,
+		Port:                  443,
+		Protocol:              LoadBalancerProtocolHTTPS,
+	}
+	fakeSdk.Listeners = append(fakeSdk.Listeners, httpsListener)
+	lb, err = c.UpdateLoadBalancer(publicLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "UpdateLoadBalancerListener failed")
+	assert.Equal(t, c.getDesiredListener(fakeSdk.Listener, fakeSdk.Listeners, c.getServiceOptions(service)).HTTPSRedirectListener, "httpsListener")
+	c.ClearFakeSdkError("UpdateLoadBalancerListener")
+	c.ClearFakeSdkError("UpdateLoadBalancerPool")
+}
+
+func TestIsHealthMonitorEqual(t *testing.T) {
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", Annotations: map[string]string{}}}
+	options := c.getServiceOptions(service)
+	healthMonitor := VpcLoadBalancerPoolHealthMonitor{Delay: 5, MaxRetries: 2, Port: 30303, Timeout: 2, Type: LoadBalancerProtocolHTTP, URLPath: "/"}
+	assert.True(t, options.isHealthMonitorEqual(healthMonitor, healthMonitor))
+
+	// Type and port are always compared
+	desired := healthMonitor
+	desired.Type = LoadBalancerProtocolTCP
+	assert.False(t, options.isHealthMonitorEqual(healthMonitor, desired))
+	desired = healthMonitor
+	desired.Port = 30304
+	assert.False(t, options.isHealthMonitorEqual(healthMonitor, desired))
+
+	// Settings that are not set by an annotation are not compared
+	desired = VpcLoadBalancerPoolHealthMonitor{Delay: 10, MaxRetries: 3, Port: 30303, Timeout: 5, Type: LoadBalancerProtocolHTTP, URLPath: "/healthz"}
+	assert.True(t, options.isHealthMonitorEqual(healthMonitor, desired))
+
+	// Settings that are set by an annotation are compared
+	for _, annotation := range []string{serviceAnnotationHealthCheckDelay, serviceAnnotationHealthCheckRetries, serviceAnnotationHealthCheckTimeout} {
+		service.Annotations = map[string]string{annotation: "3"}
+		options = c.getServiceOptions(service)
+		assert.False(t, options.isHealthMonitorEqual(healthMonitor, desired), annotation)
+	}
+	service.Annotations = map[string]string{serviceAnnotationHealthCheckPath: "/healthz"}
+	options = c.getServiceOptions(service)
+	assert.False(t, options.isHealthMonitorEqual(healthMonitor, desired))
+
+	// URL path is ignored for TCP health checks
+	healthMonitor.Type = LoadBalancerProtocolTCP
+	desired = healthMonitor
+	desired.URLPath = ""
+	assert.True(t, options.isHealthMonitorEqual(healthMonitor, desired))
+}
+
 func TestCloudVpc_WaitLoadBalancerReady(t *testing.T) {
 	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
 	lb := &VpcLoadBalancer{
@@ -414,3 +1256,70 @@ func TestCloudVpc_WaitLoadBalancerReady(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "load balancer not ready")
 }
+
+func TestCloudVpc_UpdateLoadBalancerMixedProtocol(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.1.1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.1.1", Type: v1.NodeInternalIP}}}}
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.2.2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Address: "192.168.2.2", Type: v1.NodeInternalIP}}}}
+	networkLB := &VpcLoadBalancer{
+		IsPublic:           true,
+		OperatingStatus:    LoadBalancerOperatingStatusOnline,
+		ProfileFamily:      LoadBalancerProfileFamilyNetwork,
+		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		Subnets:            []VpcObjectReference{{ID: "subnetID"}},
+	}
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "dns-server", Namespace: "default", UID: "Ready", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+			Type:                  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30303},
+				{Protocol: v1.ProtocolUDP, Port: 80, NodePort: 30303}},
+		}}
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
+	fakeSdk := c.Sdk.(*VpcSdkFake)
+	fakeSdk.Pool.ProxyProtocol = LoadBalancerProxyProtocolDisabled
+
+	// Update load balancer failed, UDP pool needs to be created, TCP listener and pool are left alone
+	c.SetFakeSdkError("DeleteLoadBalancerListener")
+	c.SetFakeSdkError("DeleteLoadBalancerPool")
+	c.SetFakeSdkError("CreateLoadBalancerPool")
+	lb, err := c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "CreateLoadBalancerPool failed")
+	c.ClearFakeSdkError("CreateLoadBalancerPool")
+
+	// Update load balancer failed, UDP listener needs to be created
+	fakeSdk.LoadBalancerReady.Pools = []VpcObjectReference{{Name: "tcp-80-30303", ID: "poolID"}, {Name: "udp-80-30303", ID: "poolUDP"}}
+	c.SetFakeSdkError("CreateLoadBalancerListener")
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "CreateLoadBalancerListener failed")
+
+	// Update load balancer successful, TCP and UDP listeners and pools already exist for the same port
+	udpPool := &VpcLoadBalancerPool{
+		Algorithm:          LoadBalancerAlgorithmRoundRobin,
+		SessionPersistence: LoadBalancerSessionPersistenceNone,
+		HealthMonitor:      VpcLoadBalancerPoolHealthMonitor{Delay: 5, MaxRetries: 2, Port: kubeProxyHealthCheckPort, Timeout: 2, Type: LoadBalancerProtocolHTTP, URLPath: kubeProxyHealthCheckPath},
+		ID:                 "poolUDP",
+		Members:            fakeSdk.Pool.Members,
+		Name:               "udp-80-30303",
+		Protocol:           LoadBalancerProtocolUDP,
+		ProxyProtocol:      LoadBalancerProxyProtocolDisabled,
+	}
+	udpListener := &VpcLoadBalancerListener{DefaultPool: VpcObjectReference{Name: "udp-80-30303", ID: "poolUDP"}, ID: "listenerUDP", Port: 80, Protocol: LoadBalancerProtocolUDP}
+	fakeSdk.Pools = append(fakeSdk.Pools, udpPool)
+	fakeSdk.Listeners = append(fakeSdk.Listeners, udpListener)
+	c.SetFakeSdkError("UpdateLoadBalancerPool")
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Update load balancer failed, TCP port was removed so the TCP listener is deleted
+	service.Spec.Ports = service.Spec.Ports[1:]
+	lb, err = c.UpdateLoadBalancer(networkLB, service, []*v1.Node{node, node2})
+	assert.Nil(t, lb)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "DeleteLoadBalancerListener failed")
+}
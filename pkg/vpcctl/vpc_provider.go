@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"cloud.ibm.com/cloud-provider-ibm/pkg/klog"
 	v1 "k8s.io/api/core/v1"
@@ -32,8 +33,11 @@ import (
 )
 
 const (
+	assigningLoadBalancerIPFailed    = "AssigningLoadBalancerIPFailed"
 	creatingCloudLoadBalancerFailed  = "CreatingCloudLoadBalancerFailed"
 	deletingCloudLoadBalancerFailed  = "DeletingCloudLoadBalancerFailed"
+	enforcingIPFamiliesFailed        = "EnforcingIPFamiliesFailed"
+	enforcingSourceRangesFailed      = "EnforcingSourceRangesFailed"
 	gettingCloudLoadBalancerFailed   = "GettingCloudLoadBalancerFailed"
 	updatingCloudLoadBalancerFailed  = "UpdatingCloudLoadBalancerFailed"
 	verifyingCloudLoadBalancerFailed = "VerifyingCloudLoadBalancerFailed"
@@ -51,6 +55,13 @@ type CloudVpc struct {
 	Config     *ConfigVpc
 	Sdk        CloudVpcSdk
 	Recorder   record.EventRecorder
+	// Names of the load balancers that were deleted by the cloud provider whose managed security group
+	// is deleted once the load balancer is gone, see deleteStaleSecurityGroups()
+	securityGroupDeletes     map[string]bool
+	securityGroupDeletesLock sync.Mutex
+	// Last warning message recorded for each service UID and event reason, see recordServiceWarningEventOnChange()
+	serviceWarnings     map[string]string
+	serviceWarningsLock sync.Mutex
 }
 
 // Global variables
@@ -94,6 +105,52 @@ func NewCloudVpc(kubeClient kubernetes.Interface, config *ConfigVpc, recorder re
 	return c, nil
 }
 
+// checkServiceIPFamilies - VPC load balancers only support IPv4. Generate a warning event if the IP families of the
+// service of an existing load balancer can not be served, the load balancer keeps handling the IPv4 traffic. The event
+// is only generated when the IP families of the service change
+func (c *CloudVpc) checkServiceIPFamilies(lbName string, service *v1.Service) {
+	err := c.validateServiceIPFamilies(service)
+	if err == nil {
+		c.clearServiceWarning(service, enforcingIPFamiliesFailed)
+		return
+	}
+	errString := fmt.Sprintf("%v. The load balancer only handles the %s traffic of the service", err, v1.IPv4Protocol)
+	c.recordServiceWarningEventOnChange(service, enforcingIPFamiliesFailed, lbName, errString, false)
+}
+
+// checkServiceLoadBalancerIP - VPC load balancers do not support requesting a specific IP address. Generate a warning
+// event if the loadBalancerIP of the service is not one of the IPs that were assigned to the existing load balancer.
+// The event is only generated when the loadBalancerIP or the IPs of the load balancer change
+func (c *CloudVpc) checkServiceLoadBalancerIP(lbName string, service *v1.Service, lb *VpcLoadBalancer) {
+	assigned := service.Spec.LoadBalancerIP == ""
+	for _, ip := range lb.getIPs() {
+		if ip == service.Spec.LoadBalancerIP {
+			assigned = true
+		}
+	}
+	if assigned {
+		c.clearServiceWarning(service, assigningLoadBalancerIPFailed)
+		return
+	}
+	errString := fmt.Sprintf("The loadBalancerIP %s of the service was not assigned to the load balancer. VPC load balancers do not support requesting a specific IP address, remove the loadBalancerIP from the service. Load balancer IPs: %v",
+		service.Spec.LoadBalancerIP, lb.getIPs())
+	c.recordServiceWarningEventOnChange(service, assigningLoadBalancerIPFailed, lbName, errString, false)
+}
+
+// checkServiceSourceRanges - the loadBalancerSourceRanges of the service are only enforced by the managed security group.
+// Generate a warning event if source ranges were specified on a service that does not use the managed security group.
+// The event is only generated when the load balancer is created or the source ranges change
+func (c *CloudVpc) checkServiceSourceRanges(lbName string, service *v1.Service, created bool) {
+	options := c.getServiceOptions(service)
+	if len(options.getSourceRanges()) == 0 || options.isManagedSecurityGroup() {
+		c.clearServiceWarning(service, enforcingSourceRangesFailed)
+		return
+	}
+	errString := fmt.Sprintf("The loadBalancerSourceRanges %v of the service are not enforced. Specify %s in the %s annotation to restrict the sources of the load balancer traffic",
+		options.getSourceRanges(), LoadBalancerOptionManagedSecurityGroup, serviceAnnotationEnableFeatures)
+	c.recordServiceWarningEventOnChange(service, enforcingSourceRangesFailed, lbName, errString, created)
+}
+
 // EnsureLoadBalancer - called by cloud provider to create/update the load balancer
 func (c *CloudVpc) EnsureLoadBalancer(lbName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
 	// Check to see if the VPC load balancer exists
@@ -104,6 +161,9 @@ func (c *CloudVpc) EnsureLoadBalancer(lbName string, service *v1.Service, nodes
 		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
 	}
 
+	// Let the user know if the source ranges of the service will not be enforced
+	c.checkServiceSourceRanges(lbName, service, lb == nil)
+
 	// If the specified VPC load balancer was not found, create it
 	if lb == nil {
 		lb, err = c.CreateLoadBalancer(lbName, service, nodes)
@@ -134,6 +194,10 @@ func (c *CloudVpc) EnsureLoadBalancer(lbName string, service *v1.Service, nodes
 		return nil, c.recordServiceWarningEvent(service, creatingCloudLoadBalancerFailed, lbName, errString)
 	}
 
+	// Let the user know if the IP families or the loadBalancerIP of the service are not supported by the existing load balancer
+	c.checkServiceIPFamilies(lbName, service)
+	c.checkServiceLoadBalancerIP(lbName, service, lb)
+
 	// The load balancer state is Online/Active.  This means that additional operations can be done.
 	// Update the existing LB with any service or node changes that may have occurred.
 	lb, err = c.UpdateLoadBalancer(lb, service, nodes)
@@ -158,9 +222,17 @@ func (c *CloudVpc) EnsureLoadBalancerDeleted(lbName string, service *v1.Service)
 		return c.recordServiceWarningEvent(service, deletingCloudLoadBalancerFailed, lbName, errString)
 	}
 
-	// If the load balancer does not exist, return
+	// If the load balancer does not exist, clean up the managed security group (if one was created) and return.
+	// The annotation is not checked, it may have been removed from the service after the security group was created
 	if lb == nil {
 		klog.Infof("Load balancer %v not found", lbName)
+		err = c.deleteLoadBalancerSecurityGroup(lbName)
+		if err != nil {
+			errString := fmt.Sprintf("Failed deleting security group: %v", err)
+			klog.Errorf("%s", errString)
+			return c.recordServiceWarningEvent(service, deletingCloudLoadBalancerFailed, lbName, errString)
+		}
+		c.clearServiceWarnings(service)
 		return nil
 	}
 
@@ -169,6 +241,11 @@ func (c *CloudVpc) EnsureLoadBalancerDeleted(lbName string, service *v1.Service)
 
 	// The load balancer state is Online/Active.  Attempt to delete the load balancer
 	err = c.DeleteLoadBalancer(lb, service)
+	if errors.Is(err, errSecurityGroupDeletePending) {
+		// The delete will be retried to clean up the security group once the load balancer is gone
+		klog.Infof("%v", err)
+		return err
+	}
 	if err != nil {
 		errString := fmt.Sprintf("Failed deleting LoadBalancer: %v", err)
 		klog.Errorf("%s", errString)
@@ -177,6 +254,7 @@ func (c *CloudVpc) EnsureLoadBalancerDeleted(lbName string, service *v1.Service)
 
 	// Return success
 	klog.Infof("Load balancer %v deleted", lbName)
+	c.clearServiceWarnings(service)
 	return nil
 }
 
@@ -274,13 +352,31 @@ func (c *CloudVpc) GatherLoadBalancers(services *v1.ServiceList) (map[string]*v1
 		if lbMap[lb.Name] == nil && npMap[lb.Name] == nil {
 			klog.Infof("Deleting stale VPC LB: %s", lb.GetSummary())
 			err := c.DeleteLoadBalancer(lb, nil)
-			if err != nil {
+			if err != nil && !errors.Is(err, errSecurityGroupDeletePending) {
 				// Add an error message to log, but don't fail the entire MONITOR operation
 				klog.Errorf("Failed to delete stale VPC LB: %s", lb.Name)
 			}
 		}
 	}
 
+	// Clean up the managed security groups of the VPC LBs deleted by the cloud provider that no longer have a VPC LB,
+	// Kube LB, or node port service. The security group of a stale VPC LB can only be deleted after the VPC LB is gone
+	inUse := map[string]bool{}
+	for lbName := range vpcMap {
+		inUse[lbName] = true
+	}
+	for lbName := range lbMap {
+		inUse[lbName] = true
+	}
+	for lbName := range npMap {
+		inUse[lbName] = true
+	}
+	err = c.deleteStaleSecurityGroups(inUse)
+	if err != nil {
+		// Add an error message to log, but don't fail the entire MONITOR operation
+		klog.Errorf("Failed to delete stale security groups: %v", err)
+	}
+
 	// Return the LB and VPC maps to the caller
 	return lbMap, vpcMap, nil
 }
@@ -437,3 +533,43 @@ func (c *CloudVpc) recordServiceWarningEvent(lbService *v1.Service, reason, lbNa
 	}
 	return errors.New(message)
 }
+
+// recordServiceWarningEventOnChange - generate a warning event for a problem with the service that does not fail the
+// operation. The event is generated if forced or if the message changed since the last event of the same reason for the
+// service. Otherwise the message is only logged at verbosity 2, so the event is not repeated on every sync
+func (c *CloudVpc) recordServiceWarningEventOnChange(lbService *v1.Service, reason, lbName, errorMessage string, force bool) {
+	key := string(lbService.ObjectMeta.UID) + "/" + reason
+	c.serviceWarningsLock.Lock()
+	changed := force || c.serviceWarnings[key] != errorMessage
+	if changed {
+		if c.serviceWarnings == nil {
+			c.serviceWarnings = map[string]string{}
+		}
+		c.serviceWarnings[key] = errorMessage
+	}
+	c.serviceWarningsLock.Unlock()
+	if !changed {
+		klog.V2Infof("%s", errorMessage)
+		return
+	}
+	klog.Warningf("%s", errorMessage)
+	_ = c.recordServiceWarningEvent(lbService, reason, lbName, errorMessage) // #nosec G104 error is always returned
+}
+
+// clearServiceWarning - forget the last warning of the reason for the service, the problem has been resolved
+func (c *CloudVpc) clearServiceWarning(lbService *v1.Service, reason string) {
+	c.serviceWarningsLock.Lock()
+	defer c.serviceWarningsLock.Unlock()
+	delete(c.serviceWarnings, string(lbService.ObjectMeta.UID)+"/"+reason)
+}
+
+// clearServiceWarnings - forget all of the warnings of the service, the load balancer of the service was deleted
+func (c *CloudVpc) clearServiceWarnings(lbService *v1.Service) {
+	c.serviceWarningsLock.Lock()
+	defer c.serviceWarningsLock.Unlock()
+	for key := range c.serviceWarnings {
+		if strings.HasPrefix(key, string(lbService.ObjectMeta.UID)+"/") {
+			delete(c.serviceWarnings, key)
+		}
+	}
+}
@@ -20,14 +20,136 @@
 package vpcctl
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 )
 
+func TestCloudVpc_checkServiceIPFamilies(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, recorder)
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
+
+	// IPv4 service, no event is generated
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	c.checkServiceIPFamilies("kube-clusterID-Ready", service)
+	assert.Empty(t, recorder.Events)
+
+	// Service was changed to IPv6, warning event is generated
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+	c.checkServiceIPFamilies("kube-clusterID-Ready", service)
+	assert.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.Contains(t, event, enforcingIPFamiliesFailed)
+	assert.Contains(t, event, "requests IP family IPv6. VPC load balancers only support the IPv4 IP family. The load balancer only handles the IPv4 traffic of the service")
+
+	// Nothing changed, the event is not repeated
+	c.checkServiceIPFamilies("kube-clusterID-Ready", service)
+	assert.Empty(t, recorder.Events)
+
+	// Service was changed back to IPv4 and then to dual-stack, warning event is generated
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	c.checkServiceIPFamilies("kube-clusterID-Ready", service)
+	requireDualStack := v1.IPFamilyPolicyRequireDualStack
+	service.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}
+	service.Spec.IPFamilyPolicy = &requireDualStack
+	c.checkServiceIPFamilies("kube-clusterID-Ready", service)
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+}
+
+func TestCloudVpc_checkServiceLoadBalancerIP(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, recorder)
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
+	lb := &VpcLoadBalancer{IsPublic: true, PrivateIps: []string{"10.0.0.1"}, PublicIps: []string{"192.168.0.1"}}
+
+	// No load balancer IP requested, no event is generated
+	c.checkServiceLoadBalancerIP("kube-clusterID-Ready", service, lb)
+	assert.Empty(t, recorder.Events)
+
+	// Requested load balancer IP was assigned, no event is generated
+	service.Spec.LoadBalancerIP = "192.168.0.1"
+	c.checkServiceLoadBalancerIP("kube-clusterID-Ready", service, lb)
+	assert.Empty(t, recorder.Events)
+
+	// Requested load balancer IP was not assigned, warning event is generated
+	lb.IsPublic = false
+	c.checkServiceLoadBalancerIP("kube-clusterID-Ready", service, lb)
+	assert.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.Contains(t, event, assigningLoadBalancerIPFailed)
+	assert.Contains(t, event, "The loadBalancerIP 192.168.0.1 of the service was not assigned to the load balancer")
+
+	// Nothing changed, the event is not repeated
+	c.checkServiceLoadBalancerIP("kube-clusterID-Ready", service, lb)
+	assert.Empty(t, recorder.Events)
+
+	// Requested load balancer IP changed, warning event is generated
+	service.Spec.LoadBalancerIP = "192.168.0.2"
+	c.checkServiceLoadBalancerIP("kube-clusterID-Ready", service, lb)
+	assert.Len(t, recorder.Events, 1)
+	event = <-recorder.Events
+	assert.Contains(t, event, "The loadBalancerIP 192.168.0.2 of the service was not assigned to the load balancer")
+}
+
+func TestCloudVpc_checkServiceSourceRanges(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, recorder)
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
+
+	// No source ranges, no event is generated
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Empty(t, recorder.Events)
+
+	// Source ranges are enforced by the managed security group, no event is generated
+	service.ObjectMeta.Annotations = map[string]string{serviceAnnotationEnableFeatures: LoadBalancerOptionManagedSecurityGroup}
+	service.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Empty(t, recorder.Events)
+
+	// Source ranges are not enforced, warning event is generated
+	service.ObjectMeta.Annotations = map[string]string{}
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Len(t, recorder.Events, 1)
+	event := <-recorder.Events
+	assert.Contains(t, event, enforcingSourceRangesFailed)
+	assert.Contains(t, event, "The loadBalancerSourceRanges [10.0.0.0/8] of the service are not enforced")
+
+	// Source ranges did not change, the event is not repeated
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Empty(t, recorder.Events)
+
+	// Load balancer was created, warning event is generated
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, true)
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Source ranges changed, the non-IPv4 entries are reported in the warning event
+	service.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "2001:db8::/32"}
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Len(t, recorder.Events, 1)
+	event = <-recorder.Events
+	assert.Contains(t, event, "The loadBalancerSourceRanges [10.0.0.0/8 2001:db8::/32] of the service are not enforced")
+
+	// Source ranges removed and set again, warning event is generated
+	service.Spec.LoadBalancerSourceRanges = nil
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	service.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "2001:db8::/32"}
+	c.checkServiceSourceRanges("kube-clusterID-Ready", service, false)
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Load balancer was deleted, the warnings of the service are cleared
+	c.clearServiceWarnings(service)
+	assert.Empty(t, c.serviceWarnings)
+}
+
 func TestCloudVpc_EnsureLoadBalancer(t *testing.T) {
 	c, _ := NewCloudVpc(fake.NewSimpleClientset(), &ConfigVpc{ClusterID: "clusterID", ProviderType: VpcProviderTypeFake}, nil)
 	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "192.168.0.1", Labels: map[string]string{}}}
@@ -71,8 +193,9 @@ func TestCloudVpc_EnsureLoadBalancer(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Failed ensuring LoadBalancer")
 
-	// EnsureLoadBalancer successful, existing LB was updated
-	service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
+	// EnsureLoadBalancer successful, existing LB was updated even though the service requests IPv6 and a loadBalancerIP
+	service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"},
+		Spec: v1.ServiceSpec{IPFamilies: []v1.IPFamily{v1.IPv6Protocol}, LoadBalancerIP: "192.168.0.3"}}
 	status, err = c.EnsureLoadBalancer("kube-clusterID-Ready", service, []*v1.Node{node})
 	assert.NotNil(t, status)
 	assert.Nil(t, err)
@@ -97,6 +220,17 @@ func TestCloudVpc_EnsureLoadBalancerDeleted(t *testing.T) {
 	err = c.EnsureLoadBalancerDeleted("kube-clusterID-NotFound", service)
 	assert.Nil(t, err)
 
+	// EnsureLoadBalancerDeleted failed, existing LB does not exist and the managed security group could not be deleted.
+	// The security group is deleted even if the managed-security-group option was removed from the service
+	c.Config.VpcName = "vpc"
+	c.SetFakeSdkError("DeleteSecurityGroup")
+	err = c.EnsureLoadBalancerDeleted("securityGroup", service)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Failed deleting security group: DeleteSecurityGroup failed")
+	c.ClearFakeSdkError("DeleteSecurityGroup")
+	err = c.EnsureLoadBalancerDeleted("securityGroup", service)
+	assert.Nil(t, err)
+
 	// EnsureLoadBalancerDeleted failed, failed to delete the LB
 	c.SetFakeSdkError("DeleteLoadBalancer")
 	service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
@@ -109,6 +243,12 @@ func TestCloudVpc_EnsureLoadBalancerDeleted(t *testing.T) {
 	service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "echo-server", Namespace: "default", UID: "Ready"}}
 	err = c.EnsureLoadBalancerDeleted("kube-clusterID-Ready", service)
 	assert.Nil(t, err)
+
+	// EnsureLoadBalancerDeleted retry, the LB delete was started and the managed security group will be deleted later
+	c.Sdk.(*VpcSdkFake).SecurityGroup.Name = "kube-clusterID-Ready"
+	err = c.EnsureLoadBalancerDeleted("kube-clusterID-Ready", service)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, errSecurityGroupDeletePending))
 }
 
 func TestCloud_EnsureLoadBalancerUpdated(t *testing.T) {
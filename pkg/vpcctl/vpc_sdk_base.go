@@ -31,23 +31,30 @@ import (
 
 // CloudVpcSdk interface for SDK operations
 type CloudVpcSdk interface {
-	CreateLoadBalancer(lbName string, nodeList, poolList, subnetList []string, options *ServiceOptions) (*VpcLoadBalancer, error)
-	CreateLoadBalancerListener(lbID, poolName, poolID string) (*VpcLoadBalancerListener, error)
+	CreateLoadBalancer(lbName string, nodeList, poolList, subnetList, securityGroupList []string, options *ServiceOptions) (*VpcLoadBalancer, error)
+	CreateLoadBalancerListener(lbID, poolName, poolID string, options *ServiceOptions) (*VpcLoadBalancerListener, error)
 	CreateLoadBalancerPool(lbID, poolName string, nodeList []string, options *ServiceOptions) (*VpcLoadBalancerPool, error)
-	CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string) (*VpcLoadBalancerPoolMember, error)
+	CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string, options *ServiceOptions) (*VpcLoadBalancerPoolMember, error)
+	CreateSecurityGroup(sgName, vpcID string, rules []*VpcSecurityGroupRule) (*VpcSecurityGroup, error)
+	CreateSecurityGroupRule(sgID string, rule *VpcSecurityGroupRule) (*VpcSecurityGroupRule, error)
 	DeleteLoadBalancer(lbID string) error
 	DeleteLoadBalancerListener(lbID, listenerID string) error
 	DeleteLoadBalancerPool(lbID, poolID string) error
 	DeleteLoadBalancerPoolMember(lbID, poolID, memberID string) error
+	DeleteSecurityGroup(sgID string) error
+	DeleteSecurityGroupRule(sgID, ruleID string) error
 	GetLoadBalancer(lbID string) (*VpcLoadBalancer, error)
 	GetSubnet(subnetID string) (*VpcSubnet, error)
 	ListLoadBalancers() ([]*VpcLoadBalancer, error)
 	ListLoadBalancerListeners(lbID string) ([]*VpcLoadBalancerListener, error)
 	ListLoadBalancerPools(lbID string) ([]*VpcLoadBalancerPool, error)
 	ListLoadBalancerPoolMembers(lbID, poolID string) ([]*VpcLoadBalancerPoolMember, error)
+	ListSecurityGroups(vpcID string) ([]*VpcSecurityGroup, error)
 	ListSubnets() ([]*VpcSubnet, error)
-	ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string) ([]*VpcLoadBalancerPoolMember, error)
+	ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string, options *ServiceOptions) ([]*VpcLoadBalancerPoolMember, error)
+	UpdateLoadBalancerListener(lbID string, existingListener, updatedListener *VpcLoadBalancerListener) (*VpcLoadBalancerListener, error)
 	UpdateLoadBalancerPool(lbID, newPoolName string, existingPool *VpcLoadBalancerPool, options *ServiceOptions) (*VpcLoadBalancerPool, error)
+	UpdateLoadBalancerPoolMember(lbID, poolID, memberID string, weight int64) (*VpcLoadBalancerPoolMember, error)
 }
 
 // NewVpcSdkProvider - name of SDK interface
@@ -106,11 +113,23 @@ func genLoadBalancerPoolName(kubePort v1.ServicePort) string {
 	return fmt.Sprintf("%s-%d-%d", strings.ToLower(string(kubePort.Protocol)), kubePort.Port, kubePort.NodePort)
 }
 
+// genLoadBalancerPoolNameFields - generate the VPC pool name fields for a specific Kubernetes service port
+func genLoadBalancerPoolNameFields(kubePort v1.ServicePort) *VpcPoolNameFields {
+	return &VpcPoolNameFields{
+		Protocol: strings.ToLower(string(kubePort.Protocol)),
+		Port:     int(kubePort.Port),
+		NodePort: int(kubePort.NodePort),
+	}
+}
+
 // ServiceOptions - options from Kubernetes Load Balancer service and methods to access those fields
 type ServiceOptions struct {
 	annotations         map[string]string
 	enabledFeatures     string
 	healthCheckNodePort int
+	memberWeights       map[string]int64
+	sourceRanges        []string
+	udpPorts            bool
 }
 
 // newServiceOptions - return blank/empty service option
@@ -127,6 +146,8 @@ func (c *CloudVpc) getServiceOptions(service *v1.Service) *ServiceOptions {
 		annotations:         service.Annotations,
 		enabledFeatures:     c.getServiceEnabledFeatures(service),
 		healthCheckNodePort: c.getServiceHealthCheckNodePort(service),
+		sourceRanges:        c.getServiceSourceRanges(service),
+		udpPorts:            c.isServiceProtocolUDP(service),
 	}
 }
 
@@ -138,6 +159,230 @@ func (options *ServiceOptions) getHealthCheckNodePort() int {
 	return options.healthCheckNodePort
 }
 
+// getSourceRanges - retrieve the loadBalancerSourceRanges of the service
+func (options *ServiceOptions) getSourceRanges() []string {
+	return options.sourceRanges
+}
+
+// getHealthMonitor - retrieve the health monitor settings for the specified pool
+func (options *ServiceOptions) getHealthMonitor(poolNameFields *VpcPoolNameFields) VpcLoadBalancerPoolHealthMonitor {
+	// The Delay, MaxRetries, and Timeout values listed below are the default values that are selected when
+	// a load balancer is created in the VPC UI.  These values may need to be adjusted for IKS clusters.
+	healthMonitor := VpcLoadBalancerPoolHealthMonitor{
+		Delay:      5,
+		MaxRetries: 2,
+		Port:       int64(poolNameFields.NodePort),
+		Timeout:    2,
+		Type:       LoadBalancerProtocolTCP,
+	}
+	switch {
+	case options.healthCheckNodePort > 0:
+		// If the service has: "externalTrafficPolicy: local", then set the health check to be HTTP
+		healthMonitor.Port = int64(options.healthCheckNodePort)
+		healthMonitor.Type = LoadBalancerProtocolHTTP
+		healthMonitor.URLPath = "/"
+	case poolNameFields.Protocol == LoadBalancerProtocolUDP:
+		// A UDP node port can not be checked with a TCP connect. Use the kube-proxy health check on the node instead
+		healthMonitor.Port = kubeProxyHealthCheckPort
+		healthMonitor.Type = LoadBalancerProtocolHTTP
+		healthMonitor.URLPath = kubeProxyHealthCheckPath
+	}
+	// Override the default values with any of the health check service annotations
+	if protocol := options.getHealthCheckProtocol(); protocol != "" {
+		healthMonitor.Type = protocol
+		if protocol == LoadBalancerProtocolTCP {
+			healthMonitor.URLPath = ""
+		} else if healthMonitor.URLPath == "" {
+			healthMonitor.URLPath = "/"
+		}
+	}
+	if path := options.getHealthCheckPath(); path != "" {
+		// The path is only used by HTTP health checks. Switch the default TCP health check to HTTP
+		healthMonitor.URLPath = path
+		if healthMonitor.Type == LoadBalancerProtocolTCP {
+			healthMonitor.Type = LoadBalancerProtocolHTTP
+		}
+	}
+	if port := options.getAnnotationInt(serviceAnnotationHealthCheckPort); port > 0 {
+		healthMonitor.Port = int64(port)
+	}
+	if delay := options.getAnnotationInt(serviceAnnotationHealthCheckDelay); delay > 0 {
+		healthMonitor.Delay = int64(delay)
+	}
+	if retries := options.getAnnotationInt(serviceAnnotationHealthCheckRetries); retries > 0 {
+		healthMonitor.MaxRetries = int64(retries)
+	}
+	if timeout := options.getAnnotationInt(serviceAnnotationHealthCheckTimeout); timeout > 0 {
+		healthMonitor.Timeout = int64(timeout)
+	}
+	return healthMonitor
+}
+
+// getHealthCheckPath - retrieve the health check path annotation
+func (options *ServiceOptions) getHealthCheckPath() string {
+	return strings.TrimSpace(options.annotations[serviceAnnotationHealthCheckPath])
+}
+
+// getHealthCheckProtocol - retrieve the health check protocol annotation
+func (options *ServiceOptions) getHealthCheckProtocol() string {
+	return strings.ToLower(strings.TrimSpace(options.annotations[serviceAnnotationHealthCheckProto]))
+}
+
+// getAnnotationInt - retrieve the numeric value of the annotation, 0 is returned if it is not set or not a number
+func (options *ServiceOptions) getAnnotationInt(annotation string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(options.annotations[annotation]))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// getCertificateCRN - retrieve the CRN of the certificate used by the HTTPS listeners
+func (options *ServiceOptions) getCertificateCRN() string {
+	return strings.TrimSpace(options.annotations[serviceAnnotationCertificateCRN])
+}
+
+// isHTTPSPort - return true if TLS for the service port is terminated by an HTTPS listener
+func (options *ServiceOptions) isHTTPSPort(port int) bool {
+	for _, value := range strings.Split(options.annotations[serviceAnnotationHTTPSPorts], ",") {
+		if strings.TrimSpace(value) == strconv.Itoa(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// getHTTPSRedirectPort - return the HTTPS port that the HTTP listener of the service port is redirected to,
+// zero if the port is not redirected. The annotation is a comma separated list of <http-port>:<https-port>
+func (options *ServiceOptions) getHTTPSRedirectPort(port int) int {
+	for _, value := range strings.Split(options.annotations[serviceAnnotationHTTPSRedirect], ",") {
+		ports := strings.Split(value, ":")
+		if len(ports) == 2 && strings.TrimSpace(ports[0]) == strconv.Itoa(port) {
+			redirectPort, _ := strconv.Atoi(strings.TrimSpace(ports[1]))
+			return redirectPort
+		}
+	}
+	return 0
+}
+
+// getListenerProtocol - return the protocol of the listener for the pool. TCP ports can be
+// terminated by an HTTPS listener or redirected to one by an HTTP listener
+func (options *ServiceOptions) getListenerProtocol(poolNameFields *VpcPoolNameFields) string {
+	if poolNameFields.Protocol != LoadBalancerProtocolTCP {
+		return poolNameFields.Protocol
+	}
+	switch {
+	case options.isHTTPSPort(poolNameFields.Port):
+		return LoadBalancerProtocolHTTPS
+	case options.getHTTPSRedirectPort(poolNameFields.Port) > 0:
+		return LoadBalancerProtocolHTTP
+	}
+	return LoadBalancerProtocolTCP
+}
+
+// getPoolProtocol - return the protocol of the pool. The pool of an HTTP or HTTPS listener must use HTTP
+func (options *ServiceOptions) getPoolProtocol(poolNameFields *VpcPoolNameFields) string {
+	switch options.getListenerProtocol(poolNameFields) {
+	case LoadBalancerProtocolHTTP, LoadBalancerProtocolHTTPS:
+		return LoadBalancerProtocolHTTP
+	}
+	return poolNameFields.Protocol
+}
+
+// getConnectionLimit - retrieve the connection limit annotation, the VPC maximum is the default
+func (options *ServiceOptions) getConnectionLimit() int64 {
+	if limit := options.getAnnotationInt(serviceAnnotationConnectionLimit); limit > 0 {
+		return int64(limit)
+	}
+	return LoadBalancerConnectionLimitDefault
+}
+
+// getIdleConnectionTimeout - retrieve the idle connection timeout annotation.
+// Zero is returned for network load balancers since they do not support the setting
+func (options *ServiceOptions) getIdleConnectionTimeout() int64 {
+	if options.isNLB() {
+		return 0
+	}
+	if timeout := options.getAnnotationInt(serviceAnnotationIdleTimeout); timeout > 0 {
+		return int64(timeout)
+	}
+	return LoadBalancerIdleConnectionTimeoutDefault
+}
+
+// getPortRange - return the listener port range of the service port. The annotation is a comma separated list
+// of <min>-<max> port ranges. If the port is not in one of the ranges, the range only contains the port itself
+func (options *ServiceOptions) getPortRange(port int) (int, int) {
+	for _, value := range strings.Split(options.annotations[serviceAnnotationPortRange], ",") {
+		portMin, portMax := parsePortRange(value)
+		if portMin > 0 && port >= portMin && port <= portMax {
+			return portMin, portMax
+		}
+	}
+	return port, port
+}
+
+// isPortRangeMember - return true if the service port is part of a port range but is not the first port of the range.
+// These ports share the listener and pool of the first port of the range
+func (options *ServiceOptions) isPortRangeMember(port int) bool {
+	portMin, _ := options.getPortRange(port)
+	return portMin != port
+}
+
+// parsePortRange - parse a <min>-<max> port range, zeros are returned if the range is not valid
+func parsePortRange(value string) (int, int) {
+	ports := strings.Split(strings.TrimSpace(value), "-")
+	if len(ports) != 2 {
+		return 0, 0
+	}
+	portMin, errMin := strconv.Atoi(strings.TrimSpace(ports[0]))
+	portMax, errMax := strconv.Atoi(strings.TrimSpace(ports[1]))
+	if errMin != nil || errMax != nil || portMin < 1 || portMax > 65535 || portMin >= portMax {
+		return 0, 0
+	}
+	return portMin, portMax
+}
+
+// getPoolAlgorithm - retrieve the pool algorithm annotation, round robin is the default. If the member weights
+// option is enabled, weighted round robin is the default. The default only depends on the annotations of the service,
+// so that every pool update path sets the same algorithm
+func (options *ServiceOptions) getPoolAlgorithm() string {
+	algorithm := strings.ToLower(strings.TrimSpace(options.annotations[serviceAnnotationPoolAlgorithm]))
+	if algorithm == "" {
+		if options.isMemberWeights() {
+			return LoadBalancerAlgorithmWeightedRoundRobin
+		}
+		return LoadBalancerAlgorithmRoundRobin
+	}
+	return algorithm
+}
+
+// getMemberWeight - retrieve the weight of the pool member for the node. False is returned if member weights
+// were not requested or the weight of the node is not known
+func (options *ServiceOptions) getMemberWeight(nodeID string) (int64, bool) {
+	weight, ok := options.memberWeights[nodeID]
+	return weight, ok
+}
+
+// getSessionPersistence - retrieve the session persistence annotation, None is returned if it is not set
+func (options *ServiceOptions) getSessionPersistence() string {
+	sessionPersistence := strings.ToLower(strings.TrimSpace(options.annotations[serviceAnnotationSessionPersistence]))
+	if sessionPersistence == "" || sessionPersistence == strings.ToLower(LoadBalancerSessionPersistenceNone) {
+		return LoadBalancerSessionPersistenceNone
+	}
+	return sessionPersistence
+}
+
+// getSecurityGroups - retrieve the names and IDs of the security groups listed in the vpc-security-groups annotation
+func (options *ServiceOptions) getSecurityGroups() []string {
+	securityGroups := []string{}
+	for _, securityGroup := range strings.Split(options.annotations[serviceAnnotationSecurityGroups], ",") {
+		if securityGroup = strings.TrimSpace(securityGroup); securityGroup != "" {
+			securityGroups = append(securityGroups, securityGroup)
+		}
+	}
+	return securityGroups
+}
+
 // getServiceSubnets - retrieve the vpc-subnets annotation
 func (options *ServiceOptions) getServiceSubnets() string {
 	return strings.ReplaceAll(options.annotations[serviceAnnotationSubnets], " ", "")
@@ -148,9 +393,48 @@ func (options *ServiceOptions) getServiceZone() string {
 	return strings.ReplaceAll(options.annotations[serviceAnnotationZone], " ", "")
 }
 
-// isProxyProtocol - return true if service has proxy-protocol enabled
+// isInstanceTargets - return true if the pool members should target the VPC instances instead of the node IP addresses
+func (options *ServiceOptions) isInstanceTargets() bool {
+	return isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionInstanceTargets)
+}
+
+// isMemberWeights - return true if the weight of each pool member should be set from the node
+func (options *ServiceOptions) isMemberWeights() bool {
+	return isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionMemberWeights)
+}
+
+// isManagedSecurityGroup - return true if a security group should be created and managed for the load balancer
+func (options *ServiceOptions) isManagedSecurityGroup() bool {
+	return isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionManagedSecurityGroup)
+}
+
+// isNLB - return true if service requested a network load balancer
+//
+// UDP listeners are only supported by load balancers in the `network` family, so a service with UDP ports
+// always requires a network load balancer
+func (options *ServiceOptions) isNLB() bool {
+	return options.udpPorts || isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionNLB)
+}
+
+// getProxyProtocol - return the PROXY protocol version to be set on the pools: disabled, v1, or v2
+func (options *ServiceOptions) getProxyProtocol() string {
+	switch {
+	case isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionProxyProtocolV2):
+		return LoadBalancerProxyProtocolV2
+	case isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionProxyProtocol):
+		return LoadBalancerProxyProtocolV1
+	}
+	return LoadBalancerProxyProtocolDisabled
+}
+
+// isProxyProtocol - return true if service has proxy-protocol or proxy-protocol-v2 enabled
 func (options *ServiceOptions) isProxyProtocol() bool {
-	return isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionProxyProtocol)
+	return options.getProxyProtocol() != LoadBalancerProxyProtocolDisabled
+}
+
+// isPublishIPs - return true if the IPs of the load balancer should be published in the service status
+func (options *ServiceOptions) isPublishIPs() bool {
+	return isVpcOptionEnabled(options.enabledFeatures, LoadBalancerOptionPublishIPs)
 }
 
 // isPublic - return true if service is public LB
@@ -228,6 +512,30 @@ const (
 	LoadBalancerProtocolHTTP  = "http"
 	LoadBalancerProtocolHTTPS = "https"
 	LoadBalancerProtocolTCP   = "tcp"
+	LoadBalancerProtocolUDP   = "udp"
+)
+
+// Constants associated with the LoadBalancer*.Profile property.
+// The profile and profile family of the network load balancer.
+const (
+	LoadBalancerProfileFamilyNetwork = "network"
+	LoadBalancerProfileNetworkFixed  = "network-fixed"
+)
+
+// Constants associated with the connection settings of the LoadBalancerListener
+const (
+	LoadBalancerConnectionLimitDefault       = 15000
+	LoadBalancerIdleConnectionTimeoutDefault = 50
+	LoadBalancerIdleConnectionTimeoutMax     = 7200
+)
+
+// LoadBalancerHTTPSRedirectStatusCode - HTTP status code returned by an HTTP listener that is redirected to an HTTPS listener
+const LoadBalancerHTTPSRedirectStatusCode = 301
+
+// Constants associated with the kube-proxy health check endpoint on each of the nodes
+const (
+	kubeProxyHealthCheckPath = "/healthz"
+	kubeProxyHealthCheckPort = 10256
 )
 
 // Constants associated with the LoadBalancerPool.Algorithm property.
@@ -238,6 +546,13 @@ const (
 	LoadBalancerAlgorithmWeightedRoundRobin = "weighted_round_robin"
 )
 
+// Constants associated with the LoadBalancerPoolMember.Weight property.
+// The weight only takes effect when the load balancing algorithm of the pool is `weighted_round_robin`.
+const (
+	LoadBalancerMemberWeightDefault = 50
+	LoadBalancerMemberWeightMax     = 100
+)
+
 // Constants associated with the LoadBalancerPool.ProxyProtocol property.
 // The PROXY protocol setting for this pool:
 // - `v1`: Enabled with version 1 (human-readable header format)
@@ -252,14 +567,29 @@ const (
 )
 
 // Constants associated with the LoadBalancerPoolSessionPersistence.Type property.
-// The session persistence type.
+// The session persistence type. None is used when session persistence is not set on the pool.
 const (
+	LoadBalancerSessionPersistenceNone     = "None"
 	LoadBalancerSessionPersistenceSourceIP = "source_ip"
 )
 
 // Constants that can control the behavior of the VPC LoadBalancer
 const (
-	LoadBalancerOptionProxyProtocol = "proxy-protocol"
+	LoadBalancerOptionInstanceTargets      = "instance-targets"
+	LoadBalancerOptionManagedSecurityGroup = "managed-security-group"
+	LoadBalancerOptionMemberWeights        = "member-weights"
+	LoadBalancerOptionNLB                  = "nlb"
+	LoadBalancerOptionProxyProtocol        = "proxy-protocol"
+	LoadBalancerOptionProxyProtocolV2      = "proxy-protocol-v2"
+	LoadBalancerOptionPublishIPs           = "publish-ips"
+)
+
+// Constants associated with the SecurityGroupRule.Direction and SecurityGroupRule.Protocol properties
+const (
+	SecurityGroupRuleDirectionInbound  = "inbound"
+	SecurityGroupRuleDirectionOutbound = "outbound"
+	SecurityGroupRuleProtocolAll       = "all"
+	SecurityGroupRuleRemoteAny         = "0.0.0.0/0"
 )
 
 // VpcObjectReference ...
@@ -337,6 +667,10 @@ type VpcLoadBalancer struct {
 	// ResourceGroup *ResourceGroupReference `json:"resource_group" validate:"required"`
 	ResourceGroup VpcObjectReference
 
+	// The security groups targeting this load balancer.
+	// SecurityGroups []SecurityGroupReference `json:"security_groups" validate:"required"`
+	SecurityGroups []VpcObjectReference
+
 	// Collection of service IP addresses for this load balancer.
 	// ServiceIps []LoadBalancerServiceIPs `json:"service_ips,omitempty"`
 	// Service IPs will be returned in the PrivateIps field
@@ -356,6 +690,14 @@ func (lb *VpcLoadBalancer) GetStatus() string {
 	return fmt.Sprintf("%s/%s", lb.OperatingStatus, lb.ProvisioningStatus)
 }
 
+// getIPs - returns the IP addresses of the VPC load balancer. The public IPs are returned for a public load balancer
+func (lb *VpcLoadBalancer) getIPs() []string {
+	if lb.IsPublic {
+		return lb.PublicIps
+	}
+	return lb.PrivateIps
+}
+
 // getSubnetIDs - returns list of subnet IDs associated with the VPC load balancer
 func (lb *VpcLoadBalancer) getSubnetIDs() []string {
 	subnetList := []string{}
@@ -432,7 +774,7 @@ func (lb *VpcLoadBalancer) getZones(vpcSubnets []*VpcSubnet) []string {
 
 // IsNLB - returns true of the load balancer is a Network Load Balancer
 func (lb *VpcLoadBalancer) IsNLB() bool {
-	return strings.EqualFold(lb.ProfileFamily, "network")
+	return strings.EqualFold(lb.ProfileFamily, LoadBalancerProfileFamilyNetwork)
 }
 
 // IsReady - returns a flag indicating if the load balancer will allow additional operations to be done
@@ -446,6 +788,7 @@ type VpcLoadBalancerListener struct {
 	// The certificate instance used for SSL termination. It is applicable only to `https`
 	// protocol.
 	// CertificateInstance *CertificateInstanceReference `json:"certificate_instance,omitempty"`
+	CertificateInstance string
 
 	// The connection limit of the listener.
 	// ConnectionLimit *int64 `json:"connection_limit,omitempty"`
@@ -461,17 +804,33 @@ type VpcLoadBalancerListener struct {
 	// The listener's canonical URL.
 	// Href *string `json:"href" validate:"required"`
 
+	// The ID of the HTTPS listener that the requests to this HTTP listener are redirected to.
+	// HTTPSRedirect *LoadBalancerListenerHTTPSRedirect `json:"https_redirect,omitempty"`
+	HTTPSRedirectListener string
+
 	// The unique identifier for this load balancer listener.
 	// ID *string `json:"id" validate:"required"`
 	ID string
 
+	// The idle connection timeout of the listener in seconds.
+	// IdleConnectionTimeout *int64 `json:"idle_connection_timeout,omitempty"`
+	IdleConnectionTimeout int64
+
 	// The list of policies of this listener.
 	// Policies []LoadBalancerListenerPolicyReference `json:"policies,omitempty"`
 
-	// The listener port number.
+	// The listener port number, or the inclusive lower bound of the port range.
 	// Port *int64 `json:"port" validate:"required"`
 	Port int64
 
+	// The inclusive upper bound of the range of ports used by this listener.
+	// PortMax *int64 `json:"port_max" validate:"required"`
+	PortMax int64
+
+	// The inclusive lower bound of the range of ports used by this listener.
+	// PortMin *int64 `json:"port_min" validate:"required"`
+	PortMin int64
+
 	// The listener protocol.
 	// Protocol *string `json:"protocol" validate:"required"`
 	Protocol string
@@ -481,6 +840,11 @@ type VpcLoadBalancerListener struct {
 	ProvisioningStatus string
 }
 
+// IsPortRange - returns true if the listener uses a range of ports
+func (listener *VpcLoadBalancerListener) IsPortRange() bool {
+	return listener.PortMax > listener.Port
+}
+
 // VpcLoadBalancerPool ...
 type VpcLoadBalancerPool struct {
 	// The load balancing algorithm.
@@ -602,6 +966,63 @@ type VpcLoadBalancerPoolMember struct {
 	Weight int64
 }
 
+// getTarget - return the target of the pool member: the IP address, or the instance ID for an instance target
+func (member *VpcLoadBalancerPoolMember) getTarget() string {
+	if member.TargetIPAddress != "" {
+		return member.TargetIPAddress
+	}
+	return member.TargetInstanceID
+}
+
+// VpcSecurityGroup ...
+type VpcSecurityGroup struct {
+	// The date and time that this security group was created.
+	// CreatedAt *strfmt.DateTime `json:"created_at" validate:"required"`
+
+	// The unique identifier for this security group.
+	// ID *string `json:"id" validate:"required"`
+	ID string
+
+	// The name for this security group. The name is unique across all security groups for the VPC.
+	// Name *string `json:"name" validate:"required"`
+	Name string
+
+	// The rules for this security group. If no rules exist, all traffic will be denied.
+	// Rules []SecurityGroupRuleIntf `json:"rules" validate:"required"`
+	Rules []*VpcSecurityGroupRule
+
+	// The VPC this security group resides in.
+	// VPC *VPCReference `json:"vpc" validate:"required"`
+	VpcID string
+}
+
+// VpcSecurityGroupRule ...
+type VpcSecurityGroupRule struct {
+	// The direction of traffic to allow.
+	// Direction *string `json:"direction" validate:"required"`
+	Direction string
+
+	// The unique identifier for this security group rule.
+	// ID *string `json:"id" validate:"required"`
+	ID string
+
+	// The inclusive upper bound of TCP/UDP destination port range.
+	// PortMax *int64 `json:"port_max,omitempty"`
+	PortMax int64
+
+	// The inclusive lower bound of TCP/UDP destination port range.
+	// PortMin *int64 `json:"port_min,omitempty"`
+	PortMin int64
+
+	// The protocol to allow.
+	// Protocol *string `json:"protocol" validate:"required"`
+	Protocol string
+
+	// The CIDR block from which this rule allows traffic (or to which, for outbound rules).
+	// Remote SecurityGroupRuleRemoteIntf `json:"remote" validate:"required"`
+	Remote string
+}
+
 // VpcSubnet ...
 type VpcSubnet struct {
 	// Saved copy of the actual SDK object
@@ -90,8 +90,9 @@ func TestGetServiceOptions(t *testing.T) {
 				serviceAnnotationZone:           "us-south-1",
 			}},
 		Spec: v1.ServiceSpec{
-			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
-			HealthCheckNodePort:   36963,
+			ExternalTrafficPolicy:    v1.ServiceExternalTrafficPolicyTypeLocal,
+			HealthCheckNodePort:      36963,
+			LoadBalancerSourceRanges: []string{" 10.0.0.0/8 ", "", "192.168.1.0/24"},
 		}}
 
 	// getServiceOptions called with no service
@@ -103,8 +104,10 @@ func TestGetServiceOptions(t *testing.T) {
 	assert.Equal(t, options.getHealthCheckNodePort(), 0)
 	assert.Equal(t, options.getServiceSubnets(), "")
 	assert.Equal(t, options.getServiceZone(), "")
+	assert.False(t, options.isNLB())
 	assert.False(t, options.isProxyProtocol())
 	assert.True(t, options.isPublic())
+	assert.Empty(t, options.getSourceRanges())
 
 	// getServiceOptions called with a mock service (nlb, sDNLB, proxy-protocol, private ...)
 	options = mockCloud.getServiceOptions(mockService)
@@ -115,8 +118,229 @@ func TestGetServiceOptions(t *testing.T) {
 	assert.Equal(t, options.getHealthCheckNodePort(), 36963)
 	assert.Equal(t, options.getServiceSubnets(), "vpc-subnets")
 	assert.Equal(t, options.getServiceZone(), "us-south-1")
+	assert.Equal(t, options.getSourceRanges(), []string{"10.0.0.0/8", "192.168.1.0/24"})
 	assert.True(t, options.isProxyProtocol())
 	assert.False(t, options.isPublic())
+	assert.False(t, options.isNLB())
+
+	// getServiceOptions called with a mock service requesting a network load balancer
+	mockService.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = "generic-option, NLB"
+	options = mockCloud.getServiceOptions(mockService)
+	assert.True(t, options.isNLB())
+	assert.False(t, options.isProxyProtocol())
+	assert.False(t, options.isPublishIPs())
+
+	// getServiceOptions called with a mock service requesting the IPs be published
+	mockService.ObjectMeta.Annotations[serviceAnnotationEnableFeatures] = LoadBalancerOptionPublishIPs
+	options = mockCloud.getServiceOptions(mockService)
+	assert.True(t, options.isPublishIPs())
+}
+
+func TestServiceOptions_getProxyProtocol(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getProxyProtocol(), LoadBalancerProxyProtocolDisabled)
+	assert.False(t, options.isProxyProtocol())
+	options.enabledFeatures = LoadBalancerOptionProxyProtocol
+	assert.Equal(t, options.getProxyProtocol(), LoadBalancerProxyProtocolV1)
+	assert.True(t, options.isProxyProtocol())
+	options.enabledFeatures = "generic-option," + LoadBalancerOptionProxyProtocolV2
+	assert.Equal(t, options.getProxyProtocol(), LoadBalancerProxyProtocolV2)
+	assert.True(t, options.isProxyProtocol())
+}
+
+func TestServiceOptions_getHealthMonitor(t *testing.T) {
+	options := newServiceOptions()
+
+	// TCP pool, externalTrafficPolicy: Cluster
+	healthMonitor := options.getHealthMonitor(&VpcPoolNameFields{Protocol: "tcp", Port: 80, NodePort: 30303})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolTCP)
+	assert.Equal(t, healthMonitor.Port, int64(30303))
+	assert.Equal(t, healthMonitor.URLPath, "")
+	assert.Equal(t, healthMonitor.Delay, int64(5))
+	assert.Equal(t, healthMonitor.MaxRetries, int64(2))
+	assert.Equal(t, healthMonitor.Timeout, int64(2))
+
+	// UDP pool, externalTrafficPolicy: Cluster
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "udp", Port: 53, NodePort: 30053})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolHTTP)
+	assert.Equal(t, healthMonitor.Port, int64(kubeProxyHealthCheckPort))
+	assert.Equal(t, healthMonitor.URLPath, kubeProxyHealthCheckPath)
+
+	// UDP pool, externalTrafficPolicy: Local
+	options.healthCheckNodePort = 36963
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "udp", Port: 53, NodePort: 30053})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolHTTP)
+	assert.Equal(t, healthMonitor.Port, int64(36963))
+	assert.Equal(t, healthMonitor.URLPath, "/")
+
+	// Health check annotations override the default values
+	options.annotations = map[string]string{
+		serviceAnnotationHealthCheckDelay:   "10",
+		serviceAnnotationHealthCheckPath:    "/ready",
+		serviceAnnotationHealthCheckPort:    "8080",
+		serviceAnnotationHealthCheckProto:   "HTTPS",
+		serviceAnnotationHealthCheckRetries: "3",
+		serviceAnnotationHealthCheckTimeout: "5",
+	}
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "tcp", Port: 80, NodePort: 30303})
+	assert.Equal(t, healthMonitor, VpcLoadBalancerPoolHealthMonitor{
+		Delay: 10, MaxRetries: 3, Port: 8080, Timeout: 5, Type: LoadBalancerProtocolHTTPS, URLPath: "/ready"})
+
+	// TCP health check annotation clears the path
+	options.annotations = map[string]string{serviceAnnotationHealthCheckProto: "tcp"}
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "tcp", Port: 80, NodePort: 30303})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolTCP)
+	assert.Equal(t, healthMonitor.Port, int64(36963))
+	assert.Equal(t, healthMonitor.URLPath, "")
+
+	// HTTP health check annotation defaults the path
+	options.healthCheckNodePort = 0
+	options.annotations = map[string]string{serviceAnnotationHealthCheckProto: "http"}
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "tcp", Port: 80, NodePort: 30303})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolHTTP)
+	assert.Equal(t, healthMonitor.Port, int64(30303))
+	assert.Equal(t, healthMonitor.URLPath, "/")
+
+	// Health check path annotation switches the default TCP health check to HTTP
+	options.annotations = map[string]string{serviceAnnotationHealthCheckPath: "/ready"}
+	healthMonitor = options.getHealthMonitor(&VpcPoolNameFields{Protocol: "tcp", Port: 80, NodePort: 30303})
+	assert.Equal(t, healthMonitor.Type, LoadBalancerProtocolHTTP)
+	assert.Equal(t, healthMonitor.URLPath, "/ready")
+}
+
+func TestServiceOptions_getListenerProtocol(t *testing.T) {
+	options := newServiceOptions()
+	options.annotations[serviceAnnotationHTTPSPorts] = "443, 8443"
+	options.annotations[serviceAnnotationHTTPSRedirect] = "80:443"
+	assert.True(t, options.isHTTPSPort(8443))
+	assert.False(t, options.isHTTPSPort(80))
+	assert.Equal(t, options.getHTTPSRedirectPort(80), 443)
+	assert.Equal(t, options.getHTTPSRedirectPort(8080), 0)
+
+	// HTTPS port
+	poolNameFields := &VpcPoolNameFields{Protocol: LoadBalancerProtocolTCP, Port: 443, NodePort: 30443}
+	assert.Equal(t, options.getListenerProtocol(poolNameFields), LoadBalancerProtocolHTTPS)
+	assert.Equal(t, options.getPoolProtocol(poolNameFields), LoadBalancerProtocolHTTP)
+
+	// HTTP port redirected to HTTPS
+	poolNameFields = &VpcPoolNameFields{Protocol: LoadBalancerProtocolTCP, Port: 80, NodePort: 30080}
+	assert.Equal(t, options.getListenerProtocol(poolNameFields), LoadBalancerProtocolHTTP)
+	assert.Equal(t, options.getPoolProtocol(poolNameFields), LoadBalancerProtocolHTTP)
+
+	// TCP and UDP ports
+	poolNameFields = &VpcPoolNameFields{Protocol: LoadBalancerProtocolTCP, Port: 22, NodePort: 30022}
+	assert.Equal(t, options.getListenerProtocol(poolNameFields), LoadBalancerProtocolTCP)
+	assert.Equal(t, options.getPoolProtocol(poolNameFields), LoadBalancerProtocolTCP)
+	poolNameFields = &VpcPoolNameFields{Protocol: LoadBalancerProtocolUDP, Port: 443, NodePort: 30443}
+	assert.Equal(t, options.getListenerProtocol(poolNameFields), LoadBalancerProtocolUDP)
+	assert.Equal(t, options.getPoolProtocol(poolNameFields), LoadBalancerProtocolUDP)
+}
+
+func TestServiceOptions_getPortRange(t *testing.T) {
+	options := newServiceOptions()
+	portMin, portMax := options.getPortRange(80)
+	assert.Equal(t, portMin, 80)
+	assert.Equal(t, portMax, 80)
+	assert.False(t, options.isPortRangeMember(80))
+
+	options.annotations[serviceAnnotationPortRange] = "30000-30002, 30010-30020"
+	portMin, portMax = options.getPortRange(30000)
+	assert.Equal(t, portMin, 30000)
+	assert.Equal(t, portMax, 30002)
+	assert.False(t, options.isPortRangeMember(30000))
+	portMin, portMax = options.getPortRange(30015)
+	assert.Equal(t, portMin, 30010)
+	assert.Equal(t, portMax, 30020)
+	assert.True(t, options.isPortRangeMember(30015))
+	portMin, portMax = options.getPortRange(30005)
+	assert.Equal(t, portMin, 30005)
+	assert.Equal(t, portMax, 30005)
+	assert.False(t, options.isPortRangeMember(30005))
+}
+
+func TestParsePortRange(t *testing.T) {
+	for value, expected := range map[string][2]int{
+		"30000-30002": {30000, 30002},
+		" 1 - 65535 ": {1, 65535},
+		"30000":       {0, 0},
+		"30002-30000": {0, 0},
+		"30000-30000": {0, 0},
+		"0-100":       {0, 0},
+		"100-70000":   {0, 0},
+		"abc-100":     {0, 0},
+		"100-200-300": {0, 0},
+		"":            {0, 0},
+	} {
+		portMin, portMax := parsePortRange(value)
+		assert.Equal(t, [2]int{portMin, portMax}, expected, value)
+	}
+}
+
+func TestServiceOptions_getConnectionLimit(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getConnectionLimit(), int64(LoadBalancerConnectionLimitDefault))
+	options.annotations[serviceAnnotationConnectionLimit] = "2000"
+	assert.Equal(t, options.getConnectionLimit(), int64(2000))
+}
+
+func TestServiceOptions_getIdleConnectionTimeout(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getIdleConnectionTimeout(), int64(LoadBalancerIdleConnectionTimeoutDefault))
+	options.annotations[serviceAnnotationIdleTimeout] = "300"
+	assert.Equal(t, options.getIdleConnectionTimeout(), int64(300))
+	// Network load balancers do not support the idle connection timeout
+	options.enabledFeatures = LoadBalancerOptionNLB
+	assert.Equal(t, options.getIdleConnectionTimeout(), int64(0))
+}
+
+func TestServiceOptions_getPoolAlgorithm(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmRoundRobin)
+	options.annotations[serviceAnnotationPoolAlgorithm] = " Weighted_Round_Robin "
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmWeightedRoundRobin)
+
+	// Member weights option is enabled, weighted round robin is the default whether or not the weights are known
+	options = newServiceOptions()
+	options.enabledFeatures = LoadBalancerOptionMemberWeights
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmWeightedRoundRobin)
+	options.memberWeights = map[string]int64{"192.168.1.1": 4, "192.168.2.2": 4}
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmWeightedRoundRobin)
+	options.annotations[serviceAnnotationPoolAlgorithm] = LoadBalancerAlgorithmRoundRobin
+	assert.Equal(t, options.getPoolAlgorithm(), LoadBalancerAlgorithmRoundRobin)
+}
+
+func TestServiceOptions_getMemberWeight(t *testing.T) {
+	options := newServiceOptions()
+	assert.False(t, options.isMemberWeights())
+	weight, ok := options.getMemberWeight("192.168.1.1")
+	assert.False(t, ok)
+	assert.Equal(t, weight, int64(0))
+
+	options.enabledFeatures = LoadBalancerOptionMemberWeights
+	options.memberWeights = map[string]int64{"192.168.1.1": 0}
+	assert.True(t, options.isMemberWeights())
+	weight, ok = options.getMemberWeight("192.168.1.1")
+	assert.True(t, ok)
+	assert.Equal(t, weight, int64(0))
+}
+
+func TestServiceOptions_getSessionPersistence(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getSessionPersistence(), LoadBalancerSessionPersistenceNone)
+	options.annotations[serviceAnnotationSessionPersistence] = "none"
+	assert.Equal(t, options.getSessionPersistence(), LoadBalancerSessionPersistenceNone)
+	options.annotations[serviceAnnotationSessionPersistence] = "SOURCE_IP"
+	assert.Equal(t, options.getSessionPersistence(), LoadBalancerSessionPersistenceSourceIP)
+}
+
+func TestServiceOptions_getSecurityGroups(t *testing.T) {
+	options := newServiceOptions()
+	assert.Equal(t, options.getSecurityGroups(), []string{})
+	assert.False(t, options.isManagedSecurityGroup())
+	options.annotations[serviceAnnotationSecurityGroups] = " sg-1, ,r006-1234 "
+	assert.Equal(t, options.getSecurityGroups(), []string{"sg-1", "r006-1234"})
+	options.enabledFeatures = LoadBalancerOptionNLB + "," + LoadBalancerOptionManagedSecurityGroup
+	assert.True(t, options.isManagedSecurityGroup())
 }
 
 func TestIsVpcOptionEnabled(t *testing.T) {
@@ -181,6 +405,13 @@ func TestVpcLoadBalancer_GetStatus(t *testing.T) {
 	assert.Equal(t, result, "online/active")
 }
 
+func TestVpcLoadBalancer_getIPs(t *testing.T) {
+	lb := &VpcLoadBalancer{IsPublic: true, PrivateIps: []string{"10.0.0.1"}, PublicIps: []string{"192.168.0.1"}}
+	assert.Equal(t, lb.getIPs(), []string{"192.168.0.1"})
+	lb.IsPublic = false
+	assert.Equal(t, lb.getIPs(), []string{"10.0.0.1"})
+}
+
 func TestVpcLoadBalancer_getSubnetIDs(t *testing.T) {
 	lb := &VpcLoadBalancer{
 		Subnets: []VpcObjectReference{{ID: "subnet-1"}, {ID: "subnet-2"}},
@@ -30,9 +30,12 @@ type VpcSdkFake struct {
 	LoadBalancerReady    *VpcLoadBalancer
 	LoadBalancerNotReady *VpcLoadBalancer
 	Listener             *VpcLoadBalancerListener
+	Listeners            []*VpcLoadBalancerListener
 	Pool                 *VpcLoadBalancerPool
+	Pools                []*VpcLoadBalancerPool
 	Member1              *VpcLoadBalancerPoolMember
 	Member2              *VpcLoadBalancerPoolMember
+	SecurityGroup        *VpcSecurityGroup
 	Subnet1              *VpcSubnet
 	Subnet2              *VpcSubnet
 }
@@ -64,11 +67,13 @@ func NewVpcSdkFake() (CloudVpcSdk, error) {
 		Subnets:            []VpcObjectReference{{Name: "subnet2", ID: "2222"}},
 	}
 	listener := &VpcLoadBalancerListener{
-		DefaultPool:        VpcObjectReference{Name: "tcp-80-30303"},
-		ID:                 "listener",
-		Port:               80,
-		Protocol:           LoadBalancerProtocolTCP,
-		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
+		ConnectionLimit:       LoadBalancerConnectionLimitDefault,
+		DefaultPool:           VpcObjectReference{Name: "tcp-80-30303"},
+		ID:                    "listener",
+		IdleConnectionTimeout: LoadBalancerIdleConnectionTimeoutDefault,
+		Port:                  80,
+		Protocol:              LoadBalancerProtocolTCP,
+		ProvisioningStatus:    LoadBalancerProvisioningStatusActive,
 	}
 	member1 := &VpcLoadBalancerPoolMember{
 		Health:             "ok",
@@ -89,10 +94,10 @@ func NewVpcSdkFake() (CloudVpcSdk, error) {
 	pool := &VpcLoadBalancerPool{
 		Algorithm: LoadBalancerAlgorithmRoundRobin,
 		HealthMonitor: VpcLoadBalancerPoolHealthMonitor{
-			Delay:      0,
-			MaxRetries: 3,
+			Delay:      5,
+			MaxRetries: 2,
 			Port:       30303,
-			Timeout:    3,
+			Timeout:    2,
 			Type:       LoadBalancerProtocolTCP,
 			URLPath:    "/",
 		},
@@ -101,7 +106,16 @@ func NewVpcSdkFake() (CloudVpcSdk, error) {
 		Name:               "tcp-80-30303",
 		Protocol:           LoadBalancerProtocolTCP,
 		ProvisioningStatus: LoadBalancerProvisioningStatusActive,
-		SessionPersistence: "None",
+		SessionPersistence: LoadBalancerSessionPersistenceNone,
+	}
+	securityGroup := &VpcSecurityGroup{
+		ID:   "securityGroupID",
+		Name: "securityGroup",
+		Rules: []*VpcSecurityGroupRule{
+			{Direction: SecurityGroupRuleDirectionInbound, ID: "inboundRule", PortMax: 80, PortMin: 80, Protocol: LoadBalancerProtocolTCP, Remote: SecurityGroupRuleRemoteAny},
+			{Direction: SecurityGroupRuleDirectionOutbound, ID: "outboundRule", Protocol: SecurityGroupRuleProtocolAll, Remote: SecurityGroupRuleRemoteAny},
+		},
+		VpcID: "vpcID",
 	}
 	subnet1 := &VpcSubnet{
 		AvailableIpv4AddressCount: 246,
@@ -125,9 +139,12 @@ func NewVpcSdkFake() (CloudVpcSdk, error) {
 		LoadBalancerReady:    lbReady,
 		LoadBalancerNotReady: lbNotReady,
 		Listener:             listener,
+		Listeners:            []*VpcLoadBalancerListener{listener},
 		Pool:                 pool,
+		Pools:                []*VpcLoadBalancerPool{pool},
 		Member1:              member1,
 		Member2:              member2,
+		SecurityGroup:        securityGroup,
 		Subnet1:              subnet1,
 		Subnet2:              subnet2,
 	}
@@ -145,18 +162,31 @@ func (c *CloudVpc) SetFakeSdkError(methodName string) {
 }
 
 // CreateLoadBalancer - create a load balancer
-func (v *VpcSdkFake) CreateLoadBalancer(lbName string, nodeList, poolList, subnetList []string, options *ServiceOptions) (*VpcLoadBalancer, error) {
+func (v *VpcSdkFake) CreateLoadBalancer(lbName string, nodeList, poolList, subnetList, securityGroupList []string, options *ServiceOptions) (*VpcLoadBalancer, error) {
 	if v.Error["CreateLoadBalancer"] != nil {
 		return nil, v.Error["CreateLoadBalancer"]
 	}
+	// Return a copy of the load balancer with the requested pools
+	lb := *v.LoadBalancerNotReady
 	if strings.HasSuffix(lbName, "-Ready") {
-		return v.LoadBalancerReady, nil
+		lb = *v.LoadBalancerReady
+	}
+	if options.isNLB() {
+		lb.ProfileFamily = LoadBalancerProfileFamilyNetwork
+	}
+	lb.Pools = []VpcObjectReference{}
+	for _, poolName := range poolList {
+		lb.Pools = append(lb.Pools, VpcObjectReference{Name: poolName})
+	}
+	lb.SecurityGroups = []VpcObjectReference{}
+	for _, securityGroup := range securityGroupList {
+		lb.SecurityGroups = append(lb.SecurityGroups, VpcObjectReference{ID: securityGroup})
 	}
-	return v.LoadBalancerNotReady, nil
+	return &lb, nil
 }
 
 // CreateLoadBalancerListener - create a load balancer listener
-func (v *VpcSdkFake) CreateLoadBalancerListener(lbID, poolName, poolID string) (*VpcLoadBalancerListener, error) {
+func (v *VpcSdkFake) CreateLoadBalancerListener(lbID, poolName, poolID string, options *ServiceOptions) (*VpcLoadBalancerListener, error) {
 	if v.Error["CreateLoadBalancerListener"] != nil {
 		return nil, v.Error["CreateLoadBalancerListener"]
 	}
@@ -172,13 +202,29 @@ func (v *VpcSdkFake) CreateLoadBalancerPool(lbID, poolName string, nodeList []st
 }
 
 // CreateLoadBalancerPoolMember - create a load balancer pool member
-func (v *VpcSdkFake) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string) (*VpcLoadBalancerPoolMember, error) {
+func (v *VpcSdkFake) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string, options *ServiceOptions) (*VpcLoadBalancerPoolMember, error) {
 	if v.Error["CreateLoadBalancerPoolMember"] != nil {
 		return nil, v.Error["CreateLoadBalancerPoolMember"]
 	}
 	return v.Member1, nil
 }
 
+// CreateSecurityGroup - create a security group
+func (v *VpcSdkFake) CreateSecurityGroup(sgName, vpcID string, rules []*VpcSecurityGroupRule) (*VpcSecurityGroup, error) {
+	if v.Error["CreateSecurityGroup"] != nil {
+		return nil, v.Error["CreateSecurityGroup"]
+	}
+	return &VpcSecurityGroup{ID: sgName + "-ID", Name: sgName, Rules: rules, VpcID: vpcID}, nil
+}
+
+// CreateSecurityGroupRule - create a security group rule
+func (v *VpcSdkFake) CreateSecurityGroupRule(sgID string, rule *VpcSecurityGroupRule) (*VpcSecurityGroupRule, error) {
+	if v.Error["CreateSecurityGroupRule"] != nil {
+		return nil, v.Error["CreateSecurityGroupRule"]
+	}
+	return rule, nil
+}
+
 // DeleteLoadBalancer - delete the specified VPC load balancer
 func (v *VpcSdkFake) DeleteLoadBalancer(lbID string) error {
 	return v.Error["DeleteLoadBalancer"]
@@ -199,6 +245,16 @@ func (v *VpcSdkFake) DeleteLoadBalancerPoolMember(lbID, poolID, memberID string)
 	return v.Error["DeleteLoadBalancerPoolMember"]
 }
 
+// DeleteSecurityGroup - delete the specified security group
+func (v *VpcSdkFake) DeleteSecurityGroup(sgID string) error {
+	return v.Error["DeleteSecurityGroup"]
+}
+
+// DeleteSecurityGroupRule - delete the specified security group rule
+func (v *VpcSdkFake) DeleteSecurityGroupRule(sgID, ruleID string) error {
+	return v.Error["DeleteSecurityGroupRule"]
+}
+
 // GetLoadBalancer - get a specific load balancer
 func (v *VpcSdkFake) GetLoadBalancer(lbID string) (*VpcLoadBalancer, error) {
 	if v.Error["GetLoadBalancer"] != nil {
@@ -234,7 +290,7 @@ func (v *VpcSdkFake) ListLoadBalancerListeners(lbID string) ([]*VpcLoadBalancerL
 	if v.Error["ListLoadBalancerListeners"] != nil {
 		return listeners, v.Error["ListLoadBalancerListeners"]
 	}
-	listeners = append(listeners, v.Listener)
+	listeners = append(listeners, v.Listeners...)
 	return listeners, nil
 }
 
@@ -244,7 +300,7 @@ func (v *VpcSdkFake) ListLoadBalancerPools(lbID string) ([]*VpcLoadBalancerPool,
 	if v.Error["ListLoadBalancerPools"] != nil {
 		return pools, v.Error["ListLoadBalancerPools"]
 	}
-	pools = append(pools, v.Pool)
+	pools = append(pools, v.Pools...)
 	return pools, nil
 }
 
@@ -258,6 +314,16 @@ func (v *VpcSdkFake) ListLoadBalancerPoolMembers(lbID, poolID string) ([]*VpcLoa
 	return members, nil
 }
 
+// ListSecurityGroups - return list of security groups
+func (v *VpcSdkFake) ListSecurityGroups(vpcID string) ([]*VpcSecurityGroup, error) {
+	securityGroups := []*VpcSecurityGroup{}
+	if v.Error["ListSecurityGroups"] != nil {
+		return securityGroups, v.Error["ListSecurityGroups"]
+	}
+	securityGroups = append(securityGroups, v.SecurityGroup)
+	return securityGroups, nil
+}
+
 // ListSubnets - return list of subnets
 func (v *VpcSdkFake) ListSubnets() ([]*VpcSubnet, error) {
 	subnets := []*VpcSubnet{}
@@ -269,7 +335,7 @@ func (v *VpcSdkFake) ListSubnets() ([]*VpcSubnet, error) {
 }
 
 // ReplaceLoadBalancerPoolMembers - update list of load balancer pool members
-func (v *VpcSdkFake) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string) ([]*VpcLoadBalancerPoolMember, error) {
+func (v *VpcSdkFake) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string, options *ServiceOptions) ([]*VpcLoadBalancerPoolMember, error) {
 	members := []*VpcLoadBalancerPoolMember{}
 	if v.Error["ReplaceLoadBalancerPoolMembers"] != nil {
 		return nil, v.Error["ReplaceLoadBalancerPoolMembers"]
@@ -278,6 +344,14 @@ func (v *VpcSdkFake) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID strin
 	return members, nil
 }
 
+// UpdateLoadBalancerListener - update a load balancer listener
+func (v *VpcSdkFake) UpdateLoadBalancerListener(lbID string, existingListener, updatedListener *VpcLoadBalancerListener) (*VpcLoadBalancerListener, error) {
+	if v.Error["UpdateLoadBalancerListener"] != nil {
+		return nil, v.Error["UpdateLoadBalancerListener"]
+	}
+	return v.Listener, nil
+}
+
 // UpdateLoadBalancerPool - update a load balancer pool
 func (v *VpcSdkFake) UpdateLoadBalancerPool(lbID, newPoolName string, existingPool *VpcLoadBalancerPool, options *ServiceOptions) (*VpcLoadBalancerPool, error) {
 	if v.Error["UpdateLoadBalancerPool"] != nil {
@@ -285,3 +359,11 @@ func (v *VpcSdkFake) UpdateLoadBalancerPool(lbID, newPoolName string, existingPo
 	}
 	return v.Pool, nil
 }
+
+// UpdateLoadBalancerPoolMember - update a load balancer pool member
+func (v *VpcSdkFake) UpdateLoadBalancerPoolMember(lbID, poolID, memberID string, weight int64) (*VpcLoadBalancerPoolMember, error) {
+	if v.Error["UpdateLoadBalancerPoolMember"] != nil {
+		return nil, v.Error["UpdateLoadBalancerPoolMember"]
+	}
+	return v.Member1, nil
+}
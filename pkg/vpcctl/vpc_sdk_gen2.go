@@ -21,6 +21,7 @@ package vpcctl
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"sort"
 	"strings"
@@ -101,7 +102,7 @@ func convertResourceGroupNameToID(c *ConfigVpc) error {
 }
 
 // CreateLoadBalancer - create a load balancer
-func (v *VpcSdkGen2) CreateLoadBalancer(lbName string, nodeList, poolList, subnetList []string, options *ServiceOptions) (*VpcLoadBalancer, error) {
+func (v *VpcSdkGen2) CreateLoadBalancer(lbName string, nodeList, poolList, subnetList, securityGroupList []string, options *ServiceOptions) (*VpcLoadBalancer, error) {
 	// For each of the ports in the Kubernetes service
 	listeners := []sdk.LoadBalancerListenerPrototypeLoadBalancerContext{}
 	pools := []sdk.LoadBalancerPoolPrototype{}
@@ -111,23 +112,28 @@ func (v *VpcSdkGen2) CreateLoadBalancer(lbName string, nodeList, poolList, subne
 			return nil, err
 		}
 		pool := sdk.LoadBalancerPoolPrototype{
-			Algorithm:     core.StringPtr(sdk.LoadBalancerPoolPrototypeAlgorithmRoundRobinConst),
-			HealthMonitor: v.genLoadBalancerHealthMonitor(poolNameFields.NodePort, options.getHealthCheckNodePort()),
-			Members:       v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList),
-			Name:          core.StringPtr(poolName),
-			Protocol:      core.StringPtr(poolNameFields.Protocol),
-			ProxyProtocol: core.StringPtr(sdk.LoadBalancerPoolProxyProtocolDisabledConst),
-		}
-		// Set proxy protocol if it was requested on the service annotation (we don't support v2)
-		if options.isProxyProtocol() {
-			pool.ProxyProtocol = core.StringPtr(sdk.LoadBalancerPoolProxyProtocolV1Const)
+			Algorithm:          core.StringPtr(options.getPoolAlgorithm()),
+			HealthMonitor:      v.genLoadBalancerHealthMonitor(poolNameFields, options),
+			Members:            v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList, options),
+			Name:               core.StringPtr(poolName),
+			Protocol:           core.StringPtr(options.getPoolProtocol(poolNameFields)),
+			ProxyProtocol:      core.StringPtr(options.getProxyProtocol()),
+			SessionPersistence: v.genLoadBalancerSessionPersistence(options),
 		}
 		pools = append(pools, pool)
 		listener := sdk.LoadBalancerListenerPrototypeLoadBalancerContext{
-			ConnectionLimit: core.Int64Ptr(15000),
-			DefaultPool:     &sdk.LoadBalancerPoolIdentityByName{Name: core.StringPtr(poolName)},
-			Port:            core.Int64Ptr(int64(poolNameFields.Port)),
-			Protocol:        core.StringPtr(sdk.LoadBalancerListenerPrototypeLoadBalancerContextProtocolTCPConst),
+			CertificateInstance:   v.genLoadBalancerCertificateInstance(poolNameFields, options),
+			ConnectionLimit:       core.Int64Ptr(options.getConnectionLimit()),
+			DefaultPool:           &sdk.LoadBalancerPoolIdentityByName{Name: core.StringPtr(poolName)},
+			IdleConnectionTimeout: v.genLoadBalancerIdleConnectionTimeout(options),
+			Protocol:              core.StringPtr(options.getListenerProtocol(poolNameFields)),
+		}
+		// Set the port range of the listener if one was requested on the service annotation
+		if portMin, portMax := options.getPortRange(poolNameFields.Port); portMin != portMax {
+			listener.PortMin = core.Int64Ptr(int64(portMin))
+			listener.PortMax = core.Int64Ptr(int64(portMax))
+		} else {
+			listener.Port = core.Int64Ptr(int64(poolNameFields.Port))
 		}
 		listeners = append(listeners, listener)
 	}
@@ -147,6 +153,14 @@ func (v *VpcSdkGen2) CreateLoadBalancer(lbName string, nodeList, poolList, subne
 		Pools:         pools,
 		ResourceGroup: &sdk.ResourceGroupIdentity{ID: core.StringPtr(v.Config.resourceGroupID)},
 	}
+	// Use the network load balancer profile if it was requested or if the service has UDP ports
+	if options.isNLB() {
+		createOptions.Profile = &sdk.LoadBalancerProfileIdentityByName{Name: core.StringPtr(LoadBalancerProfileNetworkFixed)}
+	}
+	// Attach the requested security groups. If none are specified, the default security group of the VPC is used
+	for _, securityGroup := range securityGroupList {
+		createOptions.SecurityGroups = append(createOptions.SecurityGroups, &sdk.SecurityGroupIdentity{ID: core.StringPtr(securityGroup)})
+	}
 
 	// Create the VPC LB
 	lb, response, err := v.Client.CreateLoadBalancer(createOptions)
@@ -160,7 +174,7 @@ func (v *VpcSdkGen2) CreateLoadBalancer(lbName string, nodeList, poolList, subne
 }
 
 // CreateLoadBalancerListener - create a load balancer listener
-func (v *VpcSdkGen2) CreateLoadBalancerListener(lbID, poolName, poolID string) (*VpcLoadBalancerListener, error) {
+func (v *VpcSdkGen2) CreateLoadBalancerListener(lbID, poolName, poolID string, options *ServiceOptions) (*VpcLoadBalancerListener, error) {
 	// Extract values from poolName
 	poolNameFields, err := extractFieldsFromPoolName(poolName)
 	if err != nil {
@@ -168,11 +182,19 @@ func (v *VpcSdkGen2) CreateLoadBalancerListener(lbID, poolName, poolID string) (
 	}
 	// Initialize the create options
 	createOptions := &sdk.CreateLoadBalancerListenerOptions{
-		LoadBalancerID:  core.StringPtr(lbID),
-		ConnectionLimit: core.Int64Ptr(15000),
-		Port:            core.Int64Ptr(int64(poolNameFields.Port)),
-		Protocol:        core.StringPtr(poolNameFields.Protocol),
-		DefaultPool:     &sdk.LoadBalancerPoolIdentity{ID: core.StringPtr(poolID)},
+		LoadBalancerID:        core.StringPtr(lbID),
+		CertificateInstance:   v.genLoadBalancerCertificateInstance(poolNameFields, options),
+		ConnectionLimit:       core.Int64Ptr(options.getConnectionLimit()),
+		IdleConnectionTimeout: v.genLoadBalancerIdleConnectionTimeout(options),
+		Protocol:              core.StringPtr(options.getListenerProtocol(poolNameFields)),
+		DefaultPool:           &sdk.LoadBalancerPoolIdentity{ID: core.StringPtr(poolID)},
+	}
+	// Set the port range of the listener if one was requested on the service annotation
+	if portMin, portMax := options.getPortRange(poolNameFields.Port); portMin != portMax {
+		createOptions.PortMin = core.Int64Ptr(int64(portMin))
+		createOptions.PortMax = core.Int64Ptr(int64(portMax))
+	} else {
+		createOptions.Port = core.Int64Ptr(int64(poolNameFields.Port))
 	}
 	// Create the VPC LB listener
 	listener, response, err := v.Client.CreateLoadBalancerListener(createOptions)
@@ -193,12 +215,14 @@ func (v *VpcSdkGen2) CreateLoadBalancerPool(lbID, poolName string, nodeList []st
 	}
 	// Initialize the create options
 	createOptions := &sdk.CreateLoadBalancerPoolOptions{
-		LoadBalancerID: core.StringPtr(lbID),
-		Algorithm:      core.StringPtr(sdk.CreateLoadBalancerPoolOptionsAlgorithmRoundRobinConst),
-		HealthMonitor:  v.genLoadBalancerHealthMonitor(poolNameFields.NodePort, options.getHealthCheckNodePort()),
-		Members:        v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList),
-		Name:           core.StringPtr(poolName),
-		Protocol:       core.StringPtr(poolNameFields.Protocol),
+		LoadBalancerID:     core.StringPtr(lbID),
+		Algorithm:          core.StringPtr(options.getPoolAlgorithm()),
+		HealthMonitor:      v.genLoadBalancerHealthMonitor(poolNameFields, options),
+		Members:            v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList, options),
+		Name:               core.StringPtr(poolName),
+		Protocol:           core.StringPtr(options.getPoolProtocol(poolNameFields)),
+		ProxyProtocol:      core.StringPtr(options.getProxyProtocol()),
+		SessionPersistence: v.genLoadBalancerSessionPersistence(options),
 	}
 	pool, response, err := v.Client.CreateLoadBalancerPool(createOptions)
 	if err != nil {
@@ -210,7 +234,7 @@ func (v *VpcSdkGen2) CreateLoadBalancerPool(lbID, poolName string, nodeList []st
 }
 
 // CreateLoadBalancerPoolMember - create a load balancer pool member
-func (v *VpcSdkGen2) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string) (*VpcLoadBalancerPoolMember, error) {
+func (v *VpcSdkGen2) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID string, options *ServiceOptions) (*VpcLoadBalancerPoolMember, error) {
 	// Extract values from poolName
 	poolNameFields, err := extractFieldsFromPoolName(poolName)
 	if err != nil {
@@ -221,7 +245,10 @@ func (v *VpcSdkGen2) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID
 		LoadBalancerID: core.StringPtr(lbID),
 		PoolID:         core.StringPtr(poolID),
 		Port:           core.Int64Ptr(int64(poolNameFields.NodePort)),
-		Target:         &sdk.LoadBalancerPoolMemberTargetPrototypeIP{Address: core.StringPtr(nodeID)},
+		Target:         v.genLoadBalancerMemberTarget(nodeID),
+	}
+	if weight, ok := options.getMemberWeight(nodeID); ok {
+		createOptions.Weight = core.Int64Ptr(weight)
 	}
 	// Create the VPC LB pool member
 	member, response, err := v.Client.CreateLoadBalancerPoolMember(createOptions)
@@ -233,6 +260,40 @@ func (v *VpcSdkGen2) CreateLoadBalancerPoolMember(lbID, poolName, poolID, nodeID
 	return v.mapLoadBalancerPoolMember(*member), nil
 }
 
+// CreateSecurityGroup - create a security group with the specified rules
+func (v *VpcSdkGen2) CreateSecurityGroup(sgName, vpcID string, rules []*VpcSecurityGroupRule) (*VpcSecurityGroup, error) {
+	createOptions := &sdk.CreateSecurityGroupOptions{
+		Name:          core.StringPtr(sgName),
+		ResourceGroup: &sdk.ResourceGroupIdentity{ID: core.StringPtr(v.Config.resourceGroupID)},
+		VPC:           &sdk.VPCIdentity{ID: core.StringPtr(vpcID)},
+	}
+	for _, rule := range rules {
+		createOptions.Rules = append(createOptions.Rules, v.genSecurityGroupRule(rule))
+	}
+	securityGroup, response, err := v.Client.CreateSecurityGroup(createOptions)
+	if err != nil {
+		v.logResponseError(response)
+		return nil, err
+	}
+	// Map the generated object back to the common format
+	return v.mapSecurityGroup(*securityGroup), nil
+}
+
+// CreateSecurityGroupRule - create a rule in the specified security group
+func (v *VpcSdkGen2) CreateSecurityGroupRule(sgID string, rule *VpcSecurityGroupRule) (*VpcSecurityGroupRule, error) {
+	createOptions := &sdk.CreateSecurityGroupRuleOptions{
+		SecurityGroupID:            core.StringPtr(sgID),
+		SecurityGroupRulePrototype: v.genSecurityGroupRule(rule),
+	}
+	result, response, err := v.Client.CreateSecurityGroupRule(createOptions)
+	if err != nil {
+		v.logResponseError(response)
+		return nil, err
+	}
+	// Map the generated object back to the common format
+	return v.mapSecurityGroupRule(result), nil
+}
+
 // DeleteLoadBalancer - delete the specified VPC load balancer
 func (v *VpcSdkGen2) DeleteLoadBalancer(lbID string) error {
 	response, err := v.Client.DeleteLoadBalancer(&sdk.DeleteLoadBalancerOptions{ID: &lbID})
@@ -269,64 +330,122 @@ func (v *VpcSdkGen2) DeleteLoadBalancerPoolMember(lbID, poolID, memberID string)
 	return err
 }
 
+// DeleteSecurityGroup - delete the specified security group
+func (v *VpcSdkGen2) DeleteSecurityGroup(sgID string) error {
+	response, err := v.Client.DeleteSecurityGroup(&sdk.DeleteSecurityGroupOptions{ID: &sgID})
+	if err != nil {
+		v.logResponseError(response)
+	}
+	return err
+}
+
+// DeleteSecurityGroupRule - delete the specified rule from the security group
+func (v *VpcSdkGen2) DeleteSecurityGroupRule(sgID, ruleID string) error {
+	response, err := v.Client.DeleteSecurityGroupRule(&sdk.DeleteSecurityGroupRuleOptions{SecurityGroupID: &sgID, ID: &ruleID})
+	if err != nil {
+		v.logResponseError(response)
+	}
+	return err
+}
+
 // genLoadBalancerHealthMonitor - generate the VPC health monitor template for load balancer
-func (v *VpcSdkGen2) genLoadBalancerHealthMonitor(nodePort, healthCheckPort int) *sdk.LoadBalancerPoolHealthMonitorPrototype {
-	// Define health monitor for load balancer.
-	//
-	// The Delay, MaxRetries, and Timeout values listed below are the default values that are selected when
-	// a load balancer is created in the VPC UI.  These values may need to be adjusted for IKS clusters.
+func (v *VpcSdkGen2) genLoadBalancerHealthMonitor(poolNameFields *VpcPoolNameFields, options *ServiceOptions) *sdk.LoadBalancerPoolHealthMonitorPrototype {
+	settings := options.getHealthMonitor(poolNameFields)
 	healthMonitor := &sdk.LoadBalancerPoolHealthMonitorPrototype{
-		Delay:      core.Int64Ptr(5),
-		MaxRetries: core.Int64Ptr(2),
-		Port:       core.Int64Ptr(int64(nodePort)),
-		Timeout:    core.Int64Ptr(2),
-		Type:       core.StringPtr(sdk.LoadBalancerPoolHealthMonitorPrototypeTypeTCPConst),
+		Delay:      core.Int64Ptr(settings.Delay),
+		MaxRetries: core.Int64Ptr(settings.MaxRetries),
+		Port:       core.Int64Ptr(settings.Port),
+		Timeout:    core.Int64Ptr(settings.Timeout),
+		Type:       core.StringPtr(settings.Type),
 	}
-
-	// If the service has: "externalTrafficPolicy: local", then set the health check to be HTTP
-	if healthCheckPort > 0 {
-		healthMonitor.Port = core.Int64Ptr(int64(healthCheckPort))
-		healthMonitor.Type = core.StringPtr(sdk.LoadBalancerPoolHealthMonitorPrototypeTypeHTTPConst)
-		healthMonitor.URLPath = core.StringPtr("/")
+	if settings.URLPath != "" {
+		healthMonitor.URLPath = core.StringPtr(settings.URLPath)
 	}
 	return healthMonitor
 }
 
 // genLoadBalancerHealthMonitorUpdate - generate the VPC health monitor update template for load balancer pool
-func (v *VpcSdkGen2) genLoadBalancerHealthMonitorUpdate(nodePort, healthCheckPort int) *sdk.LoadBalancerPoolHealthMonitorPatch {
-	// Define health monitor for load balancer.
-	//
-	// The Delay, MaxRetries, and Timeout values listed below are the default values that are selected when
-	// a load balancer is created in the VPC UI.  These values may need to be adjusted for IKS clusters.
+func (v *VpcSdkGen2) genLoadBalancerHealthMonitorUpdate(poolNameFields *VpcPoolNameFields, options *ServiceOptions) *sdk.LoadBalancerPoolHealthMonitorPatch {
+	settings := options.getHealthMonitor(poolNameFields)
 	healthMonitor := &sdk.LoadBalancerPoolHealthMonitorPatch{
-		Delay:      core.Int64Ptr(5),
-		MaxRetries: core.Int64Ptr(2),
-		Port:       core.Int64Ptr(int64(nodePort)),
-		Timeout:    core.Int64Ptr(2),
-		Type:       core.StringPtr(sdk.LoadBalancerPoolHealthMonitorPrototypeTypeTCPConst),
+		Delay:      core.Int64Ptr(settings.Delay),
+		MaxRetries: core.Int64Ptr(settings.MaxRetries),
+		Port:       core.Int64Ptr(settings.Port),
+		Timeout:    core.Int64Ptr(settings.Timeout),
+		Type:       core.StringPtr(settings.Type),
 	}
-
-	// If the service has: "externalTrafficPolicy: local", then set the health check to be HTTP
-	if healthCheckPort > 0 {
-		healthMonitor.Port = core.Int64Ptr(int64(healthCheckPort))
-		healthMonitor.Type = core.StringPtr(sdk.LoadBalancerPoolHealthMonitorPrototypeTypeHTTPConst)
-		healthMonitor.URLPath = core.StringPtr("/")
+	if settings.URLPath != "" {
+		healthMonitor.URLPath = core.StringPtr(settings.URLPath)
 	}
 	return healthMonitor
 }
 
+// genLoadBalancerCertificateInstance - generate the VPC certificate instance for the listener, nil unless the listener is HTTPS
+func (v *VpcSdkGen2) genLoadBalancerCertificateInstance(poolNameFields *VpcPoolNameFields, options *ServiceOptions) sdk.CertificateInstanceIdentityIntf {
+	if options.getListenerProtocol(poolNameFields) != LoadBalancerProtocolHTTPS {
+		return nil
+	}
+	return &sdk.CertificateInstanceIdentityByCRN{CRN: core.StringPtr(options.getCertificateCRN())}
+}
+
+// genLoadBalancerIdleConnectionTimeout - generate the VPC idle connection timeout for the listener, nil for network load balancers
+func (v *VpcSdkGen2) genLoadBalancerIdleConnectionTimeout(options *ServiceOptions) *int64 {
+	timeout := options.getIdleConnectionTimeout()
+	if timeout == 0 {
+		return nil
+	}
+	return core.Int64Ptr(timeout)
+}
+
+// genLoadBalancerSessionPersistence - generate the VPC session persistence template for load balancer pool
+func (v *VpcSdkGen2) genLoadBalancerSessionPersistence(options *ServiceOptions) *sdk.LoadBalancerPoolSessionPersistencePrototype {
+	sessionPersistence := options.getSessionPersistence()
+	if sessionPersistence == LoadBalancerSessionPersistenceNone {
+		return nil
+	}
+	return &sdk.LoadBalancerPoolSessionPersistencePrototype{Type: core.StringPtr(sessionPersistence)}
+}
+
+// genLoadBalancerMemberTarget - generate the VPC member target for the node. The node ID is either the IP address
+// of the node or the VPC instance ID of the node
+func (v *VpcSdkGen2) genLoadBalancerMemberTarget(nodeID string) sdk.LoadBalancerPoolMemberTargetPrototypeIntf {
+	if net.ParseIP(nodeID) == nil {
+		return &sdk.LoadBalancerPoolMemberTargetPrototypeInstanceIdentityInstanceIdentityByID{ID: core.StringPtr(nodeID)}
+	}
+	return &sdk.LoadBalancerPoolMemberTargetPrototypeIP{Address: core.StringPtr(nodeID)}
+}
+
 // genLoadBalancerMembers - generate the VPC member template for load balancer
-func (v *VpcSdkGen2) genLoadBalancerMembers(nodePort int, nodeList []string) []sdk.LoadBalancerPoolMemberPrototype {
+func (v *VpcSdkGen2) genLoadBalancerMembers(nodePort int, nodeList []string, options *ServiceOptions) []sdk.LoadBalancerPoolMemberPrototype {
 	// Create list of backend nodePorts on each of the nodes
 	members := []sdk.LoadBalancerPoolMemberPrototype{}
 	for _, node := range nodeList {
 		member := sdk.LoadBalancerPoolMemberPrototype{Port: core.Int64Ptr(int64(nodePort))}
-		member.Target = &sdk.LoadBalancerPoolMemberTargetPrototypeIP{Address: core.StringPtr(node)}
+		member.Target = v.genLoadBalancerMemberTarget(node)
+		if weight, ok := options.getMemberWeight(node); ok {
+			member.Weight = core.Int64Ptr(weight)
+		}
 		members = append(members, member)
 	}
 	return members
 }
 
+// genSecurityGroupRule - generate the VPC security group rule template
+func (v *VpcSdkGen2) genSecurityGroupRule(rule *VpcSecurityGroupRule) *sdk.SecurityGroupRulePrototype {
+	prototype := &sdk.SecurityGroupRulePrototype{
+		Direction: core.StringPtr(rule.Direction),
+		IPVersion: core.StringPtr(sdk.SecurityGroupRuleIPVersionIpv4Const),
+		Protocol:  core.StringPtr(rule.Protocol),
+		Remote:    &sdk.SecurityGroupRuleRemotePrototype{CIDRBlock: core.StringPtr(rule.Remote)},
+	}
+	// The port range is only set on TCP and UDP rules
+	if rule.PortMin > 0 {
+		prototype.PortMin = core.Int64Ptr(rule.PortMin)
+		prototype.PortMax = core.Int64Ptr(rule.PortMax)
+	}
+	return prototype
+}
+
 // GetLoadBalancer - get a specific load balancer
 func (v *VpcSdkGen2) GetLoadBalancer(lbID string) (*VpcLoadBalancer, error) {
 	lb, response, err := v.Client.GetLoadBalancer(&sdk.GetLoadBalancerOptions{ID: &lbID})
@@ -459,6 +578,34 @@ func (v *VpcSdkGen2) ListSubnets() ([]*VpcSubnet, error) {
 	return subnets, nil
 }
 
+// ListSecurityGroups - return list of security groups in the VPC
+func (v *VpcSdkGen2) ListSecurityGroups(vpcID string) ([]*VpcSecurityGroup, error) {
+	securityGroups := []*VpcSecurityGroup{}
+	var start *string
+	for {
+		list, response, err := v.Client.ListSecurityGroups(&sdk.ListSecurityGroupsOptions{Start: start, VPCID: &vpcID})
+		if err != nil {
+			v.logResponseError(response)
+			return securityGroups, err
+		}
+		for _, item := range list.SecurityGroups {
+			securityGroups = append(securityGroups, v.mapSecurityGroup(item))
+		}
+		// Check to see if more security groups need to be retrieved
+		if list.Next == nil || list.Next.Href == nil {
+			break
+		}
+		// We need to pull out the "start" query value and re-issue the call to RIaaS to get the next block of objects
+		u, err := url.Parse(*list.Next.Href)
+		if err != nil {
+			return securityGroups, err
+		}
+		qryArgs := u.Query()
+		start = core.StringPtr(qryArgs.Get("start"))
+	}
+	return securityGroups, nil
+}
+
 // logResponseError - write the response details to stdout so it will appear in logs
 func (v *VpcSdkGen2) logResponseError(response *core.DetailedResponse) {
 	if response != nil {
@@ -504,6 +651,10 @@ func (v *VpcSdkGen2) mapLoadBalancer(item sdk.LoadBalancer) *VpcLoadBalancer {
 	if item.ResourceGroup != nil {
 		lb.ResourceGroup = VpcObjectReference{ID: SafePointerString(item.ResourceGroup.ID), Name: SafePointerString(item.ResourceGroup.Name)}
 	}
+	// Security Groups
+	for _, securityGroupRef := range item.SecurityGroups {
+		lb.SecurityGroups = append(lb.SecurityGroups, VpcObjectReference{ID: SafePointerString(securityGroupRef.ID), Name: SafePointerString(securityGroupRef.Name)})
+	}
 	// Subnets
 	for _, subnetRef := range item.Subnets {
 		lb.Subnets = append(lb.Subnets, VpcObjectReference{ID: SafePointerString(subnetRef.ID), Name: SafePointerString(subnetRef.Name)})
@@ -514,15 +665,24 @@ func (v *VpcSdkGen2) mapLoadBalancer(item sdk.LoadBalancer) *VpcLoadBalancer {
 // mapLoadBalancerListener - map the LoadBalancerListener to generic format
 func (v *VpcSdkGen2) mapLoadBalancerListener(item sdk.LoadBalancerListener) *VpcLoadBalancerListener {
 	listener := &VpcLoadBalancerListener{
-		ConnectionLimit:    SafePointerInt64(item.ConnectionLimit),
-		ID:                 SafePointerString(item.ID),
-		Port:               SafePointerInt64(item.Port),
-		Protocol:           SafePointerString(item.Protocol),
-		ProvisioningStatus: SafePointerString(item.ProvisioningStatus),
+		ConnectionLimit:       SafePointerInt64(item.ConnectionLimit),
+		ID:                    SafePointerString(item.ID),
+		IdleConnectionTimeout: SafePointerInt64(item.IdleConnectionTimeout),
+		Port:                  SafePointerInt64(item.Port),
+		PortMax:               SafePointerInt64(item.PortMax),
+		PortMin:               SafePointerInt64(item.PortMin),
+		Protocol:              SafePointerString(item.Protocol),
+		ProvisioningStatus:    SafePointerString(item.ProvisioningStatus),
+	}
+	if item.CertificateInstance != nil {
+		listener.CertificateInstance = SafePointerString(item.CertificateInstance.CRN)
 	}
 	if item.DefaultPool != nil {
 		listener.DefaultPool = VpcObjectReference{ID: SafePointerString(item.DefaultPool.ID), Name: SafePointerString(item.DefaultPool.Name)}
 	}
+	if item.HTTPSRedirect != nil && item.HTTPSRedirect.Listener != nil {
+		listener.HTTPSRedirectListener = SafePointerString(item.HTTPSRedirect.Listener.ID)
+	}
 	return listener
 }
 
@@ -535,7 +695,7 @@ func (v *VpcSdkGen2) mapLoadBalancerPool(item sdk.LoadBalancerPool) *VpcLoadBala
 		Protocol:           SafePointerString(item.Protocol),
 		ProvisioningStatus: SafePointerString(item.ProvisioningStatus),
 		ProxyProtocol:      SafePointerString(item.ProxyProtocol),
-		SessionPersistence: "None",
+		SessionPersistence: LoadBalancerSessionPersistenceNone,
 	}
 	if item.HealthMonitor != nil {
 		pool.HealthMonitor = v.mapLoadBalancerPoolHealthMonitor(*item.HealthMonitor)
@@ -578,6 +738,59 @@ func (v *VpcSdkGen2) mapLoadBalancerPoolMember(item sdk.LoadBalancerPoolMember)
 	return member
 }
 
+// mapSecurityGroup - map the SecurityGroup to generic format
+func (v *VpcSdkGen2) mapSecurityGroup(item sdk.SecurityGroup) *VpcSecurityGroup {
+	securityGroup := &VpcSecurityGroup{
+		ID:    SafePointerString(item.ID),
+		Name:  SafePointerString(item.Name),
+		Rules: []*VpcSecurityGroupRule{},
+	}
+	for _, rule := range item.Rules {
+		securityGroup.Rules = append(securityGroup.Rules, v.mapSecurityGroupRule(rule))
+	}
+	if item.VPC != nil {
+		securityGroup.VpcID = SafePointerString(item.VPC.ID)
+	}
+	return securityGroup
+}
+
+// mapSecurityGroupRule - map the SecurityGroupRule to generic format
+func (v *VpcSdkGen2) mapSecurityGroupRule(item sdk.SecurityGroupRuleIntf) *VpcSecurityGroupRule {
+	rule := &VpcSecurityGroupRule{}
+	var remote sdk.SecurityGroupRuleRemoteIntf
+	switch item := item.(type) {
+	case *sdk.SecurityGroupRuleSecurityGroupRuleProtocolAll:
+		rule.Direction = SafePointerString(item.Direction)
+		rule.ID = SafePointerString(item.ID)
+		rule.Protocol = SafePointerString(item.Protocol)
+		remote = item.Remote
+	case *sdk.SecurityGroupRuleSecurityGroupRuleProtocolIcmp:
+		rule.Direction = SafePointerString(item.Direction)
+		rule.ID = SafePointerString(item.ID)
+		rule.Protocol = SafePointerString(item.Protocol)
+		remote = item.Remote
+	case *sdk.SecurityGroupRuleSecurityGroupRuleProtocolTcpudp:
+		rule.Direction = SafePointerString(item.Direction)
+		rule.ID = SafePointerString(item.ID)
+		rule.PortMax = SafePointerInt64(item.PortMax)
+		rule.PortMin = SafePointerInt64(item.PortMin)
+		rule.Protocol = SafePointerString(item.Protocol)
+		remote = item.Remote
+	}
+	// The remote of the rule is either a CIDR block, an IP address, or a security group
+	if remote, ok := remote.(*sdk.SecurityGroupRuleRemote); ok {
+		switch {
+		case remote.CIDRBlock != nil:
+			rule.Remote = *remote.CIDRBlock
+		case remote.Address != nil:
+			rule.Remote = *remote.Address
+		default:
+			rule.Remote = SafePointerString(remote.ID)
+		}
+	}
+	return rule
+}
+
 // mapSubnet - map the Subnet to generic format
 func (v *VpcSdkGen2) mapSubnet(item sdk.Subnet) *VpcSubnet {
 	subnet := &VpcSubnet{
@@ -615,7 +828,7 @@ func (v *VpcSdkGen2) mapSubnet(item sdk.Subnet) *VpcSubnet {
 }
 
 // ReplaceLoadBalancerPoolMembers - update a load balancer pool members
-func (v *VpcSdkGen2) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string) ([]*VpcLoadBalancerPoolMember, error) {
+func (v *VpcSdkGen2) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID string, nodeList []string, options *ServiceOptions) ([]*VpcLoadBalancerPoolMember, error) {
 	// Extract values from poolName
 	poolNameFields, err := extractFieldsFromPoolName(poolName)
 	if err != nil {
@@ -625,7 +838,7 @@ func (v *VpcSdkGen2) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID strin
 	replaceOptions := &sdk.ReplaceLoadBalancerPoolMembersOptions{
 		LoadBalancerID: core.StringPtr(lbID),
 		PoolID:         core.StringPtr(poolID),
-		Members:        v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList),
+		Members:        v.genLoadBalancerMembers(poolNameFields.NodePort, nodeList, options),
 	}
 	// Update the VPC LB pool member
 	list, response, err := v.Client.ReplaceLoadBalancerPoolMembers(replaceOptions)
@@ -641,6 +854,47 @@ func (v *VpcSdkGen2) ReplaceLoadBalancerPoolMembers(lbID, poolName, poolID strin
 	return members, nil
 }
 
+// UpdateLoadBalancerListener - update the connection, certificate, and redirect settings of a load balancer listener
+func (v *VpcSdkGen2) UpdateLoadBalancerListener(lbID string, existingListener, updatedListener *VpcLoadBalancerListener) (*VpcLoadBalancerListener, error) {
+	updateListener := &sdk.LoadBalancerListenerPatch{
+		ConnectionLimit: core.Int64Ptr(updatedListener.ConnectionLimit),
+	}
+	if updatedListener.IdleConnectionTimeout > 0 {
+		updateListener.IdleConnectionTimeout = core.Int64Ptr(updatedListener.IdleConnectionTimeout)
+	}
+	if updatedListener.CertificateInstance != "" {
+		updateListener.CertificateInstance = &sdk.CertificateInstanceIdentityByCRN{CRN: core.StringPtr(updatedListener.CertificateInstance)}
+	}
+	if updatedListener.HTTPSRedirectListener != "" {
+		updateListener.HTTPSRedirect = &sdk.LoadBalancerListenerHTTPSRedirectPatch{
+			HTTPStatusCode: core.Int64Ptr(LoadBalancerHTTPSRedirectStatusCode),
+			Listener:       &sdk.LoadBalancerListenerIdentityByID{ID: core.StringPtr(updatedListener.HTTPSRedirectListener)},
+		}
+	}
+	updatePatch, err := updateListener.AsPatch()
+	if err != nil {
+		return nil, err
+	}
+	// The redirect is removed from the listener by patching it to null
+	if updatedListener.HTTPSRedirectListener == "" && existingListener.HTTPSRedirectListener != "" {
+		updatePatch["https_redirect"] = nil
+	}
+	// Initialize the update listener options
+	updateOptions := &sdk.UpdateLoadBalancerListenerOptions{
+		LoadBalancerID:            core.StringPtr(lbID),
+		ID:                        core.StringPtr(existingListener.ID),
+		LoadBalancerListenerPatch: updatePatch,
+	}
+	// Update the VPC LB listener
+	listener, response, err := v.Client.UpdateLoadBalancerListener(updateOptions)
+	if err != nil {
+		v.logResponseError(response)
+		return nil, err
+	}
+	// Map the generated object back to the common format
+	return v.mapLoadBalancerListener(*listener), nil
+}
+
 // UpdateLoadBalancerPool - update a load balancer pool
 func (v *VpcSdkGen2) UpdateLoadBalancerPool(lbID, newPoolName string, existingPool *VpcLoadBalancerPool, options *ServiceOptions) (*VpcLoadBalancerPool, error) {
 	// Extract values from poolName
@@ -648,22 +902,30 @@ func (v *VpcSdkGen2) UpdateLoadBalancerPool(lbID, newPoolName string, existingPo
 	if err != nil {
 		return nil, err
 	}
-	proxyProtocolRequested := options.isProxyProtocol()
+	proxyProtocol := options.getProxyProtocol()
+	sessionPersistence := options.getSessionPersistence()
 	updatePool := &sdk.LoadBalancerPoolPatch{
-		// Algorithm:  core.StringPtr(algorithm),
-		HealthMonitor: v.genLoadBalancerHealthMonitorUpdate(poolNameFields.NodePort, options.getHealthCheckNodePort()),
+		Algorithm:     core.StringPtr(options.getPoolAlgorithm()),
+		HealthMonitor: v.genLoadBalancerHealthMonitorUpdate(poolNameFields, options),
 		Name:          core.StringPtr(newPoolName),
 	}
-	if proxyProtocolRequested && existingPool.ProxyProtocol != sdk.LoadBalancerPoolProxyProtocolV1Const {
-		updatePool.ProxyProtocol = core.StringPtr(sdk.LoadBalancerPoolProxyProtocolV1Const)
+	if sessionPersistence != LoadBalancerSessionPersistenceNone {
+		updatePool.SessionPersistence = &sdk.LoadBalancerPoolSessionPersistencePatch{Type: core.StringPtr(sessionPersistence)}
+	}
+	if existingPool.ProxyProtocol != proxyProtocol {
+		updatePool.ProxyProtocol = core.StringPtr(proxyProtocol)
 	}
-	if !proxyProtocolRequested && existingPool.ProxyProtocol != sdk.LoadBalancerPoolProxyProtocolDisabledConst {
-		updatePool.ProxyProtocol = core.StringPtr(sdk.LoadBalancerPoolProxyProtocolDisabledConst)
+	if poolProtocol := options.getPoolProtocol(poolNameFields); existingPool.Protocol != poolProtocol {
+		updatePool.Protocol = core.StringPtr(poolProtocol)
 	}
 	updatePatch, err := updatePool.AsPatch()
 	if err != nil {
 		return nil, err
 	}
+	// Session persistence is removed from the pool by patching it to null
+	if sessionPersistence == LoadBalancerSessionPersistenceNone && existingPool.SessionPersistence != LoadBalancerSessionPersistenceNone {
+		updatePatch["session_persistence"] = nil
+	}
 	// Initialize the update pool options
 	updateOptions := &sdk.UpdateLoadBalancerPoolOptions{
 		LoadBalancerID:        core.StringPtr(lbID),
@@ -680,3 +942,29 @@ func (v *VpcSdkGen2) UpdateLoadBalancerPool(lbID, newPoolName string, existingPo
 	// Map the generated object back to the common format
 	return v.mapLoadBalancerPool(*pool), nil
 }
+
+// UpdateLoadBalancerPoolMember - update the weight of a load balancer pool member
+func (v *VpcSdkGen2) UpdateLoadBalancerPoolMember(lbID, poolID, memberID string, weight int64) (*VpcLoadBalancerPoolMember, error) {
+	updateMember := &sdk.LoadBalancerPoolMemberPatch{
+		Weight: core.Int64Ptr(weight),
+	}
+	updatePatch, err := updateMember.AsPatch()
+	if err != nil {
+		return nil, err
+	}
+	// Initialize the update pool member options
+	updateOptions := &sdk.UpdateLoadBalancerPoolMemberOptions{
+		LoadBalancerID:              core.StringPtr(lbID),
+		PoolID:                      core.StringPtr(poolID),
+		ID:                          core.StringPtr(memberID),
+		LoadBalancerPoolMemberPatch: updatePatch,
+	}
+	// Update the VPC LB pool member
+	member, response, err := v.Client.UpdateLoadBalancerPoolMember(updateOptions)
+	if err != nil {
+		v.logResponseError(response)
+		return nil, err
+	}
+	// Map the generated object back to the common format
+	return v.mapLoadBalancerPoolMember(*member), nil
+}
@@ -21,6 +21,7 @@ package vpcctl
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -53,7 +54,7 @@ func TestVpcSdkGen2_CreateLoadBalancer(t *testing.T) {
 	// Invalid pool name
 	options := newServiceOptions()
 	options.healthCheckNodePort = 36963
-	lb, err := v.CreateLoadBalancer("lbName", []string{"192.168.1.1"}, []string{"poolName"}, []string{"subnetID"}, options)
+	lb, err := v.CreateLoadBalancer("lbName", []string{"192.168.1.1"}, []string{"poolName"}, []string{"subnetID"}, []string{}, options)
 	assert.Nil(t, lb)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Invalid pool name,")
@@ -62,16 +63,25 @@ func TestVpcSdkGen2_CreateLoadBalancer(t *testing.T) {
 	nodes := []string{"192.168.1.1"}
 	pools := []string{"tcp-80-30303"}
 	subnets := []string{"subnetID"}
-	lb, err = v.CreateLoadBalancer("lbName", nodes, pools, subnets, options)
+	lb, err = v.CreateLoadBalancer("lbName", nodes, pools, subnets, []string{"securityGroupID"}, options)
+	assert.NotNil(t, lb)
+	assert.Nil(t, err)
+
+	// Success, UDP network load balancer
+	options.udpPorts = true
+	lb, err = v.CreateLoadBalancer("lbName", nodes, []string{"udp-53-30053"}, subnets, []string{}, options)
 	assert.NotNil(t, lb)
 	assert.Nil(t, err)
 }
 
 func TestVpcSdkGen2_CreateLoadBalancerListener(t *testing.T) {
+	var postBody string
 	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		postBody = string(body)
 		res.Header().Set("Content-type", "application/json")
 		res.WriteHeader(201)
-		fmt.Fprintf(res, `{"certificate_instance": {"crn": "crn:v1:bluemix:public:cloudcerts:us-south:a/123456:b8866ea4-b8df-467e-801a-da1db7e020bf:certificate:78ff9c4c97d013fb2a95b21dddde7758"}, "connection_limit": 2000, "created_at": "2019-01-01T12:00:00", "default_pool": {"href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "name": "my-load-balancer-pool"}, "href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/listeners/70294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "policies": [{"href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/listeners/70294e14-4e61-11e8-bcf4-0242ac110004/policies/f3187486-7b27-4c79-990c-47d33c0e2278", "id": "70294e14-4e61-11e8-bcf4-0242ac110004"}], "port": 443, "protocol": "http", "provisioning_status": "active"}`)
+		fmt.Fprintf(res, `{"certificate_instance": {"crn": "crn:v1:bluemix:public:cloudcerts:us-south:a/123456:b8866ea4-b8df-467e-801a-da1db7e020bf:certificate:78ff9c4c97d013fb2a95b21dddde7758"}, "connection_limit": 2000, "created_at": "2019-01-01T12:00:00", "default_pool": {"href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "name": "my-load-balancer-pool"}, "href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/listeners/70294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "idle_connection_timeout": 300, "policies": [{"href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/listeners/70294e14-4e61-11e8-bcf4-0242ac110004/policies/f3187486-7b27-4c79-990c-47d33c0e2278", "id": "70294e14-4e61-11e8-bcf4-0242ac110004"}], "port": 443, "port_max": 443, "port_min": 443, "protocol": "http", "provisioning_status": "active"}`)
 	}))
 	defer server.Close()
 
@@ -79,15 +89,30 @@ func TestVpcSdkGen2_CreateLoadBalancerListener(t *testing.T) {
 	v := newNoAuthTestVpcSdkGen2(server.URL)
 
 	// Invalid pool name
-	listener, err := v.CreateLoadBalancerListener("lbID", "poolName", "poolID")
+	options := newServiceOptions()
+	listener, err := v.CreateLoadBalancerListener("lbID", "poolName", "poolID", options)
 	assert.Nil(t, listener)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Invalid pool name")
 
 	// Success
-	listener, err = v.CreateLoadBalancerListener("lbID", "tcp-80-30123", "poolID")
+	listener, err = v.CreateLoadBalancerListener("lbID", "tcp-80-30123", "poolID", options)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.Equal(t, listener.ConnectionLimit, int64(2000))
+	assert.Equal(t, listener.IdleConnectionTimeout, int64(300))
+	assert.Equal(t, listener.PortMax, int64(443))
+	assert.Equal(t, listener.PortMin, int64(443))
+	assert.Contains(t, postBody, `"port":80`)
+
+	// Success, listener is created for a port range
+	options.annotations[serviceAnnotationPortRange] = "30000-30002"
+	listener, err = v.CreateLoadBalancerListener("lbID", "tcp-30000-30000", "poolID", options)
 	assert.NotNil(t, listener)
 	assert.Nil(t, err)
+	assert.Contains(t, postBody, `"port_max":30002`)
+	assert.Contains(t, postBody, `"port_min":30000`)
+	assert.NotContains(t, postBody, `"port":`)
 }
 
 func TestVpcSdkGen2_CreateLoadBalancerPool(t *testing.T) {
@@ -113,10 +138,22 @@ func TestVpcSdkGen2_CreateLoadBalancerPool(t *testing.T) {
 	pool, err = v.CreateLoadBalancerPool("lbID", "tcp-80-30123", nodes, options)
 	assert.NotNil(t, pool)
 	assert.Nil(t, err)
+
+	// Success, session persistence requested
+	options.annotations[serviceAnnotationSessionPersistence] = LoadBalancerSessionPersistenceSourceIP
+	pool, err = v.CreateLoadBalancerPool("lbID", "tcp-80-30123", nodes, options)
+	assert.NotNil(t, pool)
+	assert.Nil(t, err)
+	assert.Equal(t, pool.SessionPersistence, LoadBalancerSessionPersistenceSourceIP)
 }
 
 func TestVpcSdkGen2_CreateLoadBalancerPoolMember(t *testing.T) {
+	postBody := ""
 	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			body, _ := io.ReadAll(req.Body)
+			postBody = string(body)
+		}
 		res.Header().Set("Content-type", "application/json")
 		res.WriteHeader(201)
 		fmt.Fprintf(res, `{"created_at": "2019-01-01T12:00:00", "health": "faulted", "href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004/members/80294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "port": 80, "provisioning_status": "active", "target": {"address": "192.168.100.5"}, "weight": 50}`)
@@ -127,15 +164,24 @@ func TestVpcSdkGen2_CreateLoadBalancerPoolMember(t *testing.T) {
 	v := newNoAuthTestVpcSdkGen2(server.URL)
 
 	// Invalid pool name
-	member, err := v.CreateLoadBalancerPoolMember("lbID", "poolName", "poolID", "192.168.1.1")
+	options := newServiceOptions()
+	member, err := v.CreateLoadBalancerPoolMember("lbID", "poolName", "poolID", "192.168.1.1", options)
 	assert.Nil(t, member)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Invalid pool name")
 
 	// Success
-	member, err = v.CreateLoadBalancerPoolMember("lbID", "tcp-80-30123", "poolID", "192.168.1.1")
+	member, err = v.CreateLoadBalancerPoolMember("lbID", "tcp-80-30123", "poolID", "192.168.1.1", options)
+	assert.NotNil(t, member)
+	assert.Nil(t, err)
+	assert.NotContains(t, postBody, `"weight"`)
+
+	// Success, member weight set from the node
+	options.memberWeights = map[string]int64{"192.168.1.1": 8}
+	member, err = v.CreateLoadBalancerPoolMember("lbID", "tcp-80-30123", "poolID", "192.168.1.1", options)
 	assert.NotNil(t, member)
 	assert.Nil(t, err)
+	assert.Contains(t, postBody, `"weight":8`)
 }
 
 func TestVpcSdkGen2_DeleteLoadBalancer(t *testing.T) {
@@ -377,19 +423,109 @@ func TestVpcSdkGen2_ReplaceLoadBalancerPoolMembers(t *testing.T) {
 
 	// Invalid pool name
 	nodes := []string{"192.168.1.1"}
-	members, err := v.ReplaceLoadBalancerPoolMembers("lbID", "poolName", "poolID", nodes)
+	options := newServiceOptions()
+	members, err := v.ReplaceLoadBalancerPoolMembers("lbID", "poolName", "poolID", nodes, options)
 	assert.Nil(t, members)
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "Invalid pool name")
 
 	// Success
-	members, err = v.ReplaceLoadBalancerPoolMembers("lbID", "tcp-80-30123", "poolID", nodes)
+	members, err = v.ReplaceLoadBalancerPoolMembers("lbID", "tcp-80-30123", "poolID", nodes, options)
 	assert.NotNil(t, members)
 	assert.Nil(t, err)
+
+	// Success, instance targets
+	members, err = v.ReplaceLoadBalancerPoolMembers("lbID", "tcp-80-30123", "poolID", []string{"instance-1"}, options)
+	assert.NotNil(t, members)
+	assert.Nil(t, err)
+}
+
+func TestVpcSdkGen2_GenLoadBalancerMembers(t *testing.T) {
+	v := &VpcSdkGen2{}
+	options := &ServiceOptions{memberWeights: map[string]int64{"192.168.1.1": 4}}
+	members := v.genLoadBalancerMembers(30123, []string{"192.168.1.1", "192.168.2.2"}, options)
+	assert.Equal(t, len(members), 2)
+	assert.Equal(t, *members[0].Port, int64(30123))
+	assert.Equal(t, *members[0].Weight, int64(4))
+	assert.Nil(t, members[1].Weight)
+}
+
+func TestVpcSdkGen2_GenLoadBalancerMemberTarget(t *testing.T) {
+	v := &VpcSdkGen2{}
+	target := v.genLoadBalancerMemberTarget("192.168.1.1")
+	ipTarget, ok := target.(*sdk.LoadBalancerPoolMemberTargetPrototypeIP)
+	assert.True(t, ok)
+	assert.Equal(t, *ipTarget.Address, "192.168.1.1")
+
+	target = v.genLoadBalancerMemberTarget("0717_1e09281b-f177-46fb-baf1-bc152b2e391a")
+	instanceTarget, ok := target.(*sdk.LoadBalancerPoolMemberTargetPrototypeInstanceIdentityInstanceIdentityByID)
+	assert.True(t, ok)
+	assert.Equal(t, *instanceTarget.ID, "0717_1e09281b-f177-46fb-baf1-bc152b2e391a")
+}
+
+func TestVpcSdkGen2_UpdateLoadBalancerListener(t *testing.T) {
+	patchBody := ""
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		patchBody = string(body)
+		res.Header().Set("Content-type", "application/json")
+		res.WriteHeader(200)
+		fmt.Fprintf(res, `{"connection_limit": 2000, "created_at": "2019-01-01T12:00:00", "default_pool": {"id": "70294e14-4e61-11e8-bcf4-0242ac110004", "name": "tcp-80-30123"}, "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "idle_connection_timeout": 300, "port": 80, "protocol": "tcp", "provisioning_status": "active"}`)
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success, application load balancer
+	existingListener := &VpcLoadBalancerListener{ID: "listenerID", Protocol: LoadBalancerProtocolTCP}
+	updatedListener := &VpcLoadBalancerListener{ID: "listenerID", ConnectionLimit: 2000, IdleConnectionTimeout: 300, Protocol: LoadBalancerProtocolTCP}
+	listener, err := v.UpdateLoadBalancerListener("lbID", existingListener, updatedListener)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.Equal(t, listener.ConnectionLimit, int64(2000))
+	assert.Equal(t, listener.IdleConnectionTimeout, int64(300))
+	assert.Contains(t, patchBody, `"connection_limit":2000`)
+	assert.Contains(t, patchBody, `"idle_connection_timeout":300`)
+
+	// Success, network load balancer does not set the idle connection timeout
+	updatedListener.IdleConnectionTimeout = 0
+	listener, err = v.UpdateLoadBalancerListener("lbID", existingListener, updatedListener)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.NotContains(t, patchBody, "idle_connection_timeout")
+
+	// Success, HTTPS listener certificate updated
+	updatedListener.CertificateInstance = "crn:v1:bluemix:public:secrets-manager:us-south:a/123456:instance::secret"
+	listener, err = v.UpdateLoadBalancerListener("lbID", existingListener, updatedListener)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.Contains(t, patchBody, `"certificate_instance":{"crn":"crn:v1:bluemix:public:secrets-manager:us-south:a/123456:instance::secret"}`)
+
+	// Success, HTTP listener redirected to the HTTPS listener
+	updatedListener.CertificateInstance = ""
+	updatedListener.HTTPSRedirectListener = "httpsListenerID"
+	listener, err = v.UpdateLoadBalancerListener("lbID", existingListener, updatedListener)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.Contains(t, patchBody, `"https_redirect":{"http_status_code":301,"listener":{"id":"httpsListenerID"}}`)
+
+	// Success, redirect removed from the HTTP listener
+	existingListener.HTTPSRedirectListener = "httpsListenerID"
+	updatedListener.HTTPSRedirectListener = ""
+	listener, err = v.UpdateLoadBalancerListener("lbID", existingListener, updatedListener)
+	assert.NotNil(t, listener)
+	assert.Nil(t, err)
+	assert.Contains(t, patchBody, `"https_redirect":null`)
 }
 
 func TestVpcSdkGen2_UpdateLoadBalancerPool(t *testing.T) {
+	patchBody := ""
 	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPatch {
+			body, _ := io.ReadAll(req.Body)
+			patchBody = string(body)
+		}
 		res.Header().Set("Content-type", "application/json")
 		res.WriteHeader(200)
 		fmt.Fprintf(res, `{"algorithm": "least_connections", "created_at": "2019-01-01T12:00:00", "health_monitor": {"delay": 5, "max_retries": 2, "port": 22, "timeout": 2, "type": "http", "url_path": "/"}, "href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "members": [{"href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004/members/80294e14-4e61-11e8-bcf4-0242ac110004", "id": "70294e14-4e61-11e8-bcf4-0242ac110004", "port": 80, "target": {"address": "192.168.100.5"}, "weight": 50}], "name": "my-load-balancer-pool", "protocol": "http", "provisioning_status": "active", "session_persistence": {"type": "source_ip"}}`)
@@ -410,4 +546,184 @@ func TestVpcSdkGen2_UpdateLoadBalancerPool(t *testing.T) {
 	members, err = v.UpdateLoadBalancerPool("lbID", "tcp-80-30123", &VpcLoadBalancerPool{ID: "poolID"}, options)
 	assert.NotNil(t, members)
 	assert.Nil(t, err)
+
+	// Success, session persistence removed from the pool
+	members, err = v.UpdateLoadBalancerPool("lbID", "tcp-80-30123", &VpcLoadBalancerPool{ID: "poolID", SessionPersistence: LoadBalancerSessionPersistenceSourceIP}, options)
+	assert.NotNil(t, members)
+	assert.Nil(t, err)
+	assert.Contains(t, patchBody, `"session_persistence":null`)
+
+	// Success, PROXY protocol v2 set on the pool
+	options.enabledFeatures = LoadBalancerOptionProxyProtocolV2
+	members, err = v.UpdateLoadBalancerPool("lbID", "tcp-80-30123", &VpcLoadBalancerPool{ID: "poolID", ProxyProtocol: LoadBalancerProxyProtocolV1}, options)
+	assert.NotNil(t, members)
+	assert.Nil(t, err)
+	assert.Contains(t, patchBody, `"proxy_protocol":"v2"`)
+}
+
+func TestVpcSdkGen2_UpdateLoadBalancerPoolMember(t *testing.T) {
+	patchBody := ""
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPatch {
+			body, _ := io.ReadAll(req.Body)
+			patchBody = string(body)
+		}
+		res.Header().Set("Content-type", "application/json")
+		res.WriteHeader(200)
+		fmt.Fprintf(res, `{"created_at": "2019-01-01T12:00:00", "health": "ok", "href": "https://us-south.iaas.cloud.ibm.com/v1/load_balancers/dd754295-e9e0-4c9d-bf6c-58fbc59e5727/pools/70294e14-4e61-11e8-bcf4-0242ac110004/members/80294e14-4e61-11e8-bcf4-0242ac110004", "id": "80294e14-4e61-11e8-bcf4-0242ac110004", "port": 30123, "provisioning_status": "active", "target": {"address": "192.168.100.5"}, "weight": 16}`)
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	member, err := v.UpdateLoadBalancerPoolMember("lbID", "poolID", "memberID", 16)
+	assert.NotNil(t, member)
+	assert.Nil(t, err)
+	assert.Equal(t, member.Weight, int64(16))
+	assert.Contains(t, patchBody, `"weight":16`)
+}
+
+const testSecurityGroupRuleJSON = `{"direction": "inbound", "href": "https://us-south.iaas.cloud.ibm.com/v1/security_groups/be5df5ca-12a0-494b-907e-aa6ec2bfa271/rules/6f2a6efe-21e2-401c-b237-620aa26ba16a", "id": "6f2a6efe-21e2-401c-b237-620aa26ba16a", "ip_version": "ipv4", "local": {"cidr_block": "0.0.0.0/0"}, "port_max": 443, "port_min": 443, "protocol": "tcp", "remote": {"cidr_block": "192.168.3.0/24"}}`
+
+const testSecurityGroupRulesJSON = `[` + testSecurityGroupRuleJSON + `, {"direction": "outbound", "href": "https://us-south.iaas.cloud.ibm.com/v1/security_groups/be5df5ca-12a0-494b-907e-aa6ec2bfa271/rules/b597cff2-38e8-4e6e-999d-000002172691", "id": "b597cff2-38e8-4e6e-999d-000002172691", "ip_version": "ipv4", "local": {"cidr_block": "0.0.0.0/0"}, "protocol": "all", "remote": {"cidr_block": "0.0.0.0/0"}}]`
+
+const testSecurityGroupJSON = `{"created_at": "2019-01-01T12:00:00", "crn": "crn:v1:bluemix:public:is:us-south:a/123456::security-group:be5df5ca-12a0-494b-907e-aa6ec2bfa271", "href": "https://us-south.iaas.cloud.ibm.com/v1/security_groups/be5df5ca-12a0-494b-907e-aa6ec2bfa271", "id": "be5df5ca-12a0-494b-907e-aa6ec2bfa271", "name": "my-security-group", "resource_group": {"href": "https://resource-controller.cloud.ibm.com/v2/resource_groups/fee82deba12e4c0fb69c3b09d1f12345", "id": "fee82deba12e4c0fb69c3b09d1f12345", "name": "my-resource-group"}, "rules": ` + testSecurityGroupRulesJSON + `, "targets": [], "vpc": {"crn": "crn:v1:bluemix:public:is:us-south:a/123456::vpc:4727d842-f94f-4a2d-824a-9bc9b02c523b", "href": "https://us-south.iaas.cloud.ibm.com/v1/vpcs/4727d842-f94f-4a2d-824a-9bc9b02c523b", "id": "4727d842-f94f-4a2d-824a-9bc9b02c523b", "name": "my-vpc"}}`
+
+func TestVpcSdkGen2_CreateSecurityGroup(t *testing.T) {
+	var postBody string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		postBody = string(body)
+		res.Header().Set("Content-type", "application/json")
+		res.WriteHeader(201)
+		fmt.Fprint(res, testSecurityGroupJSON)
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	rules := []*VpcSecurityGroupRule{
+		{Direction: SecurityGroupRuleDirectionInbound, PortMax: 443, PortMin: 443, Protocol: LoadBalancerProtocolTCP, Remote: "192.168.3.0/24"},
+		{Direction: SecurityGroupRuleDirectionOutbound, Protocol: SecurityGroupRuleProtocolAll, Remote: SecurityGroupRuleRemoteAny},
+	}
+	securityGroup, err := v.CreateSecurityGroup("my-security-group", "vpcID", rules)
+	assert.Nil(t, err)
+	assert.NotNil(t, securityGroup)
+	assert.Equal(t, securityGroup.Name, "my-security-group")
+	assert.Equal(t, securityGroup.VpcID, "4727d842-f94f-4a2d-824a-9bc9b02c523b")
+	assert.Equal(t, len(securityGroup.Rules), 2)
+	assert.Equal(t, *securityGroup.Rules[0], VpcSecurityGroupRule{Direction: SecurityGroupRuleDirectionInbound, ID: "6f2a6efe-21e2-401c-b237-620aa26ba16a",
+		PortMax: 443, PortMin: 443, Protocol: LoadBalancerProtocolTCP, Remote: "192.168.3.0/24"})
+	assert.Equal(t, *securityGroup.Rules[1], VpcSecurityGroupRule{Direction: SecurityGroupRuleDirectionOutbound, ID: "b597cff2-38e8-4e6e-999d-000002172691",
+		Protocol: SecurityGroupRuleProtocolAll, Remote: SecurityGroupRuleRemoteAny})
+	assert.Contains(t, postBody, `"vpc":{"id":"vpcID"}`)
+	assert.Contains(t, postBody, `"protocol":"tcp","remote":{"cidr_block":"192.168.3.0/24"},"port_max":443,"port_min":443`)
+	assert.Contains(t, postBody, `"protocol":"all","remote":{"cidr_block":"0.0.0.0/0"}`)
+}
+
+func TestVpcSdkGen2_CreateSecurityGroupRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-type", "application/json")
+		if strings.Contains(req.URL.String(), "securityGroupID_123") {
+			res.WriteHeader(201)
+			fmt.Fprint(res, testSecurityGroupRuleJSON)
+		} else {
+			res.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	rule := &VpcSecurityGroupRule{Direction: SecurityGroupRuleDirectionInbound, PortMax: 443, PortMin: 443, Protocol: LoadBalancerProtocolTCP, Remote: "192.168.3.0/24"}
+	result, err := v.CreateSecurityGroupRule("securityGroupID_123", rule)
+	assert.Nil(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, result.ID, "6f2a6efe-21e2-401c-b237-620aa26ba16a")
+	assert.Equal(t, result.Remote, "192.168.3.0/24")
+
+	// Error
+	result, err = v.CreateSecurityGroupRule("securityGroupID_999", rule)
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestVpcSdkGen2_DeleteSecurityGroup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-type", "application/json")
+		if strings.Contains(req.URL.String(), "securityGroupID_123") {
+			res.WriteHeader(204)
+		} else {
+			res.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	err := v.DeleteSecurityGroup("securityGroupID_123")
+	assert.Nil(t, err)
+
+	// Error
+	err = v.DeleteSecurityGroup("securityGroupID_999")
+	assert.NotNil(t, err)
+}
+
+func TestVpcSdkGen2_DeleteSecurityGroupRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-type", "application/json")
+		if strings.Contains(req.URL.String(), "securityGroupID_123") && strings.Contains(req.URL.String(), "ruleID_123") {
+			res.WriteHeader(204)
+		} else {
+			res.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	err := v.DeleteSecurityGroupRule("securityGroupID_123", "ruleID_123")
+	assert.Nil(t, err)
+
+	// Error
+	err = v.DeleteSecurityGroupRule("securityGroupID_123", "ruleID_999")
+	assert.NotNil(t, err)
+}
+
+func TestVpcSdkGen2_ListSecurityGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-type", "application/json")
+		if req.URL.Query().Get("vpc.id") == "vpcID" {
+			res.WriteHeader(200)
+			fmt.Fprint(res, `{"first": {"href": "https://us-south.iaas.cloud.ibm.com/v1/security_groups?limit=20"}, "limit": 20, "security_groups": [`+testSecurityGroupJSON+`], "total_count": 1}`)
+		} else {
+			res.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	// Create the VPC client and SDK interface
+	v := newNoAuthTestVpcSdkGen2(server.URL)
+
+	// Success
+	securityGroups, err := v.ListSecurityGroups("vpcID")
+	assert.Nil(t, err)
+	assert.Equal(t, len(securityGroups), 1)
+	assert.Equal(t, securityGroups[0].ID, "be5df5ca-12a0-494b-907e-aa6ec2bfa271")
+	assert.Equal(t, len(securityGroups[0].Rules), 2)
+
+	// Error
+	securityGroups, err = v.ListSecurityGroups("vpcID_999")
+	assert.Equal(t, len(securityGroups), 0)
+	assert.NotNil(t, err)
 }